@@ -43,10 +43,25 @@ fn build_go_bridge(bridge_dir: &Path, target_dir: &Path) {
         .arg("-buildmode=c-shared")
         .arg("-o")
         .arg(&dll_path)
-        .arg(".")
         .current_dir(bridge_dir)
         .env("CGO_ENABLED", "1");
 
+    // The bridge reports the crate version through wm_version
+    cmd.arg(format!(
+        "-ldflags=-X main.bridgeVersion={}",
+        env::var("CARGO_PKG_VERSION").unwrap()
+    ));
+
+    // FTS5 backs message search. Tags set through GOFLAGS (e.g. libsqlite3
+    // for SQLCipher builds) are left alone, as -tags would replace them.
+    println!("cargo:rerun-if-env-changed=GOFLAGS");
+    if !env::var("GOFLAGS").unwrap_or_default().contains("-tags") {
+        cmd.arg("-tags").arg("sqlite_fts5");
+    }
+
+    // Build flags must come before the package
+    cmd.arg(".");
+
     let status = cmd.status();
 
     match status {
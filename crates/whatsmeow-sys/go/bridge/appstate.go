@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+	waSyncAction "go.mau.fi/whatsmeow/proto/waSyncAction"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// StarEvent is emitted when a message is starred or unstarred on another device
+type StarEvent struct {
+	Chat         string `json:"chat" pb:"1"`
+	Sender       string `json:"sender,omitempty" pb:"2"`
+	MessageID    string `json:"message_id" pb:"3"`
+	FromMe       bool   `json:"from_me" pb:"4"`
+	Starred      bool   `json:"starred" pb:"5"`
+	Timestamp    int64  `json:"timestamp" pb:"6"`
+	FromFullSync bool   `json:"from_full_sync" pb:"7"`
+}
+
+func newStarEvent(evt *events.Star) *StarEvent {
+	out := &StarEvent{
+		Chat:         evt.ChatJID.String(),
+		MessageID:    evt.MessageID,
+		FromMe:       evt.IsFromMe,
+		Starred:      evt.Action.GetStarred(),
+		Timestamp:    evt.Timestamp.Unix(),
+		FromFullSync: evt.FromFullSync,
+	}
+	if !evt.SenderJID.IsEmpty() {
+		out.Sender = evt.SenderJID.String()
+	}
+	return out
+}
+
+// ChatArchiveEvent is emitted when a chat is archived or unarchived on
+// another device
+type ChatArchiveEvent struct {
+	Chat         string `json:"chat" pb:"1"`
+	Archived     bool   `json:"archived" pb:"2"`
+	Timestamp    int64  `json:"timestamp" pb:"3"`
+	FromFullSync bool   `json:"from_full_sync" pb:"4"`
+}
+
+// ChatPinEvent is emitted when a chat is pinned or unpinned on another device
+type ChatPinEvent struct {
+	Chat         string `json:"chat" pb:"1"`
+	Pinned       bool   `json:"pinned" pb:"2"`
+	Timestamp    int64  `json:"timestamp" pb:"3"`
+	FromFullSync bool   `json:"from_full_sync" pb:"4"`
+}
+
+// ChatMuteEvent is emitted when a chat is muted or unmuted on another
+// device. MutedUntil is when the mute ends; Forever mutes have none.
+type ChatMuteEvent struct {
+	Chat         string `json:"chat" pb:"1"`
+	Muted        bool   `json:"muted" pb:"2"`
+	MutedUntil   int64  `json:"muted_until,omitempty" pb:"3"`
+	Forever      bool   `json:"forever" pb:"4"`
+	Timestamp    int64  `json:"timestamp" pb:"5"`
+	FromFullSync bool   `json:"from_full_sync" pb:"6"`
+}
+
+func newChatArchiveEvent(evt *events.Archive) *ChatArchiveEvent {
+	return &ChatArchiveEvent{
+		Chat:         evt.JID.String(),
+		Archived:     evt.Action.GetArchived(),
+		Timestamp:    evt.Timestamp.Unix(),
+		FromFullSync: evt.FromFullSync,
+	}
+}
+
+func newChatPinEvent(evt *events.Pin) *ChatPinEvent {
+	return &ChatPinEvent{
+		Chat:         evt.JID.String(),
+		Pinned:       evt.Action.GetPinned(),
+		Timestamp:    evt.Timestamp.Unix(),
+		FromFullSync: evt.FromFullSync,
+	}
+}
+
+func newChatMuteEvent(evt *events.Mute) *ChatMuteEvent {
+	out := &ChatMuteEvent{
+		Chat:         evt.JID.String(),
+		Muted:        evt.Action.GetMuted(),
+		Timestamp:    evt.Timestamp.Unix(),
+		FromFullSync: evt.FromFullSync,
+	}
+	if out.Muted {
+		// The end is in milliseconds; -1 (or none) mutes forever
+		if end := evt.Action.GetMuteEndTimestamp(); end > 0 {
+			out.MutedUntil = end / 1000
+		} else {
+			out.Forever = true
+		}
+	}
+	return out
+}
+
+// ChatDeletedEvent is emitted when a chat is deleted on another device, and
+// ChatClearedEvent when its messages are cleared. Messages up to
+// LastMessageTimestamp are gone; archived copies are removed too.
+type ChatDeletedEvent struct {
+	Chat                 string `json:"chat" pb:"1"`
+	LastMessageTimestamp int64  `json:"last_message_timestamp,omitempty" pb:"2"`
+	Timestamp            int64  `json:"timestamp" pb:"3"`
+	FromFullSync         bool   `json:"from_full_sync" pb:"4"`
+}
+
+// ChatClearedEvent has the fields of ChatDeletedEvent; the chat itself stays
+type ChatClearedEvent ChatDeletedEvent
+
+func (c *Client) newChatDeletedEvent(evt *events.DeleteChat) *ChatDeletedEvent {
+	out := &ChatDeletedEvent{
+		Chat:                 evt.JID.String(),
+		LastMessageTimestamp: evt.Action.GetMessageRange().GetLastMessageTimestamp(),
+		Timestamp:            evt.Timestamp.Unix(),
+		FromFullSync:         evt.FromFullSync,
+	}
+	c.purgeArchive(evt.JID, out.LastMessageTimestamp)
+	return out
+}
+
+func (c *Client) newChatClearedEvent(evt *events.ClearChat) *ChatClearedEvent {
+	out := &ChatClearedEvent{
+		Chat:                 evt.JID.String(),
+		LastMessageTimestamp: evt.Action.GetMessageRange().GetLastMessageTimestamp(),
+		Timestamp:            evt.Timestamp.Unix(),
+		FromFullSync:         evt.FromFullSync,
+	}
+	c.purgeArchive(evt.JID, out.LastMessageTimestamp)
+	return out
+}
+
+// sendAppState sends an app state patch so the change syncs to all linked devices
+func (c *Client) sendAppState(ctx context.Context, patch appstate.PatchInfo) error {
+	if err := c.checkOutgoing(); err != nil {
+		return err
+	}
+
+	if err := c.client.SendAppState(ctx, patch); err != nil {
+		return fmt.Errorf("app state update failed: %w", err)
+	}
+
+	return nil
+}
+
+// SetPushName changes the account's display name on all linked devices
+func (c *Client) SetPushName(ctx context.Context, name string) error {
+	if name == "" {
+		return argErrorf("push name is required")
+	}
+	return c.sendAppState(ctx, appstate.BuildSettingPushName(name))
+}
+
+// ArchiveChat archives or unarchives a chat (archiving also unpins it)
+func (c *Client) ArchiveChat(ctx context.Context, chatStr string, archive bool) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, appstate.BuildArchive(chat, archive, time.Time{}, nil))
+}
+
+// PinChat pins or unpins a chat
+func (c *Client) PinChat(ctx context.Context, chatStr string, pin bool) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, appstate.BuildPin(chat, pin))
+}
+
+// MuteChat mutes a chat for the given duration; zero mutes it forever
+func (c *Client) MuteChat(ctx context.Context, chatStr string, duration time.Duration) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, appstate.BuildMute(chat, true, duration))
+}
+
+// UnmuteChat unmutes a chat
+func (c *Client) UnmuteChat(ctx context.Context, chatStr string) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, appstate.BuildMute(chat, false, 0))
+}
+
+// StarMessage stars or unstars a message. sender is the message author in
+// groups and may be empty for one-to-one chats.
+func (c *Client) StarMessage(ctx context.Context, chatStr, senderStr, messageID string, fromMe, starred bool) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	sender := chat
+	if senderStr != "" {
+		if sender, err = types.ParseJID(senderStr); err != nil {
+			return argErrorf("invalid sender JID: %w", err)
+		}
+	}
+
+	return c.sendAppState(ctx, appstate.BuildStar(chat, sender, messageID, fromMe, starred))
+}
+
+// buildClearChat builds an app state patch for clearing a chat's messages,
+// which whatsmeow has no builder for
+func buildClearChat(target types.JID, keepStarred bool) appstate.PatchInfo {
+	// The third index element is "0" when starred messages are kept
+	deleteStarred := "1"
+	if keepStarred {
+		deleteStarred = "0"
+	}
+	return appstate.PatchInfo{
+		Type: appstate.WAPatchRegularHigh,
+		Mutations: []appstate.MutationInfo{{
+			Index:   []string{appstate.IndexClearChat, target.String(), deleteStarred, "0"},
+			Version: 6,
+			Value: &waSyncAction.SyncActionValue{
+				ClearChatAction: &waSyncAction.ClearChatAction{
+					MessageRange: &waSyncAction.SyncActionMessageRange{
+						LastMessageTimestamp: proto.Int64(time.Now().Unix()),
+					},
+				},
+			},
+		}},
+	}
+}
+
+// ClearChat removes all messages from a chat but keeps the chat itself
+func (c *Client) ClearChat(ctx context.Context, chatStr string, keepStarred bool) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, buildClearChat(chat, keepStarred))
+}
+
+// DeleteChat deletes a chat and its messages
+func (c *Client) DeleteChat(ctx context.Context, chatStr string) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, appstate.BuildDeleteChat(chat, time.Time{}, nil))
+}
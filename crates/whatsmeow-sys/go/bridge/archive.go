@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultArchiveLimit caps archive queries that don't set a limit
+const defaultArchiveLimit = 100
+
+// ArchivedMessage is a message stored in the bridge's message archive
+type ArchivedMessage struct {
+	MessageID string `json:"message_id"`
+	Chat      string `json:"chat"`
+	Sender    string `json:"sender"`
+	FromMe    bool   `json:"from_me"`
+	Timestamp int64  `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	// MimeType and MediaPath describe the attachment of media messages;
+	// MediaPath is the CDN direct path, downloadable until the server expires it
+	MimeType  string `json:"mime_type,omitempty"`
+	MediaPath string `json:"media_path,omitempty"`
+	// Raw is the serialized waE2E.Message
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// ArchiveQuery selects archived messages, newest first. Zero values leave a
+// filter unset: any chat, no time bounds, 100 messages.
+type ArchiveQuery struct {
+	Chat  string
+	Since int64
+	Until int64
+	Limit int
+}
+
+// SetMessageArchive toggles storing every incoming and outgoing message, and
+// those delivered by history sync, in the store's wm_bridge_archive table.
+// Disabling it keeps what was archived.
+func (c *Client) SetMessageArchive(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.MessageArchive = enabled
+}
+
+// archiveEnabled reports whether the message archive is enabled
+func (c *Client) archiveEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.MessageArchive
+}
+
+// mediaInfo returns the mime type and direct path of a media message
+func mediaInfo(msg *waProto.Message) (string, string) {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetMimetype(), msg.GetImageMessage().GetDirectPath()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetMimetype(), msg.GetVideoMessage().GetDirectPath()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetMimetype(), msg.GetAudioMessage().GetDirectPath()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetMimetype(), msg.GetDocumentMessage().GetDirectPath()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage().GetMimetype(), msg.GetStickerMessage().GetDirectPath()
+	default:
+		return "", ""
+	}
+}
+
+// archive stores a message unless the archive is disabled. Failures are
+// ignored; the archive is best effort and must not hold up event delivery.
+func (c *Client) archive(info types.MessageInfo, msg *waProto.Message) {
+	own := c.client.Store.ID
+	if own == nil || !c.archiveEnabled() {
+		return
+	}
+	// Reactions, receipts and other protocol messages aren't conversation content
+	switch messageType(msg) {
+	case "reaction", "poll_update", "protocol", "unknown":
+		return
+	}
+
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		return
+	}
+	mimeType, mediaPath := mediaInfo(msg)
+	c.db.ExecContext(c.ctx,
+		// An upsert rather than REPLACE, which would skip the search index triggers
+		`INSERT INTO wm_bridge_archive
+		(our_jid, chat, message_id, sender, from_me, timestamp, type, text, mime_type, media_path, raw)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (our_jid, chat, message_id) DO UPDATE SET
+		sender = excluded.sender, from_me = excluded.from_me, timestamp = excluded.timestamp,
+		type = excluded.type, text = excluded.text, mime_type = excluded.mime_type,
+		media_path = excluded.media_path, raw = excluded.raw`,
+		own.ToNonAD().String(), info.Chat.String(), info.ID, info.Sender.ToNonAD().String(),
+		info.IsFromMe, info.Timestamp.Unix(), messageType(msg), messageText(msg),
+		mimeType, mediaPath, raw)
+}
+
+// purgeArchive removes the archived messages of a chat up to a timestamp,
+// or all of them for zero, after the chat was deleted or cleared elsewhere
+func (c *Client) purgeArchive(chat types.JID, until int64) {
+	own := c.client.Store.ID
+	if own == nil {
+		return
+	}
+	if until == 0 {
+		until = math.MaxInt64
+	}
+	c.db.ExecContext(c.ctx,
+		"DELETE FROM wm_bridge_archive WHERE our_jid = ? AND chat = ? AND timestamp <= ?",
+		own.ToNonAD().String(), chat.String(), until)
+}
+
+// archiveIncoming stores a received message, including ones sent from the
+// account's other devices
+func (c *Client) archiveIncoming(evt *events.Message) {
+	if evt.Info.Chat == types.StatusBroadcastJID {
+		return
+	}
+	c.archive(evt.Info, evt.Message)
+}
+
+// archiveSent stores a message sent through the bridge
+func (c *Client) archiveSent(jid types.JID, msg *waProto.Message, resp whatsmeow.SendResponse) {
+	own := c.client.Store.ID
+	if own == nil {
+		return
+	}
+	c.archive(types.MessageInfo{
+		MessageSource: types.MessageSource{Chat: jid, Sender: *own, IsFromMe: true},
+		ID:            resp.ID,
+		Timestamp:     resp.Timestamp,
+	}, msg)
+}
+
+// archiveColumns are selected by archive queries in scanArchived order
+const archiveColumns = "message_id, chat, sender, from_me, timestamp, type, text, mime_type, media_path, raw"
+
+// scanArchived reads one archive row
+func scanArchived(row interface{ Scan(...any) error }) (ArchivedMessage, error) {
+	var msg ArchivedMessage
+	err := row.Scan(&msg.MessageID, &msg.Chat, &msg.Sender, &msg.FromMe, &msg.Timestamp,
+		&msg.Type, &msg.Text, &msg.MimeType, &msg.MediaPath, &msg.Raw)
+	return msg, err
+}
+
+// ArchivedMessages returns archived messages matching the query, newest first
+func (c *Client) ArchivedMessages(ctx context.Context, query ArchiveQuery) ([]ArchivedMessage, error) {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, ErrNotConnected
+	}
+	if query.Limit < 0 {
+		return nil, argErrorf("limit must not be negative")
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultArchiveLimit
+	}
+
+	where := []string{"our_jid = ?"}
+	args := []any{own.ToNonAD().String()}
+	if query.Chat != "" {
+		chat, err := types.ParseJID(query.Chat)
+		if err != nil {
+			return nil, argErrorf("invalid chat JID: %w", err)
+		}
+		where = append(where, "chat = ?")
+		args = append(args, chat.String())
+	}
+	if query.Since > 0 {
+		where = append(where, "timestamp >= ?")
+		args = append(args, query.Since)
+	}
+	if query.Until > 0 {
+		where = append(where, "timestamp < ?")
+		args = append(args, query.Until)
+	}
+	args = append(args, query.Limit)
+
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM wm_bridge_archive WHERE %s ORDER BY timestamp DESC, rowid DESC LIMIT ?",
+		archiveColumns, strings.Join(where, " AND ")), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer rows.Close()
+
+	msgs := []ArchivedMessage{}
+	for rows.Next() {
+		msg, err := scanArchived(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+// ArchivedMessage returns one archived message, or nil if it isn't archived
+func (c *Client) ArchivedMessage(ctx context.Context, chatStr, messageID string) (*ArchivedMessage, error) {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, ErrNotConnected
+	}
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return nil, argErrorf("invalid chat JID: %w", err)
+	}
+	if messageID == "" {
+		return nil, argErrorf("message ID is required")
+	}
+
+	msg, err := scanArchived(c.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM wm_bridge_archive WHERE our_jid = ? AND chat = ? AND message_id = ?", archiveColumns),
+		own.ToNonAD().String(), chat.String(), messageID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// SendResultEvent reports the outcome of an asynchronous send. On success
+// it carries the SendResult fields, on failure the error detail.
+type SendResultEvent struct {
+	RequestID int64  `json:"request_id" pb:"1"`
+	OK        bool   `json:"ok" pb:"2"`
+	MessageID string `json:"message_id,omitempty" pb:"3"`
+	Chat      string `json:"chat,omitempty" pb:"4"`
+	Timestamp int64  `json:"timestamp,omitempty" pb:"5"`
+	ServerID  int    `json:"server_id,omitempty" pb:"6"`
+	Queued    bool   `json:"queued,omitempty" pb:"7"`
+	// Code is the result code the synchronous call would have returned
+	Code      int    `json:"code,omitempty" pb:"8"`
+	Category  string `json:"category,omitempty" pb:"9"`
+	Error     string `json:"error,omitempty" pb:"10"`
+	Retryable bool   `json:"retryable,omitempty" pb:"11"`
+}
+
+// sendAsync runs send in the background under ctx, ending it with done, and
+// reports the outcome as a "send_result" event. It returns the request ID
+// the event will carry.
+func (c *Client) sendAsync(ctx context.Context, done func(), send func(context.Context) (*SendResult, error)) int64 {
+	id := c.asyncIDs.Add(1)
+	go func() {
+		defer done()
+
+		code := 0
+		result, err := func() (result *SendResult, err error) {
+			// A panic here has no export to recover it and would abort the host
+			defer func() {
+				if r := recover(); r != nil {
+					code = WM_ERR_PANIC
+					err = fmt.Errorf("bridge panic: %v", r)
+				}
+			}()
+			return send(ctx)
+		}()
+
+		evt := SendResultEvent{RequestID: id}
+		if err != nil {
+			if code == 0 {
+				code = outgoingCode(err)
+			}
+			detail := c.setError(code, err)
+			evt.Code = detail.Code
+			evt.Category = detail.Category
+			evt.Error = detail.Message
+			evt.Retryable = detail.Retryable
+		} else {
+			evt.OK = true
+			evt.MessageID = result.MessageID
+			evt.Chat = result.Chat
+			evt.Timestamp = result.Timestamp
+			evt.ServerID = result.ServerID
+			evt.Queued = result.Queued
+		}
+		c.emit("send_result", evt)
+	}()
+	return id
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Backup file layout: header, then chunks of a 4-byte big-endian length and
+// the AES-256-GCM sealed chunk. Each chunk's nonce is the header's nonce
+// prefix, the chunk index and a final-chunk flag, so reordered, dropped or
+// truncated chunks fail to open. The header is authenticated with every chunk.
+const (
+	backupMagic      = "WMBACKUP"
+	backupVersion    = 1
+	backupSaltSize   = 16
+	backupPrefixSize = 7
+	backupHeaderSize = len(backupMagic) + 1 + backupSaltSize + backupPrefixSize
+	backupChunkSize  = 1 << 20
+	// backupIterations is the PBKDF2-SHA256 work factor for the passphrase
+	backupIterations = 600000
+)
+
+// ErrBackupInvalid is returned when a backup is damaged or the passphrase is wrong
+var ErrBackupInvalid = errors.New("backup is damaged or the passphrase is wrong")
+
+// backupKey derives the AES key of a backup from its passphrase
+func backupKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, backupIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// backupNonce builds the nonce of chunk index
+func backupNonce(prefix []byte, index uint32, final bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[backupPrefixSize:], index)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptBackup seals src into dst
+func encryptBackup(dst io.Writer, src io.Reader, passphrase string) error {
+	header := make([]byte, backupHeaderSize)
+	copy(header, backupMagic)
+	header[len(backupMagic)] = backupVersion
+	salt := header[len(backupMagic)+1 : len(backupMagic)+1+backupSaltSize]
+	prefix := header[len(backupMagic)+1+backupSaltSize:]
+	if _, err := rand.Read(header[len(backupMagic)+1:]); err != nil {
+		return err
+	}
+	aead, err := backupKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	// Read one chunk ahead so the last one can be flagged as final
+	chunk, next := make([]byte, backupChunkSize), make([]byte, backupChunkSize)
+	n, err := io.ReadFull(src, chunk)
+	for index := uint32(0); ; index++ {
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return err
+		}
+		final := err != nil
+		var m int
+		if !final {
+			m, err = io.ReadFull(src, next)
+			final = err == io.EOF
+		}
+
+		sealed := aead.Seal(nil, backupNonce(prefix, index, final), chunk[:n], header)
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+		if _, werr := dst.Write(size[:]); werr != nil {
+			return werr
+		}
+		if _, werr := dst.Write(sealed); werr != nil {
+			return werr
+		}
+		if final {
+			return nil
+		}
+		chunk, next, n = next, chunk, m
+	}
+}
+
+// decryptBackup opens a backup from src into dst
+func decryptBackup(dst io.Writer, src io.Reader, passphrase string) error {
+	header := make([]byte, backupHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil || string(header[:len(backupMagic)]) != backupMagic {
+		return fmt.Errorf("not a backup file: %w", ErrBackupInvalid)
+	}
+	if header[len(backupMagic)] != backupVersion {
+		return fmt.Errorf("unsupported backup version %d", header[len(backupMagic)])
+	}
+	salt := header[len(backupMagic)+1 : len(backupMagic)+1+backupSaltSize]
+	prefix := header[len(backupMagic)+1+backupSaltSize:]
+	aead, err := backupKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	sealed := make([]byte, backupChunkSize+aead.Overhead())
+	for index := uint32(0); ; index++ {
+		var size [4]byte
+		if _, err := io.ReadFull(src, size[:]); err != nil {
+			return fmt.Errorf("backup is truncated: %w", ErrBackupInvalid)
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if int(n) > len(sealed) {
+			return ErrBackupInvalid
+		}
+		if _, err := io.ReadFull(src, sealed[:n]); err != nil {
+			return fmt.Errorf("backup is truncated: %w", ErrBackupInvalid)
+		}
+
+		final := false
+		plain, err := aead.Open(nil, backupNonce(prefix, index, false), sealed[:n], header)
+		if err != nil {
+			final = true
+			if plain, err = aead.Open(nil, backupNonce(prefix, index, true), sealed[:n], header); err != nil {
+				return ErrBackupInvalid
+			}
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// excludeArchive empties the message archive of a store snapshot. The search
+// index is dropped rather than emptied row by row; opening the restored store
+// recreates it.
+func excludeArchive(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS wm_bridge_archive_ai",
+		"DROP TRIGGER IF EXISTS wm_bridge_archive_ad",
+		"DROP TRIGGER IF EXISTS wm_bridge_archive_au",
+		"DROP TABLE IF EXISTS wm_bridge_archive_fts",
+		"DELETE FROM wm_bridge_archive",
+		"VACUUM",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup writes an encrypted snapshot of the session store to path. The
+// snapshot is consistent while the client keeps running; without archive,
+// the message archive is left out. A SQLCipher store is snapshotted with its
+// key, so restoring it needs the same key. The bridge keeps no media cache.
+func (c *Client) Backup(ctx context.Context, path, passphrase string, archive bool) error {
+	if path == "" {
+		return argErrorf("backup path is required")
+	}
+	if passphrase == "" {
+		return argErrorf("backup passphrase is required")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".wm-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	snapshot := tmp.Name()
+	tmp.Close()
+	defer os.Remove(snapshot)
+	// VACUUM INTO refuses to overwrite a file
+	os.Remove(snapshot)
+
+	if _, err := c.db.ExecContext(ctx, "VACUUM INTO ?", snapshot); err != nil {
+		return fmt.Errorf("failed to snapshot store: %w", err)
+	}
+	if !archive {
+		drv := &sqlite3.SQLiteDriver{}
+		if c.config.EncryptionKey != "" {
+			drv.ConnectHook = keyHook(c.config.EncryptionKey)
+		}
+		db := sql.OpenDB(&sqliteConnector{dsn: "file:" + snapshot, driver: drv})
+		err := excludeArchive(ctx, db)
+		db.Close()
+		if err != nil {
+			return fmt.Errorf("failed to strip message archive: %w", err)
+		}
+	}
+
+	return writeBackup(snapshot, path, passphrase)
+}
+
+// writeBackup encrypts the snapshot file into path, replacing it atomically
+func writeBackup(snapshot, path, passphrase string) error {
+	src, err := os.Open(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(filepath.Dir(path), ".wm-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer os.Remove(dst.Name())
+
+	err = encryptBackup(dst, src, passphrase)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(dst.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// RestoreBackup decrypts a backup into a store file at dbPath, replacing it.
+// It must run before a client opens dbPath; the restored store is then
+// opened as usual, with the SQLCipher key it was backed up with.
+func RestoreBackup(path, passphrase, dbPath string) error {
+	if path == "" || dbPath == "" {
+		return argErrorf("backup and store paths are required")
+	}
+	if dbPath == MemoryDbPath {
+		return argErrorf("can't restore into an in-memory store")
+	}
+	if storeInUse(dbPath) {
+		return argErrorf("store %s is open by a client", dbPath)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(filepath.Dir(dbPath), ".wm-restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+	defer os.Remove(dst.Name())
+
+	err = decryptBackup(dst, src, passphrase)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// A rollback journal left by the replaced store would corrupt the restored one
+	os.Remove(dbPath + "-journal")
+	if err := os.Rename(dst.Name(), dbPath); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+
+	waCompanionReg "go.mau.fi/whatsmeow/proto/waCompanionReg"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Low-bandwidth profile limits
+const (
+	lowBandwidthHistoryDays     = 7
+	lowBandwidthHistorySizeMb   = 10
+	lowBandwidthStorageQuotaMb  = 512
+	lowBandwidthCompressMinSize = 1024
+)
+
+// applyLowBandwidthHistory asks the phone for a reduced history sync at pairing time
+func applyLowBandwidthHistory(props *waCompanionReg.DeviceProps) {
+	if props.HistorySyncConfig == nil {
+		props.HistorySyncConfig = &waCompanionReg.DeviceProps_HistorySyncConfig{}
+	}
+	cfg := props.HistorySyncConfig
+	cfg.FullSyncDaysLimit = proto.Uint32(lowBandwidthHistoryDays)
+	cfg.FullSyncSizeMbLimit = proto.Uint32(lowBandwidthHistorySizeMb)
+	cfg.RecentSyncDaysLimit = proto.Uint32(lowBandwidthHistoryDays)
+	cfg.StorageQuotaMb = proto.Uint32(lowBandwidthStorageQuotaMb)
+	cfg.ThumbnailSyncDaysLimit = proto.Uint32(0)
+	props.RequireFullSync = proto.Bool(false)
+}
+
+// stripThumbnails returns a copy of a message event without inline thumbnails
+func stripThumbnails(evt *events.Message) *events.Message {
+	if evt.Message == nil {
+		return evt
+	}
+	stripped := *evt
+	stripped.Message = proto.Clone(evt.Message).(*waProto.Message)
+	clearThumbnails(stripped.Message.ProtoReflect())
+	return &stripped
+}
+
+// clearThumbnails recursively clears every bytes field named like a thumbnail
+func clearThumbnails(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Kind() == protoreflect.BytesKind && strings.Contains(strings.ToLower(string(fd.Name())), "thumbnail"):
+			m.Clear(fd)
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				clearThumbnails(list.Get(i).Message())
+			}
+		case fd.Kind() == protoreflect.MessageKind && !fd.IsMap():
+			clearThumbnails(v.Message())
+		}
+		return true
+	})
+}
+
+// compressEvent gzips large event payloads. Compressed events start with the
+// gzip magic bytes (0x1f 0x8b) and are never confused with JSON, which starts with '{'.
+func compressEvent(data []byte) []byte {
+	if len(data) < lowBandwidthCompressMinSize {
+		return data
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err != nil {
+		return data
+	}
+	if _, err := zw.Write(data); err != nil {
+		return data
+	}
+	if err := zw.Close(); err != nil {
+		return data
+	}
+	if buf.Len() >= len(data) {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// SetLowBandwidth toggles the low-bandwidth profile. The history sync limits
+// only take effect when pairing a new device.
+func (c *Client) SetLowBandwidth(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config.LowBandwidth = enabled
+}
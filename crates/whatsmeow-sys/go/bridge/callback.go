@@ -0,0 +1,116 @@
+package main
+
+/*
+typedef void (*wm_event_cb)(void *user_data, const char *data, int len);
+
+static inline void wm_invoke_event_cb(wm_event_cb cb, void *user_data, const char *data, int len) {
+	cb(user_data, data, len);
+}
+*/
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// eventDispatcher delivers queued events to a registered C callback
+type eventDispatcher struct {
+	cb   C.wm_event_cb
+	user unsafe.Pointer
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SetEventCallback registers cb to receive every event instead of polling.
+// Passing a nil cb unregisters the current callback and resumes polling mode.
+//
+// Threading: events are delivered from a single bridge-owned OS thread, one
+// at a time and in queue order. data is only valid for the duration of the
+// call. A replaced callback finishes its in-flight invocation before the new
+// one starts. The callback must not call wm_client_destroy for its own client.
+func (c *Client) SetEventCallback(cb C.wm_event_cb, user unsafe.Pointer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.dispatch
+	if prev != nil {
+		close(prev.stop)
+		c.dispatch = nil
+	}
+	if cb == nil {
+		return
+	}
+
+	d := &eventDispatcher{
+		cb:   cb,
+		user: user,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	c.dispatch = d
+	go c.runDispatcher(d, prev)
+}
+
+// runDispatcher drains the event queue into the callback until stopped
+func (c *Client) runDispatcher(d, prev *eventDispatcher) {
+	defer close(d.done)
+
+	// Keep callbacks on one OS thread so hosts can rely on thread-local state
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if prev != nil {
+		<-prev.done
+	}
+
+	// An event PollEvents held back is older than anything queued
+	c.heldMu.Lock()
+	held := c.held
+	c.held = nil
+	c.heldMu.Unlock()
+	if len(held) > 0 {
+		C.wm_invoke_event_cb(d.cb, d.user, (*C.char)(unsafe.Pointer(&held[0])), C.int(len(held)))
+	}
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-c.ctx.Done():
+			return
+		default:
+		}
+
+		data := c.popEvent()
+		if data == nil {
+			select {
+			case <-d.stop:
+				return
+			case <-c.ctx.Done():
+				return
+			case <-c.eventQueue.ready:
+			}
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		C.wm_invoke_event_cb(d.cb, d.user, (*C.char)(unsafe.Pointer(&data[0])), C.int(len(data)))
+	}
+}
+
+// stopEventCallback unregisters the callback and waits for an in-flight call
+func (c *Client) stopEventCallback() {
+	c.mu.Lock()
+	d := c.dispatch
+	if d != nil {
+		close(d.stop)
+		c.dispatch = nil
+	}
+	c.mu.Unlock()
+
+	if d != nil {
+		<-d.done
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// CallEvent describes a call signal, emitted as call_offer, call_accept,
+// call_terminate or call_offer_notice (group calls). The bridge can't take
+// calls; these only let hosts log, notify or reject them.
+type CallEvent struct {
+	CallID  string `json:"call_id" pb:"1"`
+	From    string `json:"from" pb:"2"`
+	Creator string `json:"creator" pb:"3"`
+	Group   string `json:"group,omitempty" pb:"4"`
+	Video   bool   `json:"video" pb:"5"`
+	// Timestamp is when the server relayed the signal (unix seconds)
+	Timestamp int64 `json:"timestamp" pb:"6"`
+	// Platform and Version describe the caller's client, when known
+	Platform string `json:"platform,omitempty" pb:"7"`
+	Version  string `json:"version,omitempty" pb:"8"`
+	// Reason is why a call ended, for call_terminate
+	Reason string `json:"reason,omitempty" pb:"9"`
+}
+
+func newCallEvent(meta types.BasicCallMeta) *CallEvent {
+	evt := &CallEvent{
+		CallID:    meta.CallID,
+		From:      meta.From.ToNonAD().String(),
+		Creator:   meta.CallCreator.ToNonAD().String(),
+		Timestamp: meta.Timestamp.Unix(),
+	}
+	if !meta.GroupJID.IsEmpty() {
+		evt.Group = meta.GroupJID.String()
+	}
+	return evt
+}
+
+func newCallOfferEvent(e *events.CallOffer) *CallEvent {
+	evt := newCallEvent(e.BasicCallMeta)
+	evt.Platform, evt.Version = e.RemotePlatform, e.RemoteVersion
+	// The offer lists a video stream next to the audio one for video calls
+	if e.Data != nil {
+		_, evt.Video = e.Data.GetOptionalChildByTag("video")
+	}
+	return evt
+}
+
+func newCallAcceptEvent(e *events.CallAccept) *CallEvent {
+	evt := newCallEvent(e.BasicCallMeta)
+	evt.Platform, evt.Version = e.RemotePlatform, e.RemoteVersion
+	if e.Data != nil {
+		_, evt.Video = e.Data.GetOptionalChildByTag("video")
+	}
+	return evt
+}
+
+func newCallOfferNoticeEvent(e *events.CallOfferNotice) *CallEvent {
+	evt := newCallEvent(e.BasicCallMeta)
+	evt.Video = e.Media == "video"
+	return evt
+}
+
+func newCallTerminateEvent(e *events.CallTerminate) *CallEvent {
+	evt := newCallEvent(e.BasicCallMeta)
+	evt.Reason = e.Reason
+	return evt
+}
+
+// RejectCall declines an incoming call so it stops ringing on the account's
+// other devices; caller is the call's creator from the call_offer event
+func (c *Client) RejectCall(ctx context.Context, callID, callerStr string) error {
+	if callID == "" {
+		return argErrorf("call ID is required")
+	}
+	caller, err := types.ParseJID(callerStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return err
+	}
+
+	if err := c.client.RejectCall(ctx, caller, callID); err != nil {
+		return fmt.Errorf("reject call failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Chat export formats
+const (
+	// ExportFormatJSON writes the conversation as one JSON document
+	ExportFormatJSON = "json"
+	// ExportFormatText writes one line per message, like the official export
+	ExportFormatText = "txt"
+)
+
+// ExportedMessage is one message of a chat export
+type ExportedMessage struct {
+	MessageID  string `json:"message_id"`
+	Sender     string `json:"sender"`
+	SenderName string `json:"sender_name"`
+	FromMe     bool   `json:"from_me"`
+	Timestamp  int64  `json:"timestamp"`
+	Type       string `json:"type"`
+	Text       string `json:"text,omitempty"`
+	MimeType   string `json:"mime_type,omitempty"`
+	// MediaFile is the bundled attachment, relative to the export file
+	MediaFile string `json:"media_file,omitempty"`
+}
+
+// ChatExport is the document written by the JSON export format
+type ChatExport struct {
+	Chat       string            `json:"chat"`
+	ExportedAt int64             `json:"exported_at"`
+	Messages   []ExportedMessage `json:"messages"`
+}
+
+// ChatExportResult summarizes a finished chat export
+type ChatExportResult struct {
+	Path     string `json:"path"`
+	Messages int    `json:"messages"`
+	// MediaFiles counts bundled attachments; MediaFailed those that couldn't
+	// be downloaded anymore
+	MediaFiles  int `json:"media_files"`
+	MediaFailed int `json:"media_failed"`
+}
+
+// mediaExtensions covers the mime types WhatsApp clients send
+var mediaExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"video/mp4":       ".mp4",
+	"audio/ogg":       ".opus",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+	"application/pdf": ".pdf",
+}
+
+// mediaExtension picks a file extension for a mime type
+func mediaExtension(mimeType string) string {
+	base, _, _ := strings.Cut(mimeType, ";")
+	base = strings.TrimSpace(base)
+	if ext, ok := mediaExtensions[base]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(base); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// senderName labels a message sender for exports: "You" for our own
+// messages, else the contact name, push name or phone number
+func (c *Client) senderName(ctx context.Context, msg ArchivedMessage) string {
+	if msg.FromMe {
+		return "You"
+	}
+	jid, err := types.ParseJID(msg.Sender)
+	if err != nil {
+		return msg.Sender
+	}
+	if contact, err := c.client.Store.Contacts.GetContact(ctx, jid); err == nil && contact.Found {
+		for _, name := range []string{contact.FullName, contact.FirstName, contact.PushName, contact.BusinessName} {
+			if name != "" {
+				return name
+			}
+		}
+	}
+	if jid.Server == types.DefaultUserServer {
+		return "+" + jid.User
+	}
+	return jid.User
+}
+
+// archivedChat returns all archived messages of a chat, oldest first
+func (c *Client) archivedChat(ctx context.Context, chat types.JID) ([]ArchivedMessage, error) {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, ErrNotConnected
+	}
+
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT %s FROM wm_bridge_archive WHERE our_jid = ? AND chat = ? ORDER BY timestamp, rowid", archiveColumns),
+		own.ToNonAD().String(), chat.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []ArchivedMessage
+	for rows.Next() {
+		msg, err := scanArchived(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+// bundleMedia downloads the attachment of an archived message into dir and
+// returns the file name
+func (c *Client) bundleMedia(ctx context.Context, msg ArchivedMessage, dir string) (string, error) {
+	var content waProto.Message
+	if err := proto.Unmarshal(msg.Raw, &content); err != nil {
+		return "", err
+	}
+	release, err := c.acquireMediaSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	data, err := c.client.DownloadAny(ctx, &content)
+	release()
+	if err != nil {
+		return "", err
+	}
+	c.stats.downloaded.Add(uint64(len(data)))
+
+	name := msg.MessageID + mediaExtension(msg.MimeType)
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ExportChat writes a conversation from the message archive, which includes
+// history synced while the archive was enabled, to path as JSON or text.
+// With media, attachments are downloaded next to it into "<name>_media";
+// ones the server no longer serves are skipped and counted.
+func (c *Client) ExportChat(ctx context.Context, chatStr, path, format string, media bool) (*ChatExportResult, error) {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return nil, argErrorf("invalid chat JID: %w", err)
+	}
+	if path == "" {
+		return nil, argErrorf("export path is required")
+	}
+	if format == "" {
+		format = ExportFormatJSON
+	}
+	if format != ExportFormatJSON && format != ExportFormatText {
+		return nil, argErrorf("unknown export format %q", format)
+	}
+	if media {
+		if err := c.checkOutgoing(); err != nil {
+			return nil, err
+		}
+	}
+
+	msgs, err := c.archivedChat(ctx, chat)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ChatExportResult{Path: path, Messages: len(msgs)}
+	mediaDir := strings.TrimSuffix(path, filepath.Ext(path)) + "_media"
+	if media {
+		if err := os.MkdirAll(mediaDir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create media directory: %w", err)
+		}
+	}
+
+	export := ChatExport{Chat: chat.String(), ExportedAt: time.Now().Unix(), Messages: make([]ExportedMessage, 0, len(msgs))}
+	for _, msg := range msgs {
+		out := ExportedMessage{
+			MessageID:  msg.MessageID,
+			Sender:     msg.Sender,
+			SenderName: c.senderName(ctx, msg),
+			FromMe:     msg.FromMe,
+			Timestamp:  msg.Timestamp,
+			Type:       msg.Type,
+			Text:       msg.Text,
+			MimeType:   msg.MimeType,
+		}
+		if media && msg.MediaPath != "" {
+			if name, err := c.bundleMedia(ctx, msg, mediaDir); err == nil {
+				out.MediaFile = filepath.Join(filepath.Base(mediaDir), name)
+				result.MediaFiles++
+			} else {
+				result.MediaFailed++
+			}
+		}
+		export.Messages = append(export.Messages, out)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if format == ExportFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(export)
+	} else {
+		err = writeTextExport(w, export.Messages)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write export: %w", err)
+	}
+	return result, nil
+}
+
+// writeTextExport writes "[date, time] name: text" lines, marking bundled
+// attachments with "<attached: file>" like the official export
+func writeTextExport(w *bufio.Writer, msgs []ExportedMessage) error {
+	for _, msg := range msgs {
+		body := msg.Text
+		switch {
+		case msg.MediaFile != "":
+			body = strings.TrimSpace(fmt.Sprintf("<attached: %s> %s", msg.MediaFile, msg.Text))
+		case msg.MimeType != "" && body == "":
+			body = fmt.Sprintf("<%s omitted>", msg.Type)
+		}
+		ts := time.Unix(msg.Timestamp, 0).Format("2006-01-02, 15:04:05")
+		if _, err := fmt.Fprintf(w, "[%s] %s: %s\n", ts, msg.SenderName, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
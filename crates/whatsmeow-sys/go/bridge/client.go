@@ -2,161 +2,599 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"go.mau.fi/whatsmeow"
-	waCompanionReg "go.mau.fi/whatsmeow/proto/waCompanionReg"
 	waProto "go.mau.fi/whatsmeow/proto/waE2E"
-	"go.mau.fi/whatsmeow/store"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
-	waLog "go.mau.fi/whatsmeow/util/log"
+	"go.mau.fi/whatsmeow/types/events"
 	"google.golang.org/protobuf/proto"
 )
 
+// ErrFrozen is returned by outgoing actions while the client is frozen
+var ErrFrozen = errors.New("client is frozen")
+
 // Client wraps WhatsMeow with an event queue for FFI
 type Client struct {
 	mu         sync.RWMutex
 	client     *whatsmeow.Client
+	config     ClientConfig
+	db         *sql.DB
+	fts        bool
 	store      *sqlstore.Container
-	eventQueue chan []byte
+	eventQueue *laneQueue
+	heldMu     sync.Mutex
+	held       []byte
+	logs       *logSink
+	emitMu     sync.Mutex
+	seq        uint64
+	dropped    atomic.Uint64
+	stats      *statsCounters
+	ops        *opRegistry
+	limiter    *rateLimiter
+	asyncIDs   atomic.Int64
+	mediaSlots *mediaSemaphore
+	mediaHTTP  *http.Client
+	played     *playedTracker
+	reactions  *reactionTracker
+	polls      *pollTracker
+	delivery   *deliveryTracker
+	liveSubs   map[types.JID]*liveSub
+	devCheck   chan struct{}
+	pkCheck    chan struct{}
+	frozen     atomic.Bool
+	sendMu     sync.RWMutex
+	closing    atomic.Bool
+	closed     atomic.Bool
+	kaFails    atomic.Int32
+	outboxLen  atomic.Int64
+	flushing   atomic.Bool
+	drain      outboxDrain
+	dispatch   *eventDispatcher
+	// handle is the registry handle the host knows the client by
+	handle     uintptr
 	ctx        context.Context
 	cancel     context.CancelFunc
 	connected  bool
-	lastError  string
+	connecting bool
+	// disconnects counts Disconnect calls, and connectAfter is its value at
+	// the latest Connect, so a running attempt sees when it was abandoned
+	disconnects  uint64
+	connectAfter uint64
+	reconnect    context.CancelFunc
+	halted       bool
+	qrRetries    int
+	syncStart    time.Time
+	syncTotal    int
+	lastError    ErrorDetail
 }
 
 // ClientConfig holds configuration for creating a new client
 type ClientConfig struct {
 	DbPath     string
 	DeviceName string
+	// Platform is the DeviceProps platform announced when pairing, such as
+	// "DESKTOP" (default) or "CHROME"
+	Platform string
+	// HistorySync sets how much history the phone sends after pairing
+	HistorySync HistorySyncConfig
+	// Version pins or periodically refreshes the WhatsApp Web version
+	Version VersionConfig
+	// AvatarDir receives the new picture of contacts and groups whose
+	// picture changed, before their picture_update event
+	AvatarDir string
+	// OfflineBatch is how many offline messages are requested at a time
+	// after connecting; zero leaves it to the server
+	OfflineBatch int
+	// QRRefresh is how many times a login whose QR codes all expired is
+	// restarted automatically for fresh codes
+	QRRefresh int
+	// Ephemeral keeps the session store in memory only; nothing is written to disk
+	Ephemeral bool
+	// EncryptionKey enables SQLCipher encryption of the store (requires a SQLCipher build)
+	EncryptionKey string
+	// TLS customizes certificate verification for websocket and media connections
+	TLS TLSConfig
+	// Media controls proxy, timeout and parallelism of media transfers
+	Media MediaConfig
+	// Dialer controls IP family, DNS resolution and endpoint overrides
+	Dialer DialerConfig
+	// Websocket overrides the websocket endpoint, Origin header and timeouts
+	Websocket WebsocketConfig
+	// Proxy routes websocket and media traffic through an egress proxy
+	Proxy ProxyConfig
+	// Reconnect controls automatic reconnection after unexpected disconnects
+	Reconnect ReconnectConfig
+	// OfflineQueue stores sends made while disconnected and delivers them in
+	// order once logged in again
+	OfflineQueue bool
+	// MessageArchive stores every incoming and outgoing message in the store
+	MessageArchive bool
+	// StreamTakeover reconnects when another client replaces the stream
+	StreamTakeover bool
+	// RateLimit paces outgoing messages globally and per chat
+	RateLimit RateLimitConfig
+	// HumanPacing delays sends by a random, length-dependent composition time
+	HumanPacing HumanPacingConfig
+	// Retry controls resending our messages when recipients fail to decrypt them
+	Retry RetryConfig
+	// RequestFromPhone asks the primary phone for messages that stay undecryptable
+	RequestFromPhone bool
+	// KeepAlive tunes keepalive pings and when failing pings force a reconnect
+	KeepAlive KeepAliveConfig
+	// LowBandwidth requests a reduced history sync, overriding HistorySync,
+	// strips thumbnails from events, skips avatar downloads and
+	// gzip-compresses large event payloads
+	LowBandwidth bool
+	// NormalizeReactions merges skin-tone and presentation variants of an
+	// emoji in aggregated reaction counts; events keep the raw emoji
+	NormalizeReactions bool
+	// DisplayNameMode selects the DisplayName fallback order of message events
+	// (DisplayNamePushFirst, DisplayNameContactFirst or DisplayNameOff)
+	DisplayNameMode string
+	// QueueSize is how many events other than control and history sync
+	// events are queued for the host before the oldest are dropped; zero
+	// means 3072
+	QueueSize int
+	// EventFormat selects the event envelope encoding (EventFormatJSON,
+	// EventFormatProtobuf, EventFormatMsgpack or EventFormatCBOR); empty means JSON
+	EventFormat string
+	// PreKeyThreshold is the server prekey count below which the bridge
+	// uploads more; zero means 20
+	PreKeyThreshold int
+	// KeyExport allows exporting app state sync keys for debugging
+	KeyExport bool
+	// RawMessages adds the serialized waE2E.Message (RawProto) to message events
+	// for hosts that parse message types the bridge does not normalize
+	RawMessages bool
 }
 
 // NewClient creates a new WhatsApp client with the given configuration
 func NewClient(config ClientConfig) (*Client, error) {
 	ctx := context.Background()
 
-	// Set custom device properties
-	deviceName := config.DeviceName
-	if deviceName == "" {
-		deviceName = "WhatsApp-RS"
+	if _, err := parsePlatform(config.Platform); err != nil {
+		return nil, err
+	}
+	if err := config.Reconnect.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Retry.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Version.validate(); err != nil {
+		return nil, err
+	}
+	if config.QRRefresh < 0 {
+		return nil, argErrorf("QR refresh limit must not be negative")
+	}
+	if config.OfflineBatch < 0 {
+		return nil, argErrorf("offline batch size must not be negative")
+	}
+	if config.QueueSize < 0 {
+		return nil, argErrorf("event queue size must not be negative")
 	}
-	store.DeviceProps.Os = &deviceName
-	store.DeviceProps.PlatformType = waCompanionReg.DeviceProps_DESKTOP.Enum()
 
 	// Initialize database (new API requires context)
-	container, err := sqlstore.New(ctx, "sqlite3",
-		fmt.Sprintf("file:%s?_foreign_keys=on", config.DbPath),
-		waLog.Noop)
+	logs := newLogSink()
+	db, container, err := openStore(ctx, config, logs.logger("Database"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to open store: %w", err)
+		return nil, err
 	}
 
 	// Get or create device (new API requires context)
 	device, err := container.GetFirstDevice(ctx)
 	if err != nil {
+		container.Close()
 		return nil, fmt.Errorf("failed to get device: %w", err)
 	}
 
-	client := whatsmeow.NewClient(device, waLog.Noop)
+	client := whatsmeow.NewClient(device, logs.logger("Client"))
+	// Reconnects are driven by the bridge so the backoff can be configured
+	client.EnableAutoReconnect = false
 	clientCtx, cancel := context.WithCancel(context.Background())
 
 	c := &Client{
 		client:     client,
+		config:     config,
+		db:         db,
+		fts:        ensureArchiveSearch(ctx, db),
 		store:      container,
-		eventQueue: make(chan []byte, 1024),
+		eventQueue: newLaneQueue(config.QueueSize),
+		logs:       logs,
+		stats:      newStatsCounters(),
+		ops:        newOpRegistry(),
+		limiter:    newRateLimiter(),
+		mediaSlots: newMediaSemaphore(),
+		played:     newPlayedTracker(),
+		reactions:  newReactionTracker(),
+		polls:      newPollTracker(),
+		delivery:   newDeliveryTracker(),
+		liveSubs:   make(map[types.JID]*liveSub),
+		devCheck:   make(chan struct{}, 1),
+		pkCheck:    make(chan struct{}, 1),
 		ctx:        clientCtx,
 		cancel:     cancel,
 	}
 
+	if err := c.applyNetworkConfig(); err != nil {
+		cancel()
+		container.Close()
+		return nil, err
+	}
+	if err := c.loadOutbox(); err != nil {
+		cancel()
+		container.Close()
+		return nil, fmt.Errorf("failed to load offline queue: %w", err)
+	}
+	if config.RateLimit != (RateLimitConfig{}) {
+		if err := c.SetRateLimit(config.RateLimit); err != nil {
+			cancel()
+			container.Close()
+			return nil, err
+		}
+	}
+	if config.KeepAlive != (KeepAliveConfig{}) {
+		if err := c.SetKeepAliveConfig(config.KeepAlive); err != nil {
+			cancel()
+			container.Close()
+			return nil, err
+		}
+	}
+
+	// Device props are applied per client when the handshake payload is built
+	client.GetClientPayload = c.clientPayload
+	client.PreRetryCallback = c.preRetry
+	client.AutomaticMessageRerequestFromPhone = config.RequestFromPhone
+
 	// Register event handler
 	client.AddEventHandler(c.handleEvent)
+	go c.watchDevices()
+	go c.watchPreKeys()
 
 	return c, nil
 }
 
-// Connect initiates the WhatsApp connection
+// Connect starts connecting in the background and returns immediately.
+// Progress and the result are reported through qr, connected and
+// connect_failure events; calling it while an attempt is running is a no-op.
 func (c *Client) Connect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.client.Store.ID == nil {
-		// Need QR code login
-		qrChan, _ := c.client.GetQRChannel(c.ctx)
-		err := c.client.Connect()
-		if err != nil {
-			c.lastError = err.Error()
-			return fmt.Errorf("connect failed: %w", err)
-		}
-
-		// Forward QR codes to event queue
-		go func() {
-			for evt := range qrChan {
-				data, err := MarshalEvent(evt)
-				if err == nil {
-					c.eventQueue <- data
-				}
-			}
-		}()
-	} else {
-		// Already logged in
-		err := c.client.Connect()
-		if err != nil {
-			c.lastError = err.Error()
-			return fmt.Errorf("connect failed: %w", err)
-		}
+	// A running attempt serves this call too, even if a Disconnect
+	// abandoned it meanwhile
+	c.connectAfter = c.disconnects
+	c.halted = false
+	if c.connecting {
+		return nil
 	}
+	c.connecting = true
 
-	c.connected = true
+	go c.runConnect()
 	return nil
 }
 
 // handleEvent processes any WhatsMeow event
 func (c *Client) handleEvent(evt interface{}) {
-	data, err := MarshalEvent(evt)
-	if err != nil {
+	payload := evt
+	switch e := evt.(type) {
+	case *events.Message:
+		msg := e
+		if c.lowBandwidth() {
+			msg = stripThumbnails(msg)
+		}
+		if msg.Info.Chat == types.StatusBroadcastJID {
+			payload = c.newStatusUpdateEvent(msg)
+		} else if change := newMessageChangeEvent(msg); change != nil {
+			payload = change
+		} else if reaction := newReactionEvent(msg); reaction != nil {
+			payload = reaction
+		} else if poll := c.newPollEvent(msg); poll != nil {
+			payload = poll
+		} else {
+			payload = c.newMessageEvent(msg)
+		}
+	case *events.TemporaryBan:
+		payload = c.handleTemporaryBan(e)
+	case *events.ClientOutdated:
+		payload = c.handleClientOutdated()
+	case *events.Contact:
+		payload = c.newContactsSyncedEvent(e)
+	case *events.OfflineSyncPreview:
+		payload = c.handleOfflinePreview(e)
+	case *events.OfflineSyncCompleted:
+		payload = c.handleOfflineCompleted(e)
+	case *events.HistorySync:
+		// The raw blob is never queued, only its chunks and a summary
+		payload = c.emitHistorySync(e)
+	case *events.DeleteChat:
+		payload = c.newChatDeletedEvent(e)
+	case *events.ClearChat:
+		payload = c.newChatClearedEvent(e)
+	case *events.Picture:
+		picture := c.handlePicture(e)
+		if picture == nil {
+			// Emitted once the new avatar is downloaded
+			return
+		}
+		payload = picture
+	case *events.KeepAliveTimeout:
+		payload = c.newKeepAliveTimeoutEvent(e)
+	case *events.KeepAliveRestored:
+		payload = c.newKeepAliveRestoredEvent()
+	case *events.StreamReplaced:
+		payload = c.handleStreamReplaced()
+	case *events.NewsletterLiveUpdate:
+		// Delivered as one newsletter_message event per post
+		c.emitNewsletterUpdate(e)
 		return
 	}
 
-	select {
-	case c.eventQueue <- data:
+	eventType, payload := normalizeEvent(payload)
+	c.emit(eventType, payload)
+
+	switch e := evt.(type) {
+	case *events.AppStateSyncComplete:
+		c.emitContactsSynced(e)
+	case *events.GroupInfo:
+		c.emitMemberAddMode(e)
+	case *events.Receipt:
+		c.trackPlayed(e)
+		c.trackDelivery(e)
+	case *events.Message:
+		c.stats.countReceived(messageType(e.Message))
+		c.trackReaction(e)
+		c.archiveIncoming(e)
+	case *events.Connected:
+		c.triggerDeviceCheck()
+		c.triggerPreKeyCheck()
+		c.renewLiveUpdates()
+		go c.flushOutbox()
+	case *events.Disconnected:
+		c.startReconnect()
+	case *events.ClientOutdated:
+		go c.refreshVersion(true)
+	case *events.KeepAliveTimeout:
+		go c.checkKeepAlive(e)
+	}
+}
+
+// emit stamps an event with the next sequence number and queues it. The
+// payload is only encoded once the host polls it, in the format selected
+// now. Queueing happens under emitMu so sequence numbers follow queue order.
+func (c *Client) emit(eventType string, payload interface{}) {
+	if c.closed.Load() {
+		return
+	}
+	evt := &queuedEvent{
+		eventType: eventType,
+		timestamp: time.Now().UnixMilli(),
+		format:    c.eventFormat(),
+		compress:  c.lowBandwidth(),
+		payload:   payload,
+	}
+
+	c.emitMu.Lock()
+	defer c.emitMu.Unlock()
+
+	c.seq++
+	evt.seq = c.seq
+	c.enqueue(evt)
+}
+
+// enqueue adds an event to its lane, dropping the oldest of the lane when
+// full. Callers must hold emitMu.
+func (c *Client) enqueue(evt *queuedEvent) {
+	if n := c.eventQueue.push(eventLane(evt.eventType), evt); n > 0 {
+		c.dropped.Add(uint64(n))
+	}
+}
+
+// SetDisplayNameMode selects how DisplayName is computed for message events
+func (c *Client) SetDisplayNameMode(mode string) error {
+	switch mode {
+	case DisplayNameOff, DisplayNamePushFirst, DisplayNameContactFirst:
 	default:
-		// Queue full, drop oldest
-		select {
-		case <-c.eventQueue:
-		default:
-		}
-		c.eventQueue <- data
+		return argErrorf("unknown display name mode %q", mode)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.DisplayNameMode = mode
+	return nil
+}
+
+// SetEventFormat selects the encoding of subsequently queued events; events
+// already in the queue keep the format they were queued with
+func (c *Client) SetEventFormat(format string) error {
+	switch format {
+	case "", EventFormatJSON, EventFormatProtobuf, EventFormatMsgpack, EventFormatCBOR:
+	default:
+		return argErrorf("unknown event format %q", format)
 	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.EventFormat = format
+	return nil
+}
+
+// eventFormat returns the configured event envelope encoding
+func (c *Client) eventFormat() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.EventFormat
+}
+
+// lowBandwidth reports whether the low-bandwidth profile is active
+func (c *Client) lowBandwidth() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.LowBandwidth
 }
 
 // PollEvent retrieves the next event (non-blocking)
 func (c *Client) PollEvent() []byte {
-	select {
-	case evt := <-c.eventQueue:
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	return c.nextEvent()
+}
+
+// nextEvent returns the event held back by PollEvents, else the next queued
+// one; heldMu must be held
+func (c *Client) nextEvent() []byte {
+	if evt := c.held; evt != nil {
+		c.held = nil
 		return evt
-	default:
-		return nil
 	}
+	return c.popEvent()
+}
+
+// popEvent encodes and returns the next queued event, or nil if there is
+// none. Events that fail to encode are skipped and counted as dropped.
+func (c *Client) popEvent() []byte {
+	for {
+		evt := c.eventQueue.pop()
+		if evt == nil {
+			return nil
+		}
+		data, err := evt.encode()
+		if err == nil {
+			return data
+		}
+		c.dropped.Add(1)
+	}
+}
+
+// PollEvents drains up to max events into one buffer of at most size bytes,
+// each event as a 4-byte big-endian length followed by the event. An event
+// that doesn't fit stays first in line for the next poll; ok is false if not
+// even the first one fit.
+func (c *Client) PollEvents(max, size int) (data []byte, ok bool) {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+
+	for n := 0; n < max; n++ {
+		evt := c.nextEvent()
+		if evt == nil {
+			break
+		}
+		if len(data)+4+len(evt) > size {
+			c.held = evt
+			return data, len(data) > 0
+		}
+		data = binary.BigEndian.AppendUint32(data, uint32(len(evt)))
+		data = append(data, evt...)
+	}
+	return data, true
+}
+
+// pendingEvents counts the events waiting to be polled
+func (c *Client) pendingEvents() int {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	n := c.eventQueue.len()
+	if c.held != nil {
+		n++
+	}
+	return n
+}
+
+// DroppedEvents returns how many events were lost to queue overflow, to
+// encoding failures or to poll buffers too small to hold them
+func (c *Client) DroppedEvents() uint64 {
+	return c.dropped.Load()
+}
+
+// GenerateMessageID allocates a message ID for a later send, so hosts can
+// record it before sending for exactly-once bookkeeping
+func (c *Client) GenerateMessageID() string {
+	return c.client.GenerateMessageID()
 }
 
-// SendMessage sends a text message to the specified JID
-func (c *Client) SendMessage(jidStr, text string) error {
+// checkOutgoing reports why an outgoing request can't be made right now
+func (c *Client) checkOutgoing() error {
+	if c.frozen.Load() {
+		return ErrFrozen
+	}
+
+	c.mu.RLock()
+	connected := c.connected
+	c.mu.RUnlock()
+
+	if !connected {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// SendResult describes an accepted send. Queued sends only carry the message
+// ID; the server timestamp arrives later with their outbox_sent event.
+type SendResult struct {
+	MessageID string `json:"message_id"`
+	Chat      string `json:"chat"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	ServerID  int    `json:"server_id,omitempty"`
+	Queued    bool   `json:"queued,omitempty"`
+}
+
+// sendResult starts delivery tracking of a sent message and describes it
+func (c *Client) sendResult(jid types.JID, resp whatsmeow.SendResponse) *SendResult {
+	c.delivery.track(jid, resp.ID, StatusServerAck, resp.Timestamp)
+	return &SendResult{
+		MessageID: resp.ID,
+		Chat:      jid.String(),
+		Timestamp: resp.Timestamp.Unix(),
+		ServerID:  resp.ServerID,
+	}
+}
+
+// sendRoute reports whether a send goes through the outbox and whether the
+// client is connected. c.mu is held only for the check: sends wait on the
+// rate limiter, pacing and the network, and holding it meanwhile would stall
+// every writer and, behind a waiting writer, event delivery.
+func (c *Client) sendRoute() (queue, connected bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.shouldQueue(), c.connected
+}
 
-	if !c.connected {
-		return fmt.Errorf("not connected")
+// SendMessage sends a text message to the specified JID, or queues it when
+// the offline queue applies. id pre-assigns the message ID (see
+// GenerateMessageID); empty generates one.
+func (c *Client) SendMessage(ctx context.Context, jidStr, text, id string) (*SendResult, error) {
+	if c.frozen.Load() {
+		return nil, ErrFrozen
 	}
 
 	// Parse JID
 	jid, err := types.ParseJID(jidStr)
 	if err != nil {
-		return fmt.Errorf("invalid JID: %w", err)
+		return nil, argErrorf("invalid JID: %w", err)
+	}
+
+	queue, connected := c.sendRoute()
+	if queue {
+		return c.queueOutgoing(&outboxItem{messageID: id, kind: outboxText, chat: jid, text: text})
 	}
+	if !connected {
+		return nil, ErrNotConnected
+	}
+
+	return c.sendText(ctx, jid, text, id)
+}
 
+// sendText delivers a text message; an empty id lets whatsmeow generate one
+func (c *Client) sendText(ctx context.Context, jid types.JID, text string, id types.MessageID) (*SendResult, error) {
 	// Create text message
 	msg := &waProto.Message{
 		ExtendedTextMessage: &waProto.ExtendedTextMessage{
@@ -165,39 +603,60 @@ func (c *Client) SendMessage(jidStr, text string) error {
 	}
 
 	// Send the message
-	_, err = c.client.SendMessage(c.ctx, jid, msg)
+	resp, err := c.sendMessage(ctx, jid, msg, whatsmeow.SendRequestExtra{ID: id})
 	if err != nil {
-		c.mu.RUnlock()
-		c.mu.Lock()
-		c.lastError = err.Error()
-		c.mu.Unlock()
-		c.mu.RLock()
-		return fmt.Errorf("send failed: %w", err)
+		return nil, fmt.Errorf("send failed: %w", err)
 	}
+	c.stats.countSent(messageType(msg))
+	c.archiveSent(jid, msg, resp)
 
-	return nil
+	return c.sendResult(jid, resp), nil
 }
 
-// SendImage sends an image message to the specified JID
-func (c *Client) SendImage(jidStr string, imageData []byte, mimeType, caption string) error {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	if !c.connected {
-		return fmt.Errorf("not connected")
+// SendImage sends an image message to the specified JID, or queues it when
+// the offline queue applies; id works as in SendMessage
+func (c *Client) SendImage(ctx context.Context, jidStr string, imageData []byte, mimeType, caption, id string) (*SendResult, error) {
+	if c.frozen.Load() {
+		return nil, ErrFrozen
 	}
 
 	// Parse JID
 	jid, err := types.ParseJID(jidStr)
 	if err != nil {
-		return fmt.Errorf("invalid JID: %w", err)
+		return nil, argErrorf("invalid JID: %w", err)
 	}
 
+	queue, connected := c.sendRoute()
+	if queue {
+		return c.queueOutgoing(&outboxItem{
+			messageID: id,
+			kind:      outboxImage,
+			chat:      jid,
+			text:      caption,
+			mimeType:  mimeType,
+			data:      imageData,
+		})
+	}
+	if !connected {
+		return nil, ErrNotConnected
+	}
+
+	return c.sendImage(ctx, jid, imageData, mimeType, caption, id)
+}
+
+// sendImage uploads and delivers an image; an empty id lets whatsmeow generate one
+func (c *Client) sendImage(ctx context.Context, jid types.JID, imageData []byte, mimeType, caption string, id types.MessageID) (*SendResult, error) {
 	// Upload the image to WhatsApp servers
-	uploaded, err := c.client.Upload(c.ctx, imageData, whatsmeow.MediaImage)
+	release, err := c.acquireMediaSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uploaded, err := c.client.Upload(ctx, imageData, whatsmeow.MediaImage)
+	release()
 	if err != nil {
-		return fmt.Errorf("upload failed: %w", err)
+		return nil, fmt.Errorf("upload failed: %w", err)
 	}
+	c.stats.uploaded.Add(uint64(len(imageData)))
 
 	// Create image message
 	msg := &waProto.Message{
@@ -218,12 +677,31 @@ func (c *Client) SendImage(jidStr string, imageData []byte, mimeType, caption st
 	}
 
 	// Send the message
-	_, err = c.client.SendMessage(c.ctx, jid, msg)
+	resp, err := c.sendMessage(ctx, jid, msg, whatsmeow.SendRequestExtra{ID: id})
 	if err != nil {
-		return fmt.Errorf("send failed: %w", err)
+		return nil, fmt.Errorf("send failed: %w", err)
 	}
+	c.stats.countSent(messageType(msg))
+	c.archiveSent(jid, msg, resp)
 
-	return nil
+	return c.sendResult(jid, resp), nil
+}
+
+// FrozenEvent is emitted when the client is frozen or unfrozen
+type FrozenEvent struct {
+	Frozen bool `json:"frozen" pb:"1"`
+}
+
+// Freeze suspends (or resumes) all outgoing traffic initiated through the
+// bridge while keeping the connection and incoming events alive. Protocol
+// acks that whatsmeow needs to keep receiving are still sent.
+func (c *Client) Freeze(on bool) {
+	if c.frozen.Swap(on) != on {
+		c.emit("frozen", FrozenEvent{Frozen: on})
+		if !on {
+			go c.flushOutbox()
+		}
+	}
 }
 
 // Disconnect closes the connection
@@ -231,22 +709,18 @@ func (c *Client) Disconnect() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.stopReconnect()
 	c.client.Disconnect()
 	c.connected = false
+	c.disconnects++
 }
 
 // Destroy cleans up all resources
 func (c *Client) Destroy() {
 	c.cancel()
+	c.stopEventCallback()
 	c.Disconnect()
 	if c.store != nil {
 		c.store.Close()
 	}
 }
-
-// LastError returns the last error message
-func (c *Client) LastError() string {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.lastError
-}
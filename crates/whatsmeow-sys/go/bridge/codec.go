@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// binaryEvent is the Event envelope for self-describing binary formats, with
+// the payload embedded natively instead of as nested JSON
+type binaryEvent struct {
+	Type      string      `json:"type"`
+	Seq       uint64      `json:"seq"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// cborMode mirrors encoding/json: json struct tags, TextMarshaler types
+// (such as JIDs) as strings and times as RFC 3339
+var cborMode = func() cbor.EncMode {
+	mode, err := cbor.EncOptions{
+		Time:          cbor.TimeRFC3339Nano,
+		TextMarshaler: cbor.TextMarshalerTextString,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+func init() {
+	// msgpack encodes TextMarshaler output as binary; keep JIDs as strings
+	msgpack.Register(types.JID{}, func(e *msgpack.Encoder, v reflect.Value) error {
+		return e.EncodeString(v.Interface().(types.JID).String())
+	}, nil)
+	// and its time extension type has no JSON counterpart; use RFC 3339 like
+	// encoding/json and CBOR
+	msgpack.Register(time.Time{}, func(e *msgpack.Encoder, v reflect.Value) error {
+		return e.EncodeString(v.Interface().(time.Time).Format(time.RFC3339Nano))
+	}, nil)
+}
+
+// NewMsgpackEvent wraps a payload in the MessagePack Event envelope
+func NewMsgpackEvent(eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	buf := getEventBuffer()
+	defer putEventBuffer(buf)
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	enc.Reset(buf)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(&binaryEvent{
+		Type:      eventType,
+		Seq:       seq,
+		Timestamp: timestamp,
+		Data:      payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// NewCBOREvent wraps a payload in the CBOR Event envelope
+func NewCBOREvent(eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	return cborMode.Marshal(&binaryEvent{
+		Type:      eventType,
+		Seq:       seq,
+		Timestamp: timestamp,
+		Data:      payload,
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ConnectFailureEvent is emitted when a connection attempt fails. Reason is
+// the server's failure code (e.g. 409 bad user agent) when the server
+// rejected the connection, or zero for local and network errors.
+type ConnectFailureEvent struct {
+	Message   string `json:"message" pb:"1"`
+	Retryable bool   `json:"retryable" pb:"2"`
+	Reason    int    `json:"reason,omitempty" pb:"3"`
+}
+
+// TemporaryBanEvent is emitted when the server rejects the connection because
+// the account is temporarily banned. ExpiresAt is zero if no expiry was given.
+// Automatic reconnection stops until the host calls Connect again, since
+// retrying during a ban can extend it.
+type TemporaryBanEvent struct {
+	Code      int    `json:"code" pb:"1"`
+	Message   string `json:"message" pb:"2"`
+	ExpiresAt int64  `json:"expires_at,omitempty" pb:"3"`
+	// ExpiresIn is the remaining ban in seconds, zero if unknown
+	ExpiresIn int64 `json:"expires_in,omitempty" pb:"4"`
+}
+
+// ClientOutdatedEvent is emitted when the server rejects the connection
+// because the WhatsApp Web version the bridge announces is too old. Unless
+// version refreshing is enabled, automatic reconnection stops until the host
+// calls Connect again; Reconnecting tells which.
+type ClientOutdatedEvent struct {
+	Reason  int    `json:"reason" pb:"1"`
+	Message string `json:"message" pb:"2"`
+	// Version is the rejected WhatsApp Web version
+	Version      string `json:"version" pb:"3"`
+	Reconnecting bool   `json:"reconnecting" pb:"4"`
+}
+
+// StreamReplacedEvent is emitted when another client connected with the same
+// session and the server closed this connection. Takeover reports whether
+// the bridge is reconnecting to take the stream back.
+type StreamReplacedEvent struct {
+	Takeover bool `json:"takeover" pb:"1"`
+}
+
+// SetStreamTakeover controls whether a replaced stream is reconnected
+// automatically. Two sessions that both take over will keep replacing each
+// other, so only enable it for the instance that should win.
+func (c *Client) SetStreamTakeover(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.StreamTakeover = enabled
+}
+
+// handleStreamReplaced reports a replaced stream and starts the takeover
+func (c *Client) handleStreamReplaced() *StreamReplacedEvent {
+	c.mu.RLock()
+	takeover := c.config.StreamTakeover && !c.config.Reconnect.Disabled
+	c.mu.RUnlock()
+
+	if takeover {
+		go c.startReconnect()
+	}
+	return &StreamReplacedEvent{Takeover: takeover}
+}
+
+func newConnectFailureEvent(evt *events.ConnectFailure) *ConnectFailureEvent {
+	message := evt.Message
+	if message == "" {
+		message = evt.Reason.String()
+	}
+	return &ConnectFailureEvent{
+		Message:   message,
+		Retryable: evt.Reason == events.ConnectFailureServiceUnavailable || evt.Reason == events.ConnectFailureInternalServerError,
+		Reason:    int(evt.Reason),
+	}
+}
+
+// handleTemporaryBan reports a ban and stops reconnecting
+func (c *Client) handleTemporaryBan(evt *events.TemporaryBan) *TemporaryBanEvent {
+	c.haltReconnect()
+	out := &TemporaryBanEvent{
+		Code:    int(evt.Code),
+		Message: evt.Code.String(),
+	}
+	if evt.Expire > 0 {
+		out.ExpiresAt = time.Now().Add(evt.Expire).Unix()
+		out.ExpiresIn = int64(evt.Expire.Seconds())
+	}
+	return out
+}
+
+// handleClientOutdated reports the rejected version and stops reconnecting
+// unless a refreshed version may fix it
+func (c *Client) handleClientOutdated() *ClientOutdatedEvent {
+	c.mu.RLock()
+	cfg := c.config.Version
+	c.mu.RUnlock()
+
+	version := c.waVersion()
+	if version.IsZero() {
+		version = store.GetWAVersion()
+	}
+	reconnecting := cfg.Fixed == "" && cfg.Refresh > 0
+	if !reconnecting {
+		c.haltReconnect()
+	}
+	reason := events.ConnectFailureClientOutdated
+	return &ClientOutdatedEvent{
+		Reason:       int(reason),
+		Message:      reason.String(),
+		Version:      version.String(),
+		Reconnecting: reconnecting,
+	}
+}
+
+// runConnect dials WhatsApp without holding c.mu, so polling, sends and
+// Disconnect stay responsive while the websocket and QR login are set up
+func (c *Client) runConnect() {
+	var err error
+	c.refreshVersion(false)
+	sealKeepAliveTiming()
+	if c.client.Store.ID == nil {
+		// Need QR code login
+		qrChan, _ := c.client.GetQRChannel(c.ctx)
+		err = c.client.Connect()
+		if err == nil {
+			// Forward QR codes to event queue
+			go c.watchQR(qrChan)
+		}
+	} else {
+		// Already logged in
+		err = c.client.Connect()
+	}
+
+	c.mu.Lock()
+	c.connecting = false
+	// A Disconnect or Destroy while dialing abandons the attempt, unless
+	// Connect was called again after the Disconnect
+	abandoned := c.ctx.Err() != nil || c.connectAfter != c.disconnects
+	if err == nil && !abandoned {
+		if c.client.IsConnected() {
+			c.connected = true
+		} else {
+			// The Disconnect closed the socket this attempt had just opened
+			err = ErrNotConnected
+		}
+	}
+	c.mu.Unlock()
+
+	if abandoned {
+		if err == nil {
+			c.client.Disconnect()
+		}
+		return
+	}
+	if err != nil {
+		detail := c.setError(WM_ERR_CONNECT, fmt.Errorf("connect failed: %w", err))
+		c.emit("connect_failure", ConnectFailureEvent{
+			Message:   detail.Message,
+			Retryable: detail.Retryable,
+		})
+	}
+}
+
+// IsConnected reports whether the websocket is currently connected, as seen
+// by whatsmeow (unlike the connected flag, this follows server-side drops)
+func (c *Client) IsConnected() bool {
+	return c.client.IsConnected()
+}
+
+// IsLoggedIn reports whether the client is connected and authenticated
+func (c *Client) IsLoggedIn() bool {
+	return c.client.IsLoggedIn()
+}
+
+// OwnJID identifies the logged-in account
+type OwnJID struct {
+	JID string `json:"jid"`
+	LID string `json:"lid,omitempty"`
+}
+
+// OwnJID returns the account JID and LID, or nil before pairing
+func (c *Client) OwnJID() *OwnJID {
+	id := c.client.Store.GetJID()
+	if id.IsEmpty() {
+		return nil
+	}
+
+	out := &OwnJID{JID: id.String()}
+	if lid := c.client.Store.GetLID(); !lid.IsEmpty() {
+		out.LID = lid.String()
+	}
+	return out
+}
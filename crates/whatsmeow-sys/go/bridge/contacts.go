@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ContactInfo is an address-book entry from the contact store
+type ContactInfo struct {
+	JID string `json:"jid" pb:"1"`
+	// FirstName and FullName come from the phone's address book
+	FirstName    string `json:"first_name,omitempty" pb:"2"`
+	FullName     string `json:"full_name,omitempty" pb:"3"`
+	PushName     string `json:"push_name,omitempty" pb:"4"`
+	BusinessName string `json:"business_name,omitempty" pb:"5"`
+}
+
+// ContactsSyncedEvent is emitted when address-book entries sync from the
+// phone: once per changed contact, and with every stored contact once a full
+// sync of the contact list finishes
+type ContactsSyncedEvent struct {
+	Contacts  []ContactInfo `json:"contacts" pb:"1"`
+	FullSync  bool          `json:"full_sync" pb:"2"`
+	Timestamp int64         `json:"timestamp" pb:"3"`
+}
+
+// UserAboutEvent is emitted when a contact changes their "about" text
+type UserAboutEvent struct {
+	JID       string `json:"jid" pb:"1"`
+	About     string `json:"about" pb:"2"`
+	Timestamp int64  `json:"timestamp" pb:"3"`
+}
+
+func newUserAboutEvent(evt *events.UserAbout) *UserAboutEvent {
+	return &UserAboutEvent{
+		JID:       evt.JID.ToNonAD().String(),
+		About:     evt.Status,
+		Timestamp: evt.Timestamp.Unix(),
+	}
+}
+
+func newContactInfo(jid types.JID, info types.ContactInfo) ContactInfo {
+	return ContactInfo{
+		JID:          jid.String(),
+		FirstName:    info.FirstName,
+		FullName:     info.FullName,
+		PushName:     info.PushName,
+		BusinessName: info.BusinessName,
+	}
+}
+
+// newContactsSyncedEvent describes one contact changed through app state;
+// whatsmeow has already written it to the contact store
+func (c *Client) newContactsSyncedEvent(evt *events.Contact) *ContactsSyncedEvent {
+	contact := ContactInfo{
+		JID:       evt.JID.String(),
+		FirstName: evt.Action.GetFirstName(),
+		FullName:  evt.Action.GetFullName(),
+	}
+	if info, err := c.client.Store.Contacts.GetContact(c.ctx, evt.JID); err == nil && info.Found {
+		contact = newContactInfo(evt.JID, info)
+	}
+	return &ContactsSyncedEvent{
+		Contacts:  []ContactInfo{contact},
+		FullSync:  evt.FromFullSync,
+		Timestamp: evt.Timestamp.Unix(),
+	}
+}
+
+// emitContactsSynced reports the whole contact store after a full sync of
+// the contact list, which whatsmeow mass-inserts without per-contact events
+func (c *Client) emitContactsSynced(evt *events.AppStateSyncComplete) {
+	if evt.Name != appstate.WAPatchCriticalUnblockLow {
+		return
+	}
+	contacts, err := c.Contacts(c.ctx)
+	if err != nil {
+		return
+	}
+	c.emit("contacts_synced", &ContactsSyncedEvent{Contacts: contacts, FullSync: true, Timestamp: time.Now().Unix()})
+}
+
+// Contacts returns every stored contact, sorted by JID
+func (c *Client) Contacts(ctx context.Context) ([]ContactInfo, error) {
+	all, err := c.client.Store.Contacts.GetAllContacts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contacts: %w", err)
+	}
+	contacts := make([]ContactInfo, 0, len(all))
+	for jid, info := range all {
+		contacts = append(contacts, newContactInfo(jid, info))
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].JID < contacts[j].JID })
+	return contacts, nil
+}
+
+// Contact returns one stored contact, or nil if the store doesn't know it
+func (c *Client) Contact(ctx context.Context, jidStr string) (*ContactInfo, error) {
+	jid, err := types.ParseJID(jidStr)
+	if err != nil {
+		return nil, argErrorf("invalid JID: %w", err)
+	}
+	info, err := c.client.Store.Contacts.GetContact(ctx, jid.ToNonAD())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contact: %w", err)
+	}
+	if !info.Found {
+		return nil, nil
+	}
+	contact := newContactInfo(jid.ToNonAD(), info)
+	return &contact, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// maxDeliveryTracked bounds how many sent messages keep a delivery status
+const maxDeliveryTracked = 4096
+
+// Delivery states of a sent message, in the order they are reached
+const (
+	StatusPending   = "pending"
+	StatusServerAck = "server_ack"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+	StatusPlayed    = "played"
+	// StatusFailed marks a queued send that was dropped after a permanent error
+	StatusFailed = "failed"
+)
+
+var statusRank = map[string]int{
+	StatusPending:   1,
+	StatusServerAck: 2,
+	StatusDelivered: 3,
+	StatusRead:      4,
+	StatusPlayed:    5,
+}
+
+// MessageStatus is the delivery state of a message sent through the bridge.
+// In groups, Status is the furthest state any recipient reached and
+// Recipients holds each recipient's own state.
+type MessageStatus struct {
+	MessageID  string            `json:"message_id"`
+	Chat       string            `json:"chat"`
+	Status     string            `json:"status"`
+	Timestamp  int64             `json:"timestamp"`
+	Recipients map[string]string `json:"recipients,omitempty"`
+}
+
+// deliveryTracker correlates recently sent message IDs with their receipts
+type deliveryTracker struct {
+	mu      sync.Mutex
+	entries map[types.MessageID]*MessageStatus
+	order   []types.MessageID
+}
+
+func newDeliveryTracker() *deliveryTracker {
+	return &deliveryTracker{entries: make(map[types.MessageID]*MessageStatus)}
+}
+
+// track starts (or advances) tracking of a sent message
+func (t *deliveryTracker) track(chat types.JID, id types.MessageID, status string, ts time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[id]
+	if !ok {
+		if len(t.order) >= maxDeliveryTracked {
+			delete(t.entries, t.order[0])
+			t.order = t.order[1:]
+		}
+		entry = &MessageStatus{MessageID: id, Chat: chat.String()}
+		t.entries[id] = entry
+		t.order = append(t.order, id)
+	}
+	if status == StatusFailed || statusRank[status] > statusRank[entry.Status] {
+		entry.Status = status
+		entry.Timestamp = ts.Unix()
+	}
+}
+
+// receipt applies a recipient's receipt to a tracked message; untracked IDs
+// (messages not sent through this bridge) are ignored
+func (t *deliveryTracker) receipt(id types.MessageID, recipient types.JID, group bool, status string, ts time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[id]
+	if !ok || entry.Status == StatusFailed {
+		return
+	}
+	if group {
+		if entry.Recipients == nil {
+			entry.Recipients = make(map[string]string)
+		}
+		jid := recipient.ToNonAD().String()
+		if statusRank[status] > statusRank[entry.Recipients[jid]] {
+			entry.Recipients[jid] = status
+		}
+	}
+	if statusRank[status] > statusRank[entry.Status] {
+		entry.Status = status
+		entry.Timestamp = ts.Unix()
+	}
+}
+
+// get returns a snapshot of a message's delivery status
+func (t *deliveryTracker) get(id types.MessageID) *MessageStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[id]
+	if !ok {
+		return nil
+	}
+	cp := *entry
+	if entry.Recipients != nil {
+		cp.Recipients = make(map[string]string, len(entry.Recipients))
+		for jid, status := range entry.Recipients {
+			cp.Recipients[jid] = status
+		}
+	}
+	return &cp
+}
+
+// receiptStatus maps a receipt from a recipient to a delivery state
+func receiptStatus(t types.ReceiptType) (string, bool) {
+	switch t {
+	case types.ReceiptTypeDelivered:
+		return StatusDelivered, true
+	case types.ReceiptTypeRead:
+		return StatusRead, true
+	case types.ReceiptTypePlayed:
+		return StatusPlayed, true
+	}
+	return "", false
+}
+
+// trackDelivery advances the status of sent messages from their receipts
+func (c *Client) trackDelivery(evt *events.Receipt) {
+	if evt.IsFromMe {
+		return
+	}
+	status, ok := receiptStatus(evt.Type)
+	if !ok {
+		return
+	}
+	for _, id := range evt.MessageIDs {
+		c.delivery.receipt(id, evt.Sender, evt.IsGroup, status, evt.Timestamp)
+	}
+}
+
+// MessageStatus returns the delivery status of a sent message, or nil if it
+// wasn't sent through this client or has aged out of the tracker
+func (c *Client) MessageStatus(messageID string) *MessageStatus {
+	return c.delivery.get(messageID)
+}
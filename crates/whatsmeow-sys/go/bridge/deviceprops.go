@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	waCompanionReg "go.mau.fi/whatsmeow/proto/waCompanionReg"
+	"go.mau.fi/whatsmeow/proto/waWa6"
+	"go.mau.fi/whatsmeow/store"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultDeviceName is shown in the phone's linked devices list
+const defaultDeviceName = "WhatsApp-RS"
+
+// HistorySyncConfig is the history sync preference announced when pairing.
+// Zero limits keep whatsmeow's defaults.
+type HistorySyncConfig struct {
+	// RequireFullSync asks for the complete history instead of recent chats
+	RequireFullSync bool
+	// FullSyncDays and FullSyncSizeMb cap the full sync by age and size
+	FullSyncDays   uint32
+	FullSyncSizeMb uint32
+	// RecentSyncDays caps the initial sync of recent chats
+	RecentSyncDays uint32
+	// StorageQuotaMb is the space the companion claims to have for history
+	StorageQuotaMb uint32
+}
+
+// apply writes the preference into the pairing device properties
+func (h HistorySyncConfig) apply(props *waCompanionReg.DeviceProps) {
+	if props.HistorySyncConfig == nil {
+		props.HistorySyncConfig = &waCompanionReg.DeviceProps_HistorySyncConfig{}
+	}
+	cfg := props.HistorySyncConfig
+	if h.FullSyncDays > 0 {
+		cfg.FullSyncDaysLimit = proto.Uint32(h.FullSyncDays)
+	}
+	if h.FullSyncSizeMb > 0 {
+		cfg.FullSyncSizeMbLimit = proto.Uint32(h.FullSyncSizeMb)
+	}
+	if h.RecentSyncDays > 0 {
+		cfg.RecentSyncDaysLimit = proto.Uint32(h.RecentSyncDays)
+	}
+	if h.StorageQuotaMb > 0 {
+		cfg.StorageQuotaMb = proto.Uint32(h.StorageQuotaMb)
+	}
+	props.RequireFullSync = proto.Bool(h.RequireFullSync)
+}
+
+// devicePropsMu guards the process-wide store.DeviceProps, which whatsmeow
+// reads while building the pairing payload
+var devicePropsMu sync.Mutex
+
+// parsePlatform maps a platform name such as "DESKTOP" or "CHROME" to its
+// DeviceProps value; empty means DESKTOP
+func parsePlatform(name string) (waCompanionReg.DeviceProps_PlatformType, error) {
+	if name == "" {
+		return waCompanionReg.DeviceProps_DESKTOP, nil
+	}
+	value, ok := waCompanionReg.DeviceProps_PlatformType_value[strings.ToUpper(name)]
+	if !ok {
+		return 0, argErrorf("unknown platform %q", name)
+	}
+	return waCompanionReg.DeviceProps_PlatformType(value), nil
+}
+
+// deviceProps builds this client's device properties on top of the global
+// defaults. Callers must hold devicePropsMu.
+func (c *Client) deviceProps() *waCompanionReg.DeviceProps {
+	c.mu.RLock()
+	name, platformName, lowBandwidth := c.config.DeviceName, c.config.Platform, c.config.LowBandwidth
+	history := c.config.HistorySync
+	c.mu.RUnlock()
+
+	props := proto.Clone(store.DeviceProps).(*waCompanionReg.DeviceProps)
+	if name == "" {
+		name = defaultDeviceName
+	}
+	// whatsmeow calls the device name "Os"
+	props.Os = proto.String(name)
+	// Validated when configured
+	platform, _ := parsePlatform(platformName)
+	props.PlatformType = platform.Enum()
+	history.apply(props)
+	if lowBandwidth {
+		applyLowBandwidthHistory(props)
+	}
+	return props
+}
+
+// clientPayload builds the handshake payload with this client's device
+// properties swapped into the global that whatsmeow reads, announcing this
+// client's WhatsApp Web version
+func (c *Client) clientPayload() *waWa6.ClientPayload {
+	devicePropsMu.Lock()
+	defer devicePropsMu.Unlock()
+
+	saved := store.DeviceProps
+	store.DeviceProps = c.deviceProps()
+	defer func() { store.DeviceProps = saved }()
+
+	payload := c.client.Store.GetClientPayload()
+	c.applyVersion(payload)
+	return payload
+}
+
+// SetDeviceProps changes the device name and platform announced when pairing;
+// they cannot change for an already paired session
+func (c *Client) SetDeviceProps(name, platform string) error {
+	if _, err := parsePlatform(platform); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.DeviceName = name
+	c.config.Platform = platform
+	return nil
+}
+
+// SetHistorySync changes the history sync preference announced when pairing;
+// the phone only honors it for new sessions
+func (c *Client) SetHistorySync(cfg HistorySyncConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.HistorySync = cfg
+}
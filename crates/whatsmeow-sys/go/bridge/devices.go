@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// deviceCheckInterval is how often the own device list is re-checked
+const deviceCheckInterval = 5 * time.Minute
+
+// CompanionDeviceEvent warns that a device was linked to the account
+type CompanionDeviceEvent struct {
+	JID       string `json:"jid" pb:"1"`
+	DeviceID  uint16 `json:"device_id" pb:"2"`
+	Platform  string `json:"platform" pb:"3"`
+	FirstSeen int64  `json:"first_seen" pb:"4"`
+}
+
+// devicePlatform describes what kind of device a device JID belongs to
+func devicePlatform(jid types.JID) string {
+	switch {
+	case jid.Device == 0:
+		return "phone"
+	case jid.Server == types.HostedServer:
+		return "hosted"
+	default:
+		return "companion"
+	}
+}
+
+// watchDevices re-checks the own device list periodically and after each connect
+func (c *Client) watchDevices() {
+	ticker := time.NewTicker(deviceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.devCheck:
+		}
+		c.checkCompanionDevices(c.ctx)
+	}
+}
+
+// triggerDeviceCheck schedules a device list check without blocking
+func (c *Client) triggerDeviceCheck() {
+	select {
+	case c.devCheck <- struct{}{}:
+	default:
+	}
+}
+
+// checkCompanionDevices compares the account's devices against the known set
+// and emits new_companion_device for every device not seen before. The first
+// check after pairing only records the baseline.
+func (c *Client) checkCompanionDevices(ctx context.Context) {
+	own := c.client.Store.ID
+	if own == nil || !c.client.IsLoggedIn() {
+		return
+	}
+	ownUser := own.ToNonAD()
+
+	devices, err := c.client.GetUserDevicesContext(ctx, []types.JID{ownUser})
+	if err != nil {
+		return
+	}
+
+	var known int
+	if err := c.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM wm_bridge_known_devices WHERE our_jid = ?", ownUser.String()).Scan(&known); err != nil {
+		return
+	}
+	baseline := known == 0
+
+	now := time.Now()
+	for _, dev := range devices {
+		if dev.User != ownUser.User || dev.Device == own.Device {
+			continue
+		}
+		res, err := c.db.ExecContext(ctx,
+			"INSERT OR IGNORE INTO wm_bridge_known_devices (our_jid, device_jid, first_seen) VALUES (?, ?, ?)",
+			ownUser.String(), dev.String(), now.Unix())
+		if err != nil {
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 || baseline {
+			continue
+		}
+		c.emit("new_companion_device", CompanionDeviceEvent{
+			JID:       dev.String(),
+			DeviceID:  dev.Device,
+			Platform:  devicePlatform(dev),
+			FirstSeen: now.Unix(),
+		})
+	}
+}
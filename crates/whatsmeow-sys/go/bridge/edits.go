@@ -0,0 +1,83 @@
+package main
+
+import (
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// MessageEditedEvent is emitted instead of a message event when a sender
+// edits one of their messages
+type MessageEditedEvent struct {
+	// MessageID is the edited (original) message, EditID the edit itself
+	MessageID string `json:"message_id" pb:"1"`
+	EditID    string `json:"edit_id" pb:"2"`
+	Chat      string `json:"chat" pb:"3"`
+	Sender    string `json:"sender" pb:"4"`
+	FromMe    bool   `json:"from_me" pb:"5"`
+	Timestamp int64  `json:"timestamp" pb:"6"`
+	// Type and Text describe the new content (see messageType)
+	Type string `json:"type" pb:"7"`
+	Text string `json:"text,omitempty" pb:"8"`
+}
+
+// MessageRevokedEvent is emitted instead of a message event when a message
+// is deleted for everyone
+type MessageRevokedEvent struct {
+	MessageID string `json:"message_id" pb:"1"`
+	Chat      string `json:"chat" pb:"2"`
+	// Sender revoked the message; OriginalSender wrote it, which differs
+	// when a group admin deleted someone else's message
+	Sender         string `json:"sender" pb:"3"`
+	OriginalSender string `json:"original_sender,omitempty" pb:"4"`
+	ByAdmin        bool   `json:"by_admin" pb:"5"`
+	FromMe         bool   `json:"from_me" pb:"6"`
+	Timestamp      int64  `json:"timestamp" pb:"7"`
+}
+
+// newMessageChangeEvent returns the edit or revoke event for a protocol
+// message that changes an earlier message, or nil for anything else
+func newMessageChangeEvent(evt *events.Message) interface{} {
+	pm := evt.Message.GetProtocolMessage()
+	key := pm.GetKey()
+	if pm == nil || pm.Type == nil || key.GetID() == "" {
+		return nil
+	}
+	sender := evt.Info.Sender.ToNonAD()
+
+	switch pm.GetType() {
+	case waProto.ProtocolMessage_MESSAGE_EDIT:
+		edited := pm.GetEditedMessage()
+		ts := evt.Info.Timestamp.Unix()
+		if ms := pm.GetTimestampMS(); ms > 0 {
+			ts = ms / 1000
+		}
+		return &MessageEditedEvent{
+			MessageID: key.GetID(),
+			EditID:    evt.Info.ID,
+			Chat:      evt.Info.Chat.String(),
+			Sender:    sender.String(),
+			FromMe:    evt.Info.IsFromMe,
+			Timestamp: ts,
+			Type:      messageType(edited),
+			Text:      messageText(edited),
+		}
+	case waProto.ProtocolMessage_REVOKE:
+		out := &MessageRevokedEvent{
+			MessageID: key.GetID(),
+			Chat:      evt.Info.Chat.String(),
+			Sender:    sender.String(),
+			FromMe:    evt.Info.IsFromMe,
+			Timestamp: evt.Info.Timestamp.Unix(),
+		}
+		if participant, err := types.ParseJID(key.GetParticipant()); err == nil && !participant.IsEmpty() {
+			out.OriginalSender = participant.ToNonAD().String()
+			// The key may use the other addressing mode (LID or phone)
+			out.ByAdmin = participant.User != sender.User && participant.User != evt.Info.SenderAlt.User
+		} else if !evt.Info.IsGroup {
+			out.OriginalSender = out.Sender
+		}
+		return out
+	}
+	return nil
+}
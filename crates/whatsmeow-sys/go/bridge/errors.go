@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/mattn/go-sqlite3"
+	"go.mau.fi/whatsmeow"
+)
+
+// Error categories reported in ErrorDetail
+const (
+	CategoryInvalidArgument = "invalid_argument"
+	CategoryInvalidHandle   = "invalid_handle"
+	CategoryNotConnected    = "not_connected"
+	CategoryNotLoggedIn     = "not_logged_in"
+	CategoryFrozen          = "frozen"
+	CategoryTimeout         = "timeout"
+	CategoryRateLimited     = "rate_limited"
+	CategoryRejected        = "rejected"
+	CategoryServer          = "server"
+	CategoryNetwork         = "network"
+	CategoryStore           = "store"
+	CategoryCanceled        = "canceled"
+	CategoryInternal        = "internal"
+)
+
+var (
+	// ErrNotConnected is returned by actions that need a connected client
+	ErrNotConnected = errors.New("not connected")
+	// ErrInvalidArgument matches errors caused by malformed call arguments
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// ErrorDetail describes the most recent failed call of a client
+type ErrorDetail struct {
+	Code       int    `json:"code"`
+	Category   string `json:"category"`
+	Message    string `json:"message"`
+	Retryable  bool   `json:"retryable"`
+	ServerCode int    `json:"server_code,omitempty"`
+	// Stack is the goroutine stack of a recovered panic (WM_ERR_PANIC)
+	Stack string `json:"stack,omitempty"`
+}
+
+// argumentError keeps the message of a validation failure while matching
+// ErrInvalidArgument
+type argumentError struct {
+	err error
+}
+
+func (e *argumentError) Error() string        { return e.err.Error() }
+func (e *argumentError) Unwrap() error        { return e.err }
+func (e *argumentError) Is(target error) bool { return target == ErrInvalidArgument }
+
+// argErrorf formats an error that is classified as an invalid argument
+func argErrorf(format string, args ...interface{}) error {
+	return &argumentError{fmt.Errorf(format, args...)}
+}
+
+// newErrorDetail classifies err for the result code it was reported with
+func newErrorDetail(code int, err error) ErrorDetail {
+	detail := ErrorDetail{Code: code, Category: CategoryInternal, Message: err.Error()}
+
+	var iqErr *whatsmeow.IQError
+	var disconnected *whatsmeow.DisconnectedError
+	var netErr net.Error
+	var sqlErr sqlite3.Error
+
+	switch {
+	case errors.Is(err, ErrInvalidArgument), errors.Is(err, ErrBackupInvalid):
+		detail.Category = CategoryInvalidArgument
+	case errors.Is(err, ErrFrozen):
+		detail.Category = CategoryFrozen
+		detail.Retryable = true
+	case errors.Is(err, ErrNotConnected), errors.Is(err, whatsmeow.ErrNotConnected), errors.As(err, &disconnected):
+		detail.Category = CategoryNotConnected
+		detail.Retryable = true
+	case errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		detail.Category = CategoryNotLoggedIn
+	case errors.Is(err, ErrKeyExportDisabled):
+		detail.Category = CategoryRejected
+	case errors.Is(err, ErrShuttingDown):
+		// Queued sends stay in the outbox for the next session
+		detail.Category = CategoryRejected
+		detail.Retryable = true
+	case errors.Is(err, ErrRateLimited):
+		detail.Category = CategoryRateLimited
+		detail.Retryable = true
+	case errors.Is(err, ErrSearchUnavailable):
+		detail.Category = CategoryStore
+	case errors.Is(err, whatsmeow.ErrIQTimedOut), errors.Is(err, whatsmeow.ErrMessageTimedOut), errors.Is(err, context.DeadlineExceeded):
+		detail.Category = CategoryTimeout
+		detail.Retryable = true
+	case errors.Is(err, context.Canceled):
+		detail.Category = CategoryCanceled
+	case errors.As(err, &iqErr):
+		detail.ServerCode = iqErr.Code
+		switch {
+		case iqErr.Code == 429:
+			detail.Category = CategoryRateLimited
+			detail.Retryable = true
+		case iqErr.Code >= 500:
+			detail.Category = CategoryServer
+			detail.Retryable = true
+		default:
+			detail.Category = CategoryRejected
+		}
+	case errors.Is(err, whatsmeow.ErrServerReturnedError), errors.Is(err, whatsmeow.ErrAppStateUpdate):
+		detail.Category = CategoryServer
+	case errors.As(err, &netErr):
+		detail.Category = CategoryNetwork
+		detail.Retryable = true
+	case errors.As(err, &sqlErr):
+		detail.Category = CategoryStore
+		detail.Retryable = sqlErr.Code == sqlite3.ErrBusy || sqlErr.Code == sqlite3.ErrLocked
+	}
+
+	return detail
+}
+
+// setError records a failed call; code is the result code returned to the host
+func (c *Client) setError(code int, err error) ErrorDetail {
+	detail := newErrorDetail(code, err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastError = detail
+	return detail
+}
+
+// ErrorDetail returns the most recent error, with a zero Code if none occurred
+func (c *Client) ErrorDetail() ErrorDetail {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastError
+}
+
+// LastError returns the last error message
+func (c *Client) LastError() string {
+	return c.ErrorDetail().Message
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// lentEvents keeps event buffers handed to the host pinned until it frees
+// them, keyed by the address of their first byte
+var lentEvents = struct {
+	sync.Mutex
+	pins map[uintptr]*runtime.Pinner
+}{pins: make(map[uintptr]*runtime.Pinner)}
+
+// lendEvent pins data so C may read it in place and returns its address
+func lendEvent(data []byte) unsafe.Pointer {
+	ptr := unsafe.Pointer(unsafe.SliceData(data))
+	pinner := new(runtime.Pinner)
+	pinner.Pin(ptr)
+
+	lentEvents.Lock()
+	defer lentEvents.Unlock()
+	lentEvents.pins[uintptr(ptr)] = pinner
+	return ptr
+}
+
+// releaseEvent unpins a buffer returned by lendEvent; unknown addresses are
+// ignored so a double free is harmless
+func releaseEvent(ptr unsafe.Pointer) {
+	lentEvents.Lock()
+	pinner, ok := lentEvents.pins[uintptr(ptr)]
+	delete(lentEvents.pins, uintptr(ptr))
+	lentEvents.Unlock()
+	if ok {
+		pinner.Unpin()
+	}
+}
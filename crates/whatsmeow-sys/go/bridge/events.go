@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"time"
+	"strconv"
+	"sync"
 
 	"go.mau.fi/whatsmeow/types/events"
 )
@@ -12,16 +14,26 @@ import (
 // Event wraps any WhatsMeow event with type information
 type Event struct {
 	Type      string          `json:"type"`
+	Seq       uint64          `json:"seq"`
 	Timestamp int64           `json:"timestamp"`
 	Data      json.RawMessage `json:"data"`
 }
 
-// MarshalEvent converts any WhatsMeow event to our unified JSON format
-// It marshals ALL fields from the original event struct
-func MarshalEvent(evt interface{}) ([]byte, error) {
+// Event encodings selectable per client
+const (
+	EventFormatJSON     = "json"
+	EventFormatProtobuf = "protobuf"
+	EventFormatMsgpack  = "msgpack"
+	EventFormatCBOR     = "cbor"
+)
+
+// normalizeEvent maps a WhatsMeow event to its event type and payload
+// The payload keeps ALL fields from the original event struct
+func normalizeEvent(evt interface{}) (string, interface{}) {
 	var eventType string
+	payload := evt
 
-	switch evt.(type) {
+	switch e := evt.(type) {
 	case *events.QR:
 		eventType = "qr"
 	case *events.PairSuccess:
@@ -32,22 +44,97 @@ func MarshalEvent(evt interface{}) ([]byte, error) {
 		eventType = "disconnected"
 	case *events.LoggedOut:
 		eventType = "logged_out"
-	case *events.Message:
+	case *StreamReplacedEvent:
+		eventType = "stream_replaced"
+	case *events.ConnectFailure:
+		eventType = "connect_failure"
+		payload = newConnectFailureEvent(e)
+	case *TemporaryBanEvent:
+		eventType = "temporary_ban"
+	case *ClientOutdatedEvent:
+		eventType = "client_outdated"
+	case *events.Message, *MessageEvent:
 		eventType = "message"
+	case *StatusUpdateEvent:
+		eventType = "status_update"
+	case *MessageEditedEvent:
+		eventType = "message_edited"
+	case *MessageRevokedEvent:
+		eventType = "message_revoked"
+	case *ReactionEvent:
+		eventType = "reaction"
+	case *PollCreatedEvent:
+		eventType = "poll_created"
+	case *PollVoteEvent:
+		eventType = "poll_vote"
+	case *ContactsSyncedEvent:
+		eventType = "contacts_synced"
+	case *events.UserAbout:
+		eventType = "user_about"
+		payload = newUserAboutEvent(e)
+	case *PictureUpdateEvent:
+		eventType = "picture_update"
 	case *events.Receipt:
 		eventType = "receipt"
+	case *events.IdentityChange:
+		eventType = "identity_change"
+		payload = newIdentityChangeEvent(e)
+	case *events.UndecryptableMessage:
+		eventType = "undecryptable"
+		payload = newUndecryptableEvent(e)
+	case *events.CallOffer:
+		eventType = "call_offer"
+		payload = newCallOfferEvent(e)
+	case *events.CallAccept:
+		eventType = "call_accept"
+		payload = newCallAcceptEvent(e)
+	case *events.CallOfferNotice:
+		eventType = "call_offer_notice"
+		payload = newCallOfferNoticeEvent(e)
+	case *events.CallTerminate:
+		eventType = "call_terminate"
+		payload = newCallTerminateEvent(e)
+	case *KeepAliveTimeoutEvent:
+		eventType = "keepalive_timeout"
+	case *KeepAliveRestoredEvent:
+		eventType = "keepalive_restored"
 	case *events.Presence:
 		eventType = "presence"
-	case *events.HistorySync:
+	case *HistorySyncEvent:
 		eventType = "history_sync"
 	case *events.PushNameSetting:
 		eventType = "push_name"
 	case *events.ChatPresence:
 		eventType = "chat_presence"
-	case *events.OfflineSyncPreview:
+	case *OfflineSyncPreviewEvent:
 		eventType = "offline_sync_preview"
-	case *events.OfflineSyncCompleted:
+	case *OfflineSyncCompletedEvent:
 		eventType = "offline_sync_completed"
+	case *events.Archive:
+		eventType = "archive"
+		payload = newChatArchiveEvent(e)
+	case *events.Pin:
+		eventType = "pin"
+		payload = newChatPinEvent(e)
+	case *events.Mute:
+		eventType = "mute"
+		payload = newChatMuteEvent(e)
+	case *ChatDeletedEvent:
+		eventType = "chat_deleted"
+	case *ChatClearedEvent:
+		eventType = "chat_cleared"
+	case *events.Star:
+		eventType = "star"
+		payload = newStarEvent(e)
+	case *events.LabelEdit:
+		eventType = "label_edit"
+		payload = newLabelEditEvent(e)
+	case *events.LabelAssociationChat:
+		eventType = "label_chat"
+		payload = newLabelChatEvent(e)
+	case *events.LabelAssociationMessage:
+		eventType = "label_message"
+		payload = newLabelMessageEvent(e)
 	default:
 		// Use reflection to get type name for unknown events
 		t := reflect.TypeOf(evt)
@@ -57,18 +144,93 @@ func MarshalEvent(evt interface{}) ([]byte, error) {
 		eventType = fmt.Sprintf("unknown_%s", t.Name())
 	}
 
-	event := Event{
-		Type:      eventType,
-		Timestamp: time.Now().UnixMilli(),
-		Data:      nil,
-	}
+	// The complete original event struct (or its normalized form)
+	return eventType, payload
+}
 
-	// Marshal the complete original event struct
-	rawData, err := json.Marshal(evt)
+// queuedEvent is an event waiting for the host. It is encoded when polled,
+// so events dropped from a full lane are never encoded at all.
+type queuedEvent struct {
+	eventType string
+	seq       uint64
+	// timestamp is when the event was emitted, in Unix milliseconds
+	timestamp int64
+	format    string
+	// compress gzips the encoded event, see compressEvent
+	compress bool
+	payload  interface{}
+}
+
+// encode wraps the payload in the envelope of the event's format
+func (e *queuedEvent) encode() ([]byte, error) {
+	data, err := encodeEvent(e.format, e.eventType, e.seq, e.timestamp, e.payload)
 	if err != nil {
 		return nil, err
 	}
-	event.Data = rawData
+	if e.compress {
+		data = compressEvent(data)
+	}
+	return data, nil
+}
+
+// encodeEvent wraps a payload in the envelope of the given format
+func encodeEvent(format, eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	switch format {
+	case EventFormatProtobuf:
+		return NewProtoEvent(eventType, seq, timestamp, payload)
+	case EventFormatMsgpack:
+		return NewMsgpackEvent(eventType, seq, timestamp, payload)
+	case EventFormatCBOR:
+		return NewCBOREvent(eventType, seq, timestamp, payload)
+	default:
+		return NewEvent(eventType, seq, timestamp, payload)
+	}
+}
+
+// maxPooledEventBuffer keeps the buffers of rare huge events, such as
+// history syncs, from staying pinned in the pool
+const maxPooledEventBuffer = 64 << 10
+
+// eventBuffers recycles the scratch buffers events are encoded into; only
+// the finished event is copied out, at its exact size
+var eventBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+func getEventBuffer() *bytes.Buffer {
+	buf := eventBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putEventBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledEventBuffer {
+		eventBuffers.Put(buf)
+	}
+}
+
+// NewEvent wraps a payload in the unified JSON format under the given type.
+// The Event envelope is written by hand so the payload is encoded once,
+// straight into a pooled buffer.
+func NewEvent(eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	buf := getEventBuffer()
+	defer putEventBuffer(buf)
+	enc := json.NewEncoder(buf)
+
+	buf.WriteString(`{"type":`)
+	if err := enc.Encode(eventType); err != nil {
+		return nil, err
+	}
+	// Encode terminates each value with a newline
+	buf.Truncate(buf.Len() - 1)
+	buf.WriteString(`,"seq":`)
+	buf.Write(strconv.AppendUint(buf.AvailableBuffer(), seq, 10))
+	buf.WriteString(`,"timestamp":`)
+	buf.Write(strconv.AppendInt(buf.AvailableBuffer(), timestamp, 10))
+	buf.WriteString(`,"data":`)
+	if err := enc.Encode(payload); err != nil {
+		return nil, err
+	}
+	buf.Truncate(buf.Len() - 1)
+	buf.WriteByte('}')
 
-	return json.Marshal(event)
+	return bytes.Clone(buf.Bytes()), nil
 }
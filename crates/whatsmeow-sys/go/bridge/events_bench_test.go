@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// benchReceipt is a read receipt, the most frequent event
+func benchReceipt() *events.Receipt {
+	chat := types.JID{User: "15551234567", Server: types.DefaultUserServer}
+	return &events.Receipt{
+		MessageSource: types.MessageSource{Chat: chat, Sender: chat},
+		MessageIDs:    []types.MessageID{"3EB0C767D26A1D8B2F5A"},
+		Timestamp:     time.Unix(1700000000, 0),
+		Type:          types.ReceiptTypeRead,
+	}
+}
+
+// unpooledEvent is NewEvent as it was before pooling: the payload is
+// marshaled, then marshaled again as the envelope's RawMessage
+func unpooledEvent(eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	rawData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Event{Type: eventType, Seq: seq, Timestamp: timestamp, Data: rawData})
+}
+
+// unpooledMsgpackEvent is NewMsgpackEvent before pooling
+func unpooledMsgpackEvent(eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	err := enc.Encode(&binaryEvent{Type: eventType, Seq: seq, Timestamp: timestamp, Data: payload})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unpooledProtoEvent is NewProtoEvent before pooling
+func unpooledProtoEvent(eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	b := protowire.AppendTag(nil, protoEventType, protowire.BytesType)
+	b = protowire.AppendString(b, eventType)
+	b = protowire.AppendTag(b, protoEventSeq, protowire.VarintType)
+	b = protowire.AppendVarint(b, seq)
+	b = protowire.AppendTag(b, protoEventTimestamp, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(timestamp))
+
+	if hasProtoSchema(reflect.TypeOf(payload)) {
+		msg, err := appendProtoMessage(nil, reflect.ValueOf(payload))
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, protoEventPayload, protowire.BytesType)
+		return protowire.AppendBytes(b, msg), nil
+	}
+
+	rawData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendTag(b, protoEventJSON, protowire.BytesType)
+	return protowire.AppendBytes(b, rawData), nil
+}
+
+// BenchmarkMarshalEvent encodes a receipt event in each format, with the
+// pooled encoders and with the unpooled ones they replaced
+func BenchmarkMarshalEvent(b *testing.B) {
+	encoders := []struct {
+		name   string
+		encode func(string, uint64, int64, interface{}) ([]byte, error)
+	}{
+		{"json/pooled", NewEvent},
+		{"json/unpooled", unpooledEvent},
+		{"msgpack/pooled", NewMsgpackEvent},
+		{"msgpack/unpooled", unpooledMsgpackEvent},
+		{"protobuf/pooled", NewProtoEvent},
+		{"protobuf/unpooled", unpooledProtoEvent},
+		{"cbor", NewCBOREvent},
+	}
+	receipt := benchReceipt()
+	for _, e := range encoders {
+		b.Run(e.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := e.encode("receipt", uint64(i), 1700000000000, receipt); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 /*
+#include <stdint.h>
 #include <stdlib.h>
 #include <string.h>
+
+typedef void (*wm_event_cb)(void *user_data, const char *data, int len);
 */
 import "C"
 
 import (
-	"sync"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -19,39 +26,84 @@ const (
 	WM_ERR_DISCONNECTED     = -3
 	WM_ERR_INVALID_HANDLE   = -4
 	WM_ERR_BUFFER_TOO_SMALL = -5
+	WM_ERR_FROZEN           = -6
+	WM_ERR_PANIC            = -7
 )
 
-// Global client registry
-var (
-	clientsMu sync.RWMutex
-	clients           = make(map[uintptr]*Client)
-	nextID    uintptr = 1
-)
-
+// wm_client_new creates a client; pass ":memory:" as dbPath for an ephemeral store
+//
 //export wm_client_new
-func wm_client_new(dbPath *C.char, deviceName *C.char) C.uintptr_t {
+func wm_client_new(dbPath *C.char, deviceName *C.char) (ret C.uintptr_t) {
+	defer catchPanicHandle(&ret)
 	config := ClientConfig{
 		DbPath:     C.GoString(dbPath),
 		DeviceName: C.GoString(deviceName),
 	}
 
-	client, err := NewClient(config)
+	return newClientHandle(config)
+}
+
+//export wm_client_new_encrypted
+func wm_client_new_encrypted(dbPath *C.char, deviceName *C.char, key *C.char) (ret C.uintptr_t) {
+	defer catchPanicHandle(&ret)
+	config := ClientConfig{
+		DbPath:        C.GoString(dbPath),
+		DeviceName:    C.GoString(deviceName),
+		EncryptionKey: C.GoString(key),
+	}
+
+	return newClientHandle(config)
+}
+
+// wm_client_new_with_options creates a client from a JSON ClientOptions
+// object; on failure it returns 0 and wm_get_call_error says what was wrong
+//
+//export wm_client_new_with_options
+func wm_client_new_with_options(optionsJSON *C.char) (ret C.uintptr_t) {
+	defer catchPanicHandle(&ret)
+	if optionsJSON == nil {
+		setCallError(newErrorDetail(WM_ERR_INIT, argErrorf("client options are required")))
+		return 0
+	}
+
+	client, err := NewClientWithOptions([]byte(C.GoString(optionsJSON)))
+	if err != nil {
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
+		return 0
+	}
+
+	handle, err := registerClient(client)
 	if err != nil {
+		client.Destroy()
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
 		return 0
 	}
 
-	clientsMu.Lock()
-	defer clientsMu.Unlock()
+	return C.uintptr_t(handle)
+}
+
+// newClientHandle creates a client and registers it, returning 0 on failure
+// with the error kept for wm_get_call_error
+func newClientHandle(config ClientConfig) C.uintptr_t {
+	client, err := NewClient(config)
+	if err != nil {
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
+		return 0
+	}
 
-	id := nextID
-	nextID++
-	clients[id] = client
+	handle, err := registerClient(client)
+	if err != nil {
+		client.Destroy()
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
+		return 0
+	}
 
-	return C.uintptr_t(id)
+	return C.uintptr_t(handle)
 }
 
 //export wm_client_connect
-func wm_client_connect(handle C.uintptr_t) C.int {
+func wm_client_connect(handle C.uintptr_t) (ret C.int) {
+	defer catchPanic(&ret)
 	client := getClient(uintptr(handle))
 	if client == nil {
 		return WM_ERR_INVALID_HANDLE
@@ -59,14 +111,15 @@ func wm_client_connect(handle C.uintptr_t) C.int {
 
 	err := client.Connect()
 	if err != nil {
-		return WM_ERR_CONNECT
+		return failCall(client, WM_ERR_CONNECT, err)
 	}
 
 	return WM_OK
 }
 
 //export wm_client_disconnect
-func wm_client_disconnect(handle C.uintptr_t) C.int {
+func wm_client_disconnect(handle C.uintptr_t) (ret C.int) {
+	defer catchPanic(&ret)
 	client := getClient(uintptr(handle))
 	if client == nil {
 		return WM_ERR_INVALID_HANDLE
@@ -76,19 +129,111 @@ func wm_client_disconnect(handle C.uintptr_t) C.int {
 	return WM_OK
 }
 
+//export wm_refresh_qr
+func wm_refresh_qr(handle C.uintptr_t) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if err := client.RefreshQR(); err != nil {
+		return failCall(client, WM_ERR_CONNECT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_qr_refresh
+func wm_set_qr_refresh(handle C.uintptr_t, limit C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if err := client.SetQRRefresh(int(limit)); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_offline_batch
+func wm_set_offline_batch(handle C.uintptr_t, size C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if err := client.SetOfflineBatch(int(size)); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
 //export wm_client_destroy
 func wm_client_destroy(handle C.uintptr_t) {
-	clientsMu.Lock()
-	defer clientsMu.Unlock()
-
-	if client, ok := clients[uintptr(handle)]; ok {
+	defer catchPanic(nil)
+	if client := unregisterClient(uintptr(handle)); client != nil {
 		client.Destroy()
-		delete(clients, uintptr(handle))
 	}
 }
 
+//export wm_client_is_connected
+func wm_client_is_connected(handle C.uintptr_t) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if client.IsConnected() {
+		return 1
+	}
+	return 0
+}
+
+//export wm_client_is_logged_in
+func wm_client_is_logged_in(handle C.uintptr_t) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if client.IsLoggedIn() {
+		return 1
+	}
+	return 0
+}
+
+//export wm_get_own_jid
+func wm_get_own_jid(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	own := client.OwnJID()
+	if own == nil {
+		return 0
+	}
+
+	data, err := json.Marshal(own)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
 //export wm_poll_event
-func wm_poll_event(handle C.uintptr_t, buf *C.char, bufLen C.int) C.int {
+func wm_poll_event(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
 	client := getClient(uintptr(handle))
 	if client == nil {
 		return WM_ERR_INVALID_HANDLE
@@ -99,32 +244,133 @@ func wm_poll_event(handle C.uintptr_t, buf *C.char, bufLen C.int) C.int {
 		return 0 // No event
 	}
 
-	if len(data) > int(bufLen) {
+	n := copyToBuffer(data, buf, bufLen)
+	if n == WM_ERR_BUFFER_TOO_SMALL {
+		// The event has already left the queue
+		client.dropped.Add(1)
+	}
+	return n
+}
+
+// wm_poll_events_batch drains up to maxEvents events into buf in one call,
+// each as a 4-byte big-endian length followed by the event, and returns the
+// bytes written (0 if none were queued). Events that don't fit stay queued;
+// if even the first doesn't, it returns WM_ERR_BUFFER_TOO_SMALL without
+// losing it.
+//
+//export wm_poll_events_batch
+func wm_poll_events_batch(handle C.uintptr_t, maxEvents C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	data, ok := client.PollEvents(int(maxEvents), int(bufLen))
+	if !ok {
 		return WM_ERR_BUFFER_TOO_SMALL
 	}
+	if len(data) == 0 {
+		return 0
+	}
+	return copyToBuffer(data, buf, bufLen)
+}
 
-	// Copy to buffer
-	C.memcpy(unsafe.Pointer(buf), unsafe.Pointer(&data[0]), C.size_t(len(data)))
-	return C.int(len(data))
+// wm_poll_event_ref polls like wm_poll_event, but instead of copying into a
+// host buffer it points data at the event held by the bridge, so no event is
+// ever too large. It returns 1 with an event, 0 without; the event stays
+// valid, even after the client is destroyed, until passed to wm_event_free.
+//
+//export wm_poll_event_ref
+func wm_poll_event_ref(handle C.uintptr_t, data **C.char, dataLen *C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	evt := client.PollEvent()
+	if evt == nil {
+		return 0
+	}
+	*data = (*C.char)(lendEvent(evt))
+	*dataLen = C.int(len(evt))
+	return 1
+}
+
+// wm_event_free releases an event returned by wm_poll_event_ref
+//
+//export wm_event_free
+func wm_event_free(data *C.char) {
+	defer catchPanic(nil)
+	releaseEvent(unsafe.Pointer(data))
+}
+
+//export wm_get_dropped_events
+func wm_get_dropped_events(handle C.uintptr_t) (ret C.longlong) {
+	defer catchPanicLong(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return C.longlong(WM_ERR_INVALID_HANDLE)
+	}
+
+	return C.longlong(client.DroppedEvents())
+}
+
+// wm_set_event_callback delivers events to cb instead of wm_poll_event; see
+// Client.SetEventCallback for the threading rules. A NULL cb unregisters it.
+//
+//export wm_set_event_callback
+func wm_set_event_callback(handle C.uintptr_t, cb C.wm_event_cb, userData unsafe.Pointer) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetEventCallback(cb, userData)
+	return WM_OK
 }
 
 //export wm_send_message
-func wm_send_message(handle C.uintptr_t, jid *C.char, text *C.char) C.int {
+func wm_send_message(handle C.uintptr_t, jid *C.char, text *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	return wm_send_message_with_id(handle, jid, text, nil)
+}
+
+//export wm_send_message_with_id
+func wm_send_message_with_id(handle C.uintptr_t, jid *C.char, text *C.char, messageID *C.char) (ret C.int) {
+	defer catchPanic(&ret)
 	client := getClient(uintptr(handle))
 	if client == nil {
 		return WM_ERR_INVALID_HANDLE
 	}
 
-	err := client.SendMessage(C.GoString(jid), C.GoString(text))
+	var id string
+	if messageID != nil {
+		id = C.GoString(messageID)
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	result, err := client.SendMessage(ctx, C.GoString(jid), C.GoString(text), id)
 	if err != nil {
-		return WM_ERR_CONNECT
+		return failOutgoing(client, err)
 	}
 
+	setSendResult(result)
 	return WM_OK
 }
 
 //export wm_send_image
-func wm_send_image(handle C.uintptr_t, jid *C.char, data *C.char, dataLen C.int, mimeType *C.char, caption *C.char) C.int {
+func wm_send_image(handle C.uintptr_t, jid *C.char, data *C.char, dataLen C.int, mimeType *C.char, caption *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	return wm_send_image_with_id(handle, jid, data, dataLen, mimeType, caption, nil)
+}
+
+//export wm_send_image_with_id
+func wm_send_image_with_id(handle C.uintptr_t, jid *C.char, data *C.char, dataLen C.int, mimeType *C.char, caption *C.char, messageID *C.char) (ret C.int) {
+	defer catchPanic(&ret)
 	client := getClient(uintptr(handle))
 	if client == nil {
 		return WM_ERR_INVALID_HANDLE
@@ -137,42 +383,1902 @@ func wm_send_image(handle C.uintptr_t, jid *C.char, data *C.char, dataLen C.int,
 	if caption != nil {
 		captionStr = C.GoString(caption)
 	}
+	var id string
+	if messageID != nil {
+		id = C.GoString(messageID)
+	}
 
-	err := client.SendImage(C.GoString(jid), imageData, C.GoString(mimeType), captionStr)
+	ctx, done := client.callContext()
+	defer done()
+	result, err := client.SendImage(ctx, C.GoString(jid), imageData, C.GoString(mimeType), captionStr, id)
 	if err != nil {
-		return WM_ERR_CONNECT
+		return failOutgoing(client, err)
 	}
 
+	setSendResult(result)
 	return WM_OK
 }
 
-//export wm_last_error
-func wm_last_error(handle C.uintptr_t, buf *C.char, bufLen C.int) C.int {
+// wm_send_message_async starts wm_send_message_with_id in the background and
+// returns a request ID (> 0) at once; the outcome arrives as a send_result
+// event carrying that ID
+//
+//export wm_send_message_async
+func wm_send_message_async(handle C.uintptr_t, jid *C.char, text *C.char, messageID *C.char) (ret C.longlong) {
+	defer catchPanicLong(&ret)
 	client := getClient(uintptr(handle))
 	if client == nil {
-		return 0
+		return C.longlong(WM_ERR_INVALID_HANDLE)
 	}
 
-	msg := client.LastError()
-	if msg == "" {
-		return 0
+	jidStr, textStr := C.GoString(jid), C.GoString(text)
+	var id string
+	if messageID != nil {
+		id = C.GoString(messageID)
 	}
 
-	if len(msg) > int(bufLen)-1 {
-		msg = msg[:bufLen-1]
+	ctx, done := client.callContext()
+	return C.longlong(client.sendAsync(ctx, done, func(ctx context.Context) (*SendResult, error) {
+		return client.SendMessage(ctx, jidStr, textStr, id)
+	}))
+}
+
+// wm_send_image_async is the asynchronous wm_send_image_with_id; the image
+// is copied before it returns
+//
+//export wm_send_image_async
+func wm_send_image_async(handle C.uintptr_t, jid *C.char, data *C.char, dataLen C.int, mimeType *C.char, caption *C.char, messageID *C.char) (ret C.longlong) {
+	defer catchPanicLong(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return C.longlong(WM_ERR_INVALID_HANDLE)
 	}
 
-	cstr := C.CString(msg)
-	defer C.free(unsafe.Pointer(cstr))
-	C.strcpy(buf, cstr)
+	jidStr, mimeStr := C.GoString(jid), C.GoString(mimeType)
+	imageData := C.GoBytes(unsafe.Pointer(data), dataLen)
+	var captionStr, id string
+	if caption != nil {
+		captionStr = C.GoString(caption)
+	}
+	if messageID != nil {
+		id = C.GoString(messageID)
+	}
 
-	return C.int(len(msg))
+	ctx, done := client.callContext()
+	return C.longlong(client.sendAsync(ctx, done, func(ctx context.Context) (*SendResult, error) {
+		return client.SendImage(ctx, jidStr, imageData, mimeStr, captionStr, id)
+	}))
 }
 
-func getClient(handle uintptr) *Client {
-	clientsMu.RLock()
-	defer clientsMu.RUnlock()
-	return clients[handle]
+//export wm_generate_message_id
+func wm_generate_message_id(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	return copyToBuffer([]byte(client.GenerateMessageID()), buf, bufLen)
+}
+
+//export wm_set_tls_config
+func wm_set_tls_config(handle C.uintptr_t, rootCAsPEM *C.char, pins *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	cfg := TLSConfig{}
+	if rootCAsPEM != nil {
+		cfg.RootCAsPEM = C.GoString(rootCAsPEM)
+	}
+	if pins != nil {
+		cfg.PinnedSPKI = splitList(C.GoString(pins))
+	}
+
+	if err := client.SetTLSConfig(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_tls_system_roots
+func wm_set_tls_system_roots(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if err := client.SetTLSSystemRoots(enabled != 0); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_media_config
+func wm_set_media_config(handle C.uintptr_t, proxyURL *C.char, timeoutMs C.int, maxParallel C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	cfg := MediaConfig{
+		Timeout:     time.Duration(timeoutMs) * time.Millisecond,
+		MaxParallel: int(maxParallel),
+	}
+	if proxyURL != nil {
+		cfg.ProxyURL = C.GoString(proxyURL)
+	}
+
+	if err := client.SetMediaConfig(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_dialer_config
+func wm_set_dialer_config(handle C.uintptr_t, forceIPv4 C.int, resolver *C.char, endpoints *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	cfg := DialerConfig{ForceIPv4: forceIPv4 != 0}
+	if resolver != nil {
+		cfg.Resolver = C.GoString(resolver)
+	}
+	if endpoints != nil {
+		// "host:port=addr:port" pairs separated by commas
+		cfg.Endpoints = make(map[string]string)
+		for _, pair := range splitList(C.GoString(endpoints)) {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok {
+				return failCall(client, WM_ERR_INIT, argErrorf("invalid endpoint override %q", pair))
+			}
+			cfg.Endpoints[strings.TrimSpace(from)] = strings.TrimSpace(to)
+		}
+	}
+
+	if err := client.SetDialerConfig(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_proxy
+func wm_set_proxy(handle C.uintptr_t, proxyURL *C.char, username *C.char, password *C.char, onlyLogin C.int, noMedia C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	cfg := ProxyConfig{OnlyLogin: onlyLogin != 0, NoMedia: noMedia != 0}
+	if proxyURL != nil {
+		cfg.URL = C.GoString(proxyURL)
+	}
+	if username != nil {
+		cfg.Username = C.GoString(username)
+	}
+	if password != nil {
+		cfg.Password = C.GoString(password)
+	}
+
+	if err := client.SetProxyConfig(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_websocket_config
+func wm_set_websocket_config(handle C.uintptr_t, wsURL *C.char, origin *C.char, dialTimeoutMs C.int, handshakeTimeoutMs C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	cfg := WebsocketConfig{
+		DialTimeout:      time.Duration(dialTimeoutMs) * time.Millisecond,
+		HandshakeTimeout: time.Duration(handshakeTimeoutMs) * time.Millisecond,
+	}
+	if wsURL != nil {
+		cfg.URL = C.GoString(wsURL)
+	}
+	if origin != nil {
+		cfg.Origin = C.GoString(origin)
+	}
+
+	if err := client.SetWebsocketConfig(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_auto_reconnect
+func wm_set_auto_reconnect(handle C.uintptr_t, enabled C.int, initialDelayMs C.int, maxDelayMs C.int, jitter C.double) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	err := client.SetReconnectConfig(ReconnectConfig{
+		Disabled:     enabled == 0,
+		InitialDelay: time.Duration(initialDelayMs) * time.Millisecond,
+		MaxDelay:     time.Duration(maxDelayMs) * time.Millisecond,
+		Jitter:       float64(jitter),
+	})
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_retry_config
+func wm_set_retry_config(handle C.uintptr_t, enabled C.int, maxRetries C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if err := client.SetRetryConfig(RetryConfig{Disabled: enabled == 0, MaxRetries: int(maxRetries)}); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+// wm_set_rate_limit paces outgoing messages: global and perChat are
+// messages per minute (0 = unlimited), burst how many may go out at once.
+// Sends over the limit wait for a token, or fail as rate_limited if reject
+// is non-zero.
+//
+//export wm_set_rate_limit
+func wm_set_rate_limit(handle C.uintptr_t, global C.int, perChat C.int, burst C.int, reject C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	cfg := RateLimitConfig{Global: int(global), PerChat: int(perChat), Burst: int(burst), Reject: reject != 0}
+	if err := client.SetRateLimit(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+// wm_set_human_pacing delays each message by a random time between minMs
+// and maxMs plus perCharMs per character (capped at maxMs), showing a typing
+// indicator meanwhile if typing is non-zero. A zero maxMs disables it.
+//
+//export wm_set_human_pacing
+func wm_set_human_pacing(handle C.uintptr_t, minMs C.int, maxMs C.int, perCharMs C.int, typing C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	cfg := HumanPacingConfig{
+		MinDelay: time.Duration(minMs) * time.Millisecond,
+		MaxDelay: time.Duration(maxMs) * time.Millisecond,
+		PerChar:  time.Duration(perCharMs) * time.Millisecond,
+		Typing:   typing != 0,
+	}
+	if err := client.SetHumanPacing(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_request_from_phone
+func wm_set_request_from_phone(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetRequestFromPhone(enabled != 0)
+	return WM_OK
+}
+
+//export wm_request_from_phone
+func wm_request_from_phone(handle C.uintptr_t, chat *C.char, sender *C.char, messageID *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	reqID, err := client.RequestFromPhone(ctx, C.GoString(chat), C.GoString(sender), C.GoString(messageID))
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return copyCallResult([]byte(reqID), buf, bufLen)
+}
+
+//export wm_set_keepalive
+func wm_set_keepalive(handle C.uintptr_t, maxFailMs C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	err := client.SetKeepAliveConfig(KeepAliveConfig{
+		MaxFailTime: time.Duration(maxFailMs) * time.Millisecond,
+	})
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+// wm_set_keepalive_timing sets the process-wide keepalive ping timing; it
+// must be called before any client connects
+//
+//export wm_set_keepalive_timing
+func wm_set_keepalive_timing(intervalMinMs C.int, intervalMaxMs C.int, responseTimeoutMs C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	err := SetKeepAliveTiming(KeepAliveTiming{
+		IntervalMin:     time.Duration(intervalMinMs) * time.Millisecond,
+		IntervalMax:     time.Duration(intervalMaxMs) * time.Millisecond,
+		ResponseTimeout: time.Duration(responseTimeoutMs) * time.Millisecond,
+	})
+	if err != nil {
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
+		return WM_ERR_INIT
+	}
+
+	return WM_OK
+}
+
+// wm_ping measures the round-trip time to the server with an IQ ping and
+// returns it in milliseconds; timeoutMs <= 0 means 10s
+//
+//export wm_ping
+func wm_ping(handle C.uintptr_t, timeoutMs C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	rtt, err := client.Ping(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	if err != nil {
+		return failCall(client, WM_ERR_CONNECT, err)
+	}
+
+	return C.int(rtt.Milliseconds())
+}
+
+//export wm_set_stream_takeover
+func wm_set_stream_takeover(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetStreamTakeover(enabled != 0)
+	return WM_OK
+}
+
+//export wm_set_offline_queue
+func wm_set_offline_queue(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetOfflineQueue(enabled != 0)
+	return WM_OK
+}
+
+//export wm_set_low_bandwidth
+func wm_set_low_bandwidth(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetLowBandwidth(enabled != 0)
+	return WM_OK
+}
+
+//export wm_store_maintain
+func wm_store_maintain(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	stats, err := client.Maintain(ctx)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	// Maintenance deletes rows and vacuums; a retry reads the kept report
+	return copyCallResult(data, buf, bufLen)
+}
+
+//export wm_keystore_summary
+func wm_keystore_summary(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	summary, err := client.KeystoreSummary(ctx)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_set_key_export
+func wm_set_key_export(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetKeyExport(enabled != 0)
+	return WM_OK
+}
+
+//export wm_export_app_state_keys
+func wm_export_app_state_keys(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	export, err := client.ExportAppStateKeys(ctx)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_set_prekey_threshold
+func wm_set_prekey_threshold(handle C.uintptr_t, threshold C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if err := client.SetPreKeyThreshold(int(threshold)); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_upload_prekeys
+func wm_upload_prekeys(handle C.uintptr_t) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	count, err := client.UploadPreKeys(ctx)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return C.int(count)
+}
+
+//export wm_get_security_code
+func wm_get_security_code(handle C.uintptr_t, jid *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	code, err := client.SecurityCode(ctx, C.GoString(jid))
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(code)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_set_message_archive
+func wm_set_message_archive(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetMessageArchive(enabled != 0)
+	return WM_OK
+}
+
+//export wm_archive_messages
+func wm_archive_messages(handle C.uintptr_t, chat *C.char, since C.longlong, until C.longlong, limit C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	query := ArchiveQuery{Since: int64(since), Until: int64(until), Limit: int(limit)}
+	if chat != nil {
+		query.Chat = C.GoString(chat)
+	}
+	ctx, done := client.callContext()
+	defer done()
+	msgs, err := client.ArchivedMessages(ctx, query)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_archive_message
+func wm_archive_message(handle C.uintptr_t, chat *C.char, messageID *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	msg, err := client.ArchivedMessage(ctx, C.GoString(chat), C.GoString(messageID))
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+	if msg == nil {
+		return 0
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_search_messages
+func wm_search_messages(handle C.uintptr_t, query *C.char, chat *C.char, limit C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var chatStr string
+	if chat != nil {
+		chatStr = C.GoString(chat)
+	}
+	ctx, done := client.callContext()
+	defer done()
+	results, err := client.SearchMessages(ctx, C.GoString(query), chatStr, int(limit))
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_export_chat
+func wm_export_chat(handle C.uintptr_t, chat *C.char, path *C.char, format *C.char, media C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var formatStr string
+	if format != nil {
+		formatStr = C.GoString(format)
+	}
+	ctx, done := client.callContext()
+	defer done()
+	result, err := client.ExportChat(ctx, C.GoString(chat), C.GoString(path), formatStr, media != 0)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyCallResult(data, buf, bufLen)
+}
+
+//export wm_backup
+func wm_backup(handle C.uintptr_t, path *C.char, passphrase *C.char, archive C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.Backup(ctx, C.GoString(path), C.GoString(passphrase), archive != 0); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+// wm_restore writes a backup to a store file before a client opens it
+//
+//export wm_restore
+func wm_restore(path *C.char, passphrase *C.char, dbPath *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	if err := RestoreBackup(C.GoString(path), C.GoString(passphrase), C.GoString(dbPath)); err != nil {
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
+		return WM_ERR_INIT
+	}
+
+	return WM_OK
+}
+
+//export wm_get_stats
+func wm_get_stats(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	data, err := json.Marshal(client.Stats())
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_poll_log
+func wm_poll_log(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	data := client.PollLog()
+	if data == nil {
+		return 0
+	}
+
+	n := copyToBuffer(data, buf, bufLen)
+	if n == WM_ERR_BUFFER_TOO_SMALL {
+		// Reported with the next line like an overflow
+		client.logs.dropped.Add(1)
+	}
+	return n
+}
+
+//export wm_set_log_level
+func wm_set_log_level(handle C.uintptr_t, level *C.char, module *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var levelStr, moduleStr string
+	if level != nil {
+		levelStr = C.GoString(level)
+	}
+	if module != nil {
+		moduleStr = C.GoString(module)
+	}
+	if err := client.SetLogLevel(levelStr, moduleStr); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+// wm_version writes the bridge, Go, whatsmeow and WhatsApp Web versions as JSON
+//
+//export wm_version
+func wm_version(buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	data, err := json.Marshal(Version())
+	if err != nil {
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
+		return WM_ERR_INIT
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+// wm_list_clients writes every live handle with its state as JSON; needs no
+// client
+//
+//export wm_list_clients
+func wm_list_clients(buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	data, err := json.Marshal(ListClients())
+	if err != nil {
+		setCallError(newErrorDetail(WM_ERR_INIT, err))
+		return WM_ERR_INIT
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+// wm_client_count returns how many handles are live
+//
+//export wm_client_count
+func wm_client_count() (ret C.int) {
+	defer catchPanic(&ret)
+	return C.int(ClientCount())
+}
+
+// wm_client_shutdown is a draining wm_client_destroy; see Client.Shutdown.
+// Keep polling from another thread while it runs. Returns how many events
+// were left over (and spilled to spill_path if given).
+//
+//export wm_client_shutdown
+func wm_client_shutdown(handle C.uintptr_t, timeoutMs C.int, spillPath *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var path string
+	if spillPath != nil {
+		path = C.GoString(spillPath)
+	}
+	// The handle stays valid while the host drains the queue
+	left, err := client.Shutdown(time.Duration(timeoutMs)*time.Millisecond, path)
+	unregisterClient(uintptr(handle))
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return C.int(left)
+}
+
+// wm_op_begin starts a cancellable operation and returns its ID (> 0). The
+// next blocking call on this client made on the same thread runs under it,
+// ending after timeoutMs (if positive) or when wm_cancel is called from any
+// thread.
+//
+//export wm_op_begin
+func wm_op_begin(handle C.uintptr_t, timeoutMs C.longlong) (ret C.longlong) {
+	defer catchPanicLong(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return C.longlong(WM_ERR_INVALID_HANDLE)
+	}
+
+	op := client.BeginOp(time.Duration(timeoutMs) * time.Millisecond)
+	setPendingOp(uintptr(handle), op)
+	return C.longlong(op)
+}
+
+// wm_cancel aborts an operation begun with wm_op_begin; the call running
+// under it fails with a "canceled" error. It returns 1 if the operation was
+// still running, 0 if it had already ended.
+//
+//export wm_cancel
+func wm_cancel(handle C.uintptr_t, opID C.longlong) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if client.CancelOp(int64(opID)) {
+		return 1
+	}
+	return 0
+}
+
+//export wm_get_contacts
+func wm_get_contacts(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	contacts, err := client.Contacts(ctx)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(contacts)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_get_contact
+func wm_get_contact(handle C.uintptr_t, jid *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	contact, err := client.Contact(ctx, C.GoString(jid))
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	data, err := json.Marshal(contact)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_register_push
+func wm_register_push(handle C.uintptr_t, platform *C.char, config *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.RegisterPush(ctx, C.GoString(platform), C.GoString(config)); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_get_played_by
+func wm_get_played_by(handle C.uintptr_t, messageID *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	played := client.PlayedBy(C.GoString(messageID))
+	if played == nil {
+		return 0
+	}
+
+	data, err := json.Marshal(played)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_get_message_status
+func wm_get_message_status(handle C.uintptr_t, messageID *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	status := client.MessageStatus(C.GoString(messageID))
+	if status == nil {
+		return 0
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_set_device_props
+func wm_set_device_props(handle C.uintptr_t, name *C.char, platform *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var nameStr, platformStr string
+	if name != nil {
+		nameStr = C.GoString(name)
+	}
+	if platform != nil {
+		platformStr = C.GoString(platform)
+	}
+
+	if err := client.SetDeviceProps(nameStr, platformStr); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_history_sync
+func wm_set_history_sync(handle C.uintptr_t, requireFull C.int, fullDays C.int, fullSizeMb C.int, recentDays C.int, quotaMb C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+	if fullDays < 0 || fullSizeMb < 0 || recentDays < 0 || quotaMb < 0 {
+		return failCall(client, WM_ERR_INIT, argErrorf("history sync limits must not be negative"))
+	}
+
+	client.SetHistorySync(HistorySyncConfig{
+		RequireFullSync: requireFull != 0,
+		FullSyncDays:    uint32(fullDays),
+		FullSyncSizeMb:  uint32(fullSizeMb),
+		RecentSyncDays:  uint32(recentDays),
+		StorageQuotaMb:  uint32(quotaMb),
+	})
+	return WM_OK
+}
+
+//export wm_set_wa_version
+func wm_set_wa_version(handle C.uintptr_t, version *C.char, refreshSecs C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var fixed string
+	if version != nil {
+		fixed = C.GoString(version)
+	}
+	cfg := VersionConfig{Fixed: fixed, Refresh: time.Duration(refreshSecs) * time.Second}
+	if err := client.SetVersionConfig(cfg); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_push_name
+func wm_set_push_name(handle C.uintptr_t, name *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.SetPushName(ctx, C.GoString(name))
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_archive_chat
+func wm_archive_chat(handle C.uintptr_t, chat *C.char, archive C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.ArchiveChat(ctx, C.GoString(chat), archive != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_pin_chat
+func wm_pin_chat(handle C.uintptr_t, chat *C.char, pin C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.PinChat(ctx, C.GoString(chat), pin != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_mute_chat
+func wm_mute_chat(handle C.uintptr_t, chat *C.char, durationSeconds C.longlong) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	// Zero or negative durations mute forever
+	var duration time.Duration
+	if durationSeconds > 0 {
+		duration = time.Duration(durationSeconds) * time.Second
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.MuteChat(ctx, C.GoString(chat), duration)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_unmute_chat
+func wm_unmute_chat(handle C.uintptr_t, chat *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.UnmuteChat(ctx, C.GoString(chat))
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_clear_chat
+func wm_clear_chat(handle C.uintptr_t, chat *C.char, keepStarred C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.ClearChat(ctx, C.GoString(chat), keepStarred != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_delete_chat
+func wm_delete_chat(handle C.uintptr_t, chat *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.DeleteChat(ctx, C.GoString(chat))
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_display_name_mode
+func wm_set_display_name_mode(handle C.uintptr_t, mode *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var modeStr string
+	if mode != nil {
+		modeStr = C.GoString(mode)
+	}
+
+	if err := client.SetDisplayNameMode(modeStr); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_event_format
+func wm_set_event_format(handle C.uintptr_t, format *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var formatStr string
+	if format != nil {
+		formatStr = C.GoString(format)
+	}
+
+	if err := client.SetEventFormat(formatStr); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_raw_messages
+func wm_set_raw_messages(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetRawMessages(enabled != 0)
+	return WM_OK
+}
+
+//export wm_star_message
+func wm_star_message(handle C.uintptr_t, chat *C.char, sender *C.char, messageID *C.char, fromMe C.int, starred C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var senderStr string
+	if sender != nil {
+		senderStr = C.GoString(sender)
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.StarMessage(ctx, C.GoString(chat), senderStr, C.GoString(messageID), fromMe != 0, starred != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_label_edit
+func wm_label_edit(handle C.uintptr_t, labelID *C.char, name *C.char, color C.int, deleted C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.EditLabel(ctx, C.GoString(labelID), C.GoString(name), int32(color), deleted != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_label_chat
+func wm_label_chat(handle C.uintptr_t, chat *C.char, labelID *C.char, labeled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.LabelChat(ctx, C.GoString(chat), C.GoString(labelID), labeled != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_label_message
+func wm_label_message(handle C.uintptr_t, chat *C.char, labelID *C.char, messageID *C.char, labeled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	err := client.LabelMessage(ctx, C.GoString(chat), C.GoString(labelID), C.GoString(messageID), labeled != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_group_invite_qr
+func wm_group_invite_qr(handle C.uintptr_t, group *C.char, size C.int, reset C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	png, err := client.GroupInviteQR(ctx, C.GoString(group), int(size), reset != 0)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	if reset != 0 {
+		// The old link is gone; a retry must not revoke the new one too
+		return copyCallResult(png, buf, bufLen)
+	}
+	return copyToBuffer(png, buf, bufLen)
+}
+
+//export wm_set_avatar_dir
+func wm_set_avatar_dir(handle C.uintptr_t, dir *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var dirStr string
+	if dir != nil {
+		dirStr = C.GoString(dir)
+	}
+	client.SetAvatarDir(dirStr)
+	return WM_OK
+}
+
+//export wm_group_set_member_add_mode
+func wm_group_set_member_add_mode(handle C.uintptr_t, group *C.char, adminOnly C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.SetGroupMemberAddMode(ctx, C.GoString(group), adminOnly != 0); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_post_text_status
+func wm_post_text_status(handle C.uintptr_t, text *C.char, background C.uint, textColor C.uint, font C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	result, err := client.PostTextStatus(ctx, TextStatus{
+		Text:       C.GoString(text),
+		Background: uint32(background),
+		TextColor:  uint32(textColor),
+		Font:       int32(font),
+	})
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	setSendResult(result)
+	return WM_OK
+}
+
+//export wm_post_media_status
+func wm_post_media_status(handle C.uintptr_t, data *C.char, dataLen C.int, mimeType *C.char, caption *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	media := C.GoBytes(unsafe.Pointer(data), dataLen)
+	var captionStr string
+	if caption != nil {
+		captionStr = C.GoString(caption)
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	result, err := client.PostMediaStatus(ctx, media, C.GoString(mimeType), captionStr)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	setSendResult(result)
+	return WM_OK
+}
+
+// wm_post_text_status_async is the asynchronous wm_post_text_status
+//
+//export wm_post_text_status_async
+func wm_post_text_status_async(handle C.uintptr_t, text *C.char, background C.uint, textColor C.uint, font C.int) (ret C.longlong) {
+	defer catchPanicLong(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return C.longlong(WM_ERR_INVALID_HANDLE)
+	}
+
+	status := TextStatus{
+		Text:       C.GoString(text),
+		Background: uint32(background),
+		TextColor:  uint32(textColor),
+		Font:       int32(font),
+	}
+
+	ctx, done := client.callContext()
+	return C.longlong(client.sendAsync(ctx, done, func(ctx context.Context) (*SendResult, error) {
+		return client.PostTextStatus(ctx, status)
+	}))
+}
+
+// wm_post_media_status_async is the asynchronous wm_post_media_status; the
+// media is copied before it returns
+//
+//export wm_post_media_status_async
+func wm_post_media_status_async(handle C.uintptr_t, data *C.char, dataLen C.int, mimeType *C.char, caption *C.char) (ret C.longlong) {
+	defer catchPanicLong(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return C.longlong(WM_ERR_INVALID_HANDLE)
+	}
+
+	media := C.GoBytes(unsafe.Pointer(data), dataLen)
+	mimeStr := C.GoString(mimeType)
+	var captionStr string
+	if caption != nil {
+		captionStr = C.GoString(caption)
+	}
+
+	ctx, done := client.callContext()
+	return C.longlong(client.sendAsync(ctx, done, func(ctx context.Context) (*SendResult, error) {
+		return client.PostMediaStatus(ctx, media, mimeStr, captionStr)
+	}))
+}
+
+//export wm_get_status_privacy
+func wm_get_status_privacy(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	lists, err := client.StatusPrivacy(ctx)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	data, err := json.Marshal(lists)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_set_status_privacy
+func wm_set_status_privacy(handle C.uintptr_t, listType *C.char, jids *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var list []string
+	if jids != nil {
+		list = splitList(C.GoString(jids))
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.SetStatusPrivacy(ctx, C.GoString(listType), list); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_mark_status_viewed
+func wm_mark_status_viewed(handle C.uintptr_t, sender *C.char, messageIDs *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.MarkStatusViewed(ctx, C.GoString(sender), splitList(C.GoString(messageIDs))); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+// wm_mark_read sends read receipts for a JSON array of {"chat", "sender",
+// "message_id"} objects (sender only for group messages), coalesced into one
+// receipt per chat and sender. It returns the number of receipts sent.
+//
+//export wm_mark_read
+func wm_mark_read(handle C.uintptr_t, marksJSON *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var marks []ReadMark
+	if err := json.Unmarshal([]byte(C.GoString(marksJSON)), &marks); err != nil {
+		return failCall(client, WM_ERR_INIT, argErrorf("invalid read marks: %w", err))
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	n, err := client.MarkRead(ctx, marks)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+	return C.int(n)
+}
+
+//export wm_reject_call
+func wm_reject_call(handle C.uintptr_t, callID *C.char, caller *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.RejectCall(ctx, C.GoString(callID), C.GoString(caller)); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_newsletter_follow
+func wm_newsletter_follow(handle C.uintptr_t, jid *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.FollowNewsletter(ctx, C.GoString(jid)); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_newsletter_unfollow
+func wm_newsletter_unfollow(handle C.uintptr_t, jid *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.UnfollowNewsletter(ctx, C.GoString(jid)); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_newsletter_info
+func wm_newsletter_info(handle C.uintptr_t, ref *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	info, err := client.NewsletterInfo(ctx, C.GoString(ref))
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_newsletter_create
+func wm_newsletter_create(handle C.uintptr_t, name *C.char, description *C.char, picture *C.char, pictureLen C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var desc string
+	if description != nil {
+		desc = C.GoString(description)
+	}
+	var pic []byte
+	if picture != nil && pictureLen > 0 {
+		pic = C.GoBytes(unsafe.Pointer(picture), pictureLen)
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	info, err := client.CreateNewsletter(ctx, C.GoString(name), desc, pic)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyCallResult(data, buf, bufLen)
+}
+
+//export wm_newsletter_update
+func wm_newsletter_update(handle C.uintptr_t, jid *C.char, name *C.char, description *C.char, picture *C.char, pictureLen C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var update NewsletterUpdate
+	if name != nil {
+		s := C.GoString(name)
+		update.Name = &s
+	}
+	if description != nil {
+		s := C.GoString(description)
+		update.Description = &s
+	}
+	if picture != nil {
+		update.Picture = C.GoBytes(unsafe.Pointer(picture), pictureLen)
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	info, err := client.UpdateNewsletter(ctx, C.GoString(jid), update)
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyCallResult(data, buf, bufLen)
+}
+
+// wm_newsletter_admin invites, revokes, accepts, demotes or transfers
+// ownership to a channel admin; user is unused for "accept"
+//
+//export wm_newsletter_admin
+func wm_newsletter_admin(handle C.uintptr_t, jid *C.char, action *C.char, user *C.char) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	var userStr string
+	if user != nil {
+		userStr = C.GoString(user)
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	if err := client.ManageNewsletterAdmin(ctx, C.GoString(jid), C.GoString(action), userStr); err != nil {
+		return failOutgoing(client, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_newsletter_messages
+func wm_newsletter_messages(handle C.uintptr_t, jid *C.char, count C.int, before C.int, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	ctx, done := client.callContext()
+	defer done()
+	msgs, err := client.NewsletterMessages(ctx, C.GoString(jid), int(count), int(before))
+	if err != nil {
+		return failOutgoing(client, err)
+	}
+
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_newsletter_subscribe
+func wm_newsletter_subscribe(handle C.uintptr_t, jid *C.char, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	if err := client.SubscribeNewsletter(C.GoString(jid), enabled != 0); err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return WM_OK
+}
+
+//export wm_set_normalize_reactions
+func wm_set_normalize_reactions(handle C.uintptr_t, enabled C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.SetNormalizeReactions(enabled != 0)
+	return WM_OK
+}
+
+//export wm_get_reactions
+func wm_get_reactions(handle C.uintptr_t, messageID *C.char, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	counts := client.Reactions(C.GoString(messageID))
+	if counts == nil {
+		return 0
+	}
+
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return failCall(client, WM_ERR_INIT, err)
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_freeze
+func wm_freeze(handle C.uintptr_t, on C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	client.Freeze(on != 0)
+	return WM_OK
+}
+
+//export wm_get_error_detail
+func wm_get_error_detail(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return WM_ERR_INVALID_HANDLE
+	}
+
+	detail := client.ErrorDetail()
+	if detail.Code == 0 {
+		return 0
+	}
+
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return WM_ERR_INIT
+	}
+
+	return copyToBuffer(data, buf, bufLen)
+}
+
+//export wm_last_error
+func wm_last_error(handle C.uintptr_t, buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	client := getClient(uintptr(handle))
+	if client == nil {
+		return 0
+	}
+
+	msg := client.LastError()
+	if msg == "" {
+		return 0
+	}
+	// A truncated message still needs room for the terminator
+	if bufLen <= 0 {
+		return WM_ERR_BUFFER_TOO_SMALL
+	}
+
+	if len(msg) > int(bufLen)-1 {
+		msg = msg[:bufLen-1]
+	}
+
+	cstr := C.CString(msg)
+	defer C.free(unsafe.Pointer(cstr))
+	C.strcpy(buf, cstr)
+
+	return C.int(len(msg))
+}
+
+// failCall records err as the client's and the calling thread's error detail
+// and returns code
+func failCall(client *Client, code C.int, err error) C.int {
+	setCallError(client.setError(int(code), err))
+	return code
+}
+
+// failOutgoing records an error from an outgoing action and returns its
+// result code
+func failOutgoing(client *Client, err error) C.int {
+	return failCall(client, C.int(outgoingCode(err)), err)
+}
+
+// outgoingCode is the result code of a failed outgoing action
+func outgoingCode(err error) int {
+	if errors.Is(err, ErrFrozen) {
+		return WM_ERR_FROZEN
+	}
+	return WM_ERR_CONNECT
+}
+
+// copyToBuffer copies data into a caller-provided buffer, returning its length
+func copyToBuffer(data []byte, buf *C.char, bufLen C.int) C.int {
+	if len(data) > int(bufLen) {
+		return WM_ERR_BUFFER_TOO_SMALL
+	}
+	if len(data) == 0 {
+		return 0
+	}
+
+	C.memcpy(unsafe.Pointer(buf), unsafe.Pointer(&data[0]), C.size_t(len(data)))
+	return C.int(len(data))
+}
+
+// splitList parses a comma-separated FFI string list, skipping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func getClient(handle uintptr) *Client {
+	client := lookupClient(handle)
+	if client == nil {
+		setCallError(ErrorDetail{
+			Code:     WM_ERR_INVALID_HANDLE,
+			Category: CategoryInvalidHandle,
+			Message:  "invalid client handle",
+		})
+	}
+	return client
 }
 
 func main() {} // Required for CGO build
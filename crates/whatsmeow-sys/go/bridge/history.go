@@ -0,0 +1,228 @@
+package main
+
+import (
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// HistoryMessage is a normalized message decoded from a history sync blob
+type HistoryMessage struct {
+	ID        string `json:"id" pb:"1"`
+	Sender    string `json:"sender" pb:"2"`
+	FromMe    bool   `json:"from_me" pb:"3"`
+	Timestamp int64  `json:"timestamp" pb:"4"`
+	PushName  string `json:"push_name,omitempty" pb:"5"`
+	Type      string `json:"type" pb:"6"`
+	Text      string `json:"text,omitempty" pb:"7"`
+}
+
+// HistoryParticipant is a group member listed in a history sync conversation
+type HistoryParticipant struct {
+	JID  string `json:"jid" pb:"1"`
+	Rank string `json:"rank" pb:"2"`
+}
+
+// History sync chunk bounds. A blob can hold tens of megabytes, so it is
+// streamed as chunks that stay small for any poll buffer.
+const (
+	historyChunkMessages = 500
+	historyChunkBytes    = 256 << 10
+)
+
+// HistoryConversation is a conversation, or part of one, in a history sync
+// chunk. A conversation too large for one chunk continues in the next ones.
+type HistoryConversation struct {
+	ChatJID             string               `json:"chat_jid" pb:"4"`
+	Name                string               `json:"name,omitempty" pb:"5"`
+	UnreadCount         uint32               `json:"unread_count" pb:"6"`
+	Archived            bool                 `json:"archived" pb:"7"`
+	Pinned              bool                 `json:"pinned" pb:"8"`
+	MuteEndTime         uint64               `json:"mute_end_time,omitempty" pb:"9"`
+	EphemeralExpiration uint32               `json:"ephemeral_expiration,omitempty" pb:"10"`
+	LastMessageTime     uint64               `json:"last_message_timestamp,omitempty" pb:"11"`
+	Participants        []HistoryParticipant `json:"participants,omitempty" pb:"12"`
+	Messages            []HistoryMessage     `json:"messages" pb:"13"`
+	// Continued marks a later part of a conversation split across chunks;
+	// only the first part lists the participants
+	Continued bool `json:"continued" pb:"14"`
+}
+
+// HistorySyncChunkEvent carries a bounded batch of conversations from a
+// history sync blob. Index counts the chunks of the blob from 0.
+type HistorySyncChunkEvent struct {
+	SyncType      string                `json:"sync_type" pb:"1"`
+	ChunkOrder    uint32                `json:"chunk_order" pb:"2"`
+	Progress      uint32                `json:"progress" pb:"3"`
+	Index         int                   `json:"index" pb:"4"`
+	Conversations []HistoryConversation `json:"conversations" pb:"5"`
+}
+
+// HistorySyncEvent is emitted after the last chunk of a history sync blob
+type HistorySyncEvent struct {
+	SyncType   string `json:"sync_type" pb:"1"`
+	ChunkOrder uint32 `json:"chunk_order" pb:"2"`
+	Progress   uint32 `json:"progress" pb:"3"`
+	// Chunks is how many history_sync_chunk events the blob was split into
+	Chunks        int `json:"chunks" pb:"4"`
+	Conversations int `json:"conversations" pb:"5"`
+	Messages      int `json:"messages" pb:"6"`
+}
+
+// historyChunker batches conversations into chunk events
+type historyChunker struct {
+	c       *Client
+	summary *HistorySyncEvent
+	chunk   *HistorySyncChunkEvent
+	size    int
+	count   int
+}
+
+func (h *historyChunker) reset() {
+	h.chunk = &HistorySyncChunkEvent{
+		SyncType:   h.summary.SyncType,
+		ChunkOrder: h.summary.ChunkOrder,
+		Progress:   h.summary.Progress,
+		Index:      h.summary.Chunks,
+	}
+	h.size, h.count = 0, 0
+}
+
+// flush emits the current chunk, if it holds anything, once the history lane
+// has room for it
+func (h *historyChunker) flush() {
+	if len(h.chunk.Conversations) == 0 {
+		return
+	}
+	if h.c.eventQueue.waitRoom(h.c.ctx, laneHistory) != nil {
+		// The client is being destroyed
+		return
+	}
+	h.c.emit("history_sync_chunk", h.chunk)
+	h.summary.Chunks++
+	h.reset()
+}
+
+// fits reports whether size more bytes and messages more messages fit into
+// the current chunk; an empty chunk takes anything
+func (h *historyChunker) fits(size, messages int) bool {
+	if len(h.chunk.Conversations) == 0 && h.count == 0 {
+		return true
+	}
+	return h.size+size <= historyChunkBytes && h.count+messages <= historyChunkMessages
+}
+
+// conversationSize estimates the encoded size of a conversation header
+func conversationSize(conv *HistoryConversation) int {
+	size := 256 + len(conv.ChatJID) + len(conv.Name)
+	for _, p := range conv.Participants {
+		size += 32 + len(p.JID) + len(p.Rank)
+	}
+	return size
+}
+
+// messageSize estimates the encoded size of a message
+func messageSize(msg *HistoryMessage) int {
+	return 96 + len(msg.ID) + len(msg.Sender) + len(msg.PushName) + len(msg.Type) + len(msg.Text)
+}
+
+// add appends a conversation, splitting its messages across chunks as needed
+func (h *historyChunker) add(conv HistoryConversation) {
+	messages := conv.Messages
+	part := conv
+	part.Messages = make([]HistoryMessage, 0, min(len(messages), historyChunkMessages))
+	if size := conversationSize(&part); !h.fits(size, 0) {
+		h.flush()
+	}
+	h.size += conversationSize(&part)
+
+	for _, msg := range messages {
+		size := messageSize(&msg)
+		if !h.fits(size, 1) {
+			if len(part.Messages) > 0 {
+				h.chunk.Conversations = append(h.chunk.Conversations, part)
+				h.flush()
+				part.Participants = nil
+				part.Continued = true
+				part.Messages = make([]HistoryMessage, 0, min(len(messages), historyChunkMessages))
+			} else {
+				h.flush()
+			}
+			h.size += conversationSize(&part)
+		}
+		part.Messages = append(part.Messages, msg)
+		h.size += size
+		h.count++
+	}
+	h.chunk.Conversations = append(h.chunk.Conversations, part)
+	h.summary.Conversations++
+	h.summary.Messages += len(messages)
+}
+
+// emitHistorySync streams a history sync blob as history_sync_chunk events
+// and returns the summary the history_sync event reports once they are queued
+func (c *Client) emitHistorySync(evt *events.HistorySync) *HistorySyncEvent {
+	data := evt.Data
+	summary := &HistorySyncEvent{
+		SyncType:   data.GetSyncType().String(),
+		ChunkOrder: data.GetChunkOrder(),
+		Progress:   data.GetProgress(),
+	}
+	if data == nil {
+		c.eventQueue.waitRoom(c.ctx, laneHistory)
+		return summary
+	}
+	h := &historyChunker{c: c, summary: summary}
+	h.reset()
+
+	for _, conv := range data.GetConversations() {
+		chatJID, err := types.ParseJID(conv.GetID())
+		if err != nil {
+			continue
+		}
+
+		out := HistoryConversation{
+			ChatJID:             chatJID.String(),
+			Name:                conv.GetName(),
+			UnreadCount:         conv.GetUnreadCount(),
+			Archived:            conv.GetArchived(),
+			Pinned:              conv.GetPinned() > 0,
+			MuteEndTime:         conv.GetMuteEndTime(),
+			EphemeralExpiration: conv.GetEphemeralExpiration(),
+			LastMessageTime:     conv.GetLastMsgTimestamp(),
+			Messages:            make([]HistoryMessage, 0, len(conv.GetMessages())),
+		}
+
+		for _, p := range conv.GetParticipant() {
+			out.Participants = append(out.Participants, HistoryParticipant{
+				JID:  p.GetUserJID(),
+				Rank: p.GetRank().String(),
+			})
+		}
+
+		for _, hm := range conv.GetMessages() {
+			if hm.GetMessage() == nil {
+				continue
+			}
+			msg, err := c.client.ParseWebMessage(chatJID, hm.GetMessage())
+			if err != nil {
+				continue
+			}
+			c.archive(msg.Info, msg.Message)
+			out.Messages = append(out.Messages, HistoryMessage{
+				ID:        msg.Info.ID,
+				Sender:    msg.Info.Sender.String(),
+				FromMe:    msg.Info.IsFromMe,
+				Timestamp: msg.Info.Timestamp.Unix(),
+				PushName:  msg.Info.PushName,
+				Type:      messageType(msg.Message),
+				Text:      messageText(msg.Message),
+			})
+		}
+
+		h.add(out)
+	}
+	h.flush()
+	// Room for the summary itself, queued by the caller
+	c.eventQueue.waitRoom(c.ctx, laneHistory)
+	return summary
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// historyConversation builds a conversation of n messages with text of
+// textLen bytes
+func historyConversation(chat string, n, textLen int) HistoryConversation {
+	conv := HistoryConversation{
+		ChatJID:      chat,
+		Participants: []HistoryParticipant{{JID: "15551111111@s.whatsapp.net", Rank: "ADMIN"}},
+	}
+	for i := 0; i < n; i++ {
+		conv.Messages = append(conv.Messages, HistoryMessage{
+			ID:   fmt.Sprintf("%s-%04d", chat, i),
+			Type: "text",
+			Text: strings.Repeat("x", textLen),
+		})
+	}
+	return conv
+}
+
+func TestHistoryChunker(t *testing.T) {
+	tests := []struct {
+		name          string
+		conversations []HistoryConversation
+		wantChunks    int
+	}{
+		{"empty", nil, 0},
+		{"one small", []HistoryConversation{historyConversation("a", 3, 10)}, 1},
+		{"no messages", []HistoryConversation{historyConversation("a", 0, 0)}, 1},
+		{"several small", []HistoryConversation{
+			historyConversation("a", 100, 10),
+			historyConversation("b", 100, 10),
+			historyConversation("c", 100, 10),
+		}, 1},
+		{"split by count", []HistoryConversation{historyConversation("a", 1200, 10)}, 3},
+		{"count spills over", []HistoryConversation{
+			historyConversation("a", 400, 10),
+			historyConversation("b", 400, 10),
+		}, 2},
+		{"split by size", []HistoryConversation{historyConversation("a", 200, 4096)}, 4},
+		{"oversized message", []HistoryConversation{historyConversation("a", 2, historyChunkBytes)}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(ClientConfig{DbPath: MemoryDbPath})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Destroy()
+
+			h := &historyChunker{c: c, summary: &HistorySyncEvent{SyncType: "RECENT"}}
+			h.reset()
+			wantMessages := 0
+			for _, conv := range tt.conversations {
+				h.add(conv)
+				wantMessages += len(conv.Messages)
+			}
+			h.flush()
+
+			if h.summary.Chunks != tt.wantChunks || h.summary.Conversations != len(tt.conversations) || h.summary.Messages != wantMessages {
+				t.Errorf("summary = %+v, want %d chunks, %d conversations and %d messages",
+					h.summary, tt.wantChunks, len(tt.conversations), wantMessages)
+			}
+
+			// Rejoin the parts in order, checking each chunk's bounds
+			var joined []HistoryConversation
+			for index := 0; ; index++ {
+				evt := c.eventQueue.pop()
+				if evt == nil {
+					if index != tt.wantChunks {
+						t.Errorf("got %d chunks, want %d", index, tt.wantChunks)
+					}
+					break
+				}
+				chunk := evt.payload.(*HistorySyncChunkEvent)
+				if evt.eventType != "history_sync_chunk" || chunk.Index != index || chunk.SyncType != "RECENT" {
+					t.Errorf("chunk %d is %s %+v", index, evt.eventType, chunk)
+				}
+				messages, size := 0, 0
+				for i, part := range chunk.Conversations {
+					size += conversationSize(&part)
+					for j := range part.Messages {
+						size += messageSize(&part.Messages[j])
+					}
+					messages += len(part.Messages)
+
+					continues := len(joined) > 0 && i == 0 && joined[len(joined)-1].ChatJID == part.ChatJID
+					if part.Continued != continues {
+						t.Errorf("chunk %d part %d: continued = %v, want %v", index, i, part.Continued, continues)
+					}
+					if continues {
+						if part.Participants != nil {
+							t.Errorf("chunk %d repeats the participants of %s", index, part.ChatJID)
+						}
+						joined[len(joined)-1].Messages = append(joined[len(joined)-1].Messages, part.Messages...)
+					} else {
+						joined = append(joined, part)
+					}
+				}
+				if messages > historyChunkMessages {
+					t.Errorf("chunk %d holds %d messages", index, messages)
+				}
+				if size > historyChunkBytes && messages > 1 {
+					t.Errorf("chunk %d holds about %d bytes", index, size)
+				}
+			}
+
+			if len(joined) != len(tt.conversations) {
+				t.Fatalf("rejoined %d conversations, want %d", len(joined), len(tt.conversations))
+			}
+			for i, conv := range tt.conversations {
+				got := joined[i]
+				if got.ChatJID != conv.ChatJID || len(got.Participants) != len(conv.Participants) || len(got.Messages) != len(conv.Messages) {
+					t.Errorf("conversation %d = %s with %d messages, want %s with %d", i, got.ChatJID, len(got.Messages), conv.ChatJID, len(conv.Messages))
+					continue
+				}
+				for j := range conv.Messages {
+					if got.Messages[j].ID != conv.Messages[j].ID {
+						t.Errorf("conversation %s message %d = %s, want %s", conv.ChatJID, j, got.Messages[j].ID, conv.Messages[j].ID)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/sha512"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.mau.fi/libsignal/ecc"
+	"go.mau.fi/libsignal/fingerprint"
+	"go.mau.fi/libsignal/serialize"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Signal numeric fingerprint parameters used by WhatsApp security codes
+const (
+	fingerprintIterations = 5200
+	fingerprintVersion    = 0
+	// scannableVersion is the CombinedFingerprints version of the QR payload
+	scannableVersion = 1
+)
+
+// IdentityChangeEvent is emitted when a contact's identity key (and so the
+// security code shown to users) changed. Implicit is set when this was only
+// noticed while decrypting a message rather than announced by the server.
+type IdentityChangeEvent struct {
+	JID       string `json:"jid" pb:"1"`
+	Timestamp int64  `json:"timestamp" pb:"2"`
+	Implicit  bool   `json:"implicit" pb:"3"`
+}
+
+func newIdentityChangeEvent(e *events.IdentityChange) *IdentityChangeEvent {
+	return &IdentityChangeEvent{
+		JID:       e.JID.ToNonAD().String(),
+		Timestamp: e.Timestamp.Unix(),
+		Implicit:  e.Implicit,
+	}
+}
+
+// SecurityCode is what the "Verify security code" screen shows for a contact
+type SecurityCode struct {
+	JID string `json:"jid"`
+	// Code is the 60-digit safety number; both sides compute the same one
+	Code string `json:"code"`
+	// QR is the serialized CombinedFingerprints to render as a QR code
+	QR []byte `json:"qr"`
+}
+
+// SecurityCode computes the security code for end-to-end encryption with a
+// contact. It needs a session with the contact, i.e. a message exchanged.
+func (c *Client) SecurityCode(ctx context.Context, jidStr string) (*SecurityCode, error) {
+	jid, err := types.ParseJID(jidStr)
+	if err != nil {
+		return nil, argErrorf("invalid JID: %w", err)
+	}
+	if jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer {
+		return nil, argErrorf("%s is not a user JID", jidStr)
+	}
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, ErrNotConnected
+	}
+
+	pn, remoteKey, err := c.contactIdentity(ctx, jid.ToNonAD())
+	if err != nil {
+		return nil, err
+	}
+
+	local := numericFingerprint(own.User, *c.client.Store.IdentityKey.Pub)
+	remote := numericFingerprint(pn.User, remoteKey)
+	qr, err := proto.Marshal(&serialize.CombinedFingerprints{
+		Version:           proto.Uint32(scannableVersion),
+		LocalFingerprint:  &serialize.LogicalFingerprint{Content: local[:32]},
+		RemoteFingerprint: &serialize.LogicalFingerprint{Content: remote[:32]},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecurityCode{
+		JID:  pn.String(),
+		Code: fingerprint.NewDisplay(local, remote).DisplayText(),
+		QR:   qr,
+	}, nil
+}
+
+// contactIdentity returns a contact's phone number JID and the identity key
+// of their primary device. Sessions may be stored under either the phone
+// number or the LID, so both addresses are tried.
+func (c *Client) contactIdentity(ctx context.Context, jid types.JID) (types.JID, [32]byte, error) {
+	var key [32]byte
+	pn, lid := jid, jid
+	var err error
+	if jid.Server == types.HiddenUserServer {
+		pn, err = c.client.Store.LIDs.GetPNForLID(ctx, jid)
+	} else {
+		lid, err = c.client.Store.LIDs.GetLIDForPN(ctx, jid)
+	}
+	if err != nil {
+		return pn, key, fmt.Errorf("failed to map contact JID: %w", err)
+	}
+	if pn.IsEmpty() {
+		return pn, key, argErrorf("no phone number known for %s", jid)
+	}
+
+	for _, addr := range []types.JID{pn, lid} {
+		if addr.IsEmpty() {
+			continue
+		}
+		var identity []byte
+		err := c.db.QueryRowContext(ctx,
+			"SELECT identity FROM whatsmeow_identity_keys WHERE our_jid = ? AND their_id = ?",
+			c.client.Store.ID.String(), addr.SignalAddress().String()).Scan(&identity)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return pn, key, fmt.Errorf("failed to read identity: %w", err)
+		}
+		copy(key[:], identity)
+		return pn, key, nil
+	}
+	return pn, key, argErrorf("no encryption session with %s yet", pn)
+}
+
+// numericFingerprint derives one side of a Signal numeric fingerprint
+func numericFingerprint(identifier string, key [32]byte) []byte {
+	pub := ecc.NewDjbECPublicKey(key).Serialize()
+	hash := append([]byte{0, fingerprintVersion}, pub...)
+	hash = append(hash, identifier...)
+	for i := 0; i < fingerprintIterations; i++ {
+		digest := sha512.New()
+		digest.Write(hash)
+		digest.Write(pub)
+		hash = digest.Sum(nil)
+	}
+	return hash
+}
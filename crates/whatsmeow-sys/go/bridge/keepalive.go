@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// defaultKeepAliveMaxFail mirrors whatsmeow.KeepAliveMaxFailTime
+const defaultKeepAliveMaxFail = 3 * time.Minute
+
+// defaultPingTimeout bounds Ping when no timeout is given
+const defaultPingTimeout = 10 * time.Second
+
+// ErrKeepAliveInUse is returned when keepalive timing is changed after a
+// client has connected
+var ErrKeepAliveInUse = errors.New("keepalive timing must be set before any client connects")
+
+// keepAliveTiming guards whatsmeow's keepalive globals. Every connected
+// client's keepalive loop reads them without synchronization, so they may
+// only change until the first client connects.
+var keepAliveTiming struct {
+	sync.Mutex
+	sealed bool
+}
+
+// KeepAliveTiming tunes the websocket keepalive pings of every client in the
+// process. whatsmeow only exposes these as package variables, so they are
+// set once at startup, before any client connects.
+type KeepAliveTiming struct {
+	// IntervalMin and IntervalMax bound the random wait between pings;
+	// zero leaves the current value (initially 20s to 30s)
+	IntervalMin time.Duration
+	IntervalMax time.Duration
+	// ResponseTimeout is how long a ping may go unanswered; zero leaves the
+	// current value (initially 10s)
+	ResponseTimeout time.Duration
+}
+
+// KeepAliveConfig controls how a client reacts to failing keepalive pings
+type KeepAliveConfig struct {
+	// MaxFailTime forces a reconnect once pings have failed for this long;
+	// zero means 3m. Has no effect while auto-reconnect is disabled.
+	MaxFailTime time.Duration
+}
+
+// KeepAliveTimeoutEvent is emitted each time a keepalive ping goes unanswered
+type KeepAliveTimeoutEvent struct {
+	ErrorCount  int   `json:"error_count" pb:"1"`
+	LastSuccess int64 `json:"last_success" pb:"2"`
+}
+
+// KeepAliveRestoredEvent is emitted when pings succeed again after timeouts
+type KeepAliveRestoredEvent struct {
+	Failures int `json:"failures" pb:"1"`
+}
+
+// SetKeepAliveTiming applies the process-wide ping timing; it fails with
+// ErrKeepAliveInUse once any client has connected
+func SetKeepAliveTiming(cfg KeepAliveTiming) error {
+	if cfg.IntervalMin < 0 || cfg.IntervalMax < 0 || cfg.ResponseTimeout < 0 {
+		return argErrorf("keepalive durations must not be negative")
+	}
+
+	keepAliveTiming.Lock()
+	defer keepAliveTiming.Unlock()
+	if keepAliveTiming.sealed {
+		return ErrKeepAliveInUse
+	}
+	minInterval, maxInterval := whatsmeow.KeepAliveIntervalMin, whatsmeow.KeepAliveIntervalMax
+	if cfg.IntervalMin > 0 {
+		minInterval = cfg.IntervalMin
+	}
+	if cfg.IntervalMax > 0 {
+		maxInterval = cfg.IntervalMax
+	}
+	if maxInterval <= minInterval {
+		return argErrorf("keepalive interval max %v must exceed min %v", maxInterval, minInterval)
+	}
+	whatsmeow.KeepAliveIntervalMin, whatsmeow.KeepAliveIntervalMax = minInterval, maxInterval
+	if cfg.ResponseTimeout > 0 {
+		whatsmeow.KeepAliveResponseDeadline = cfg.ResponseTimeout
+	}
+	return nil
+}
+
+// sealKeepAliveTiming freezes the keepalive globals before a client's
+// keepalive loop can start reading them
+func sealKeepAliveTiming() {
+	keepAliveTiming.Lock()
+	keepAliveTiming.sealed = true
+	keepAliveTiming.Unlock()
+}
+
+// SetKeepAliveConfig applies the client's keepalive failure handling
+func (c *Client) SetKeepAliveConfig(cfg KeepAliveConfig) error {
+	if cfg.MaxFailTime < 0 {
+		return argErrorf("keepalive durations must not be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.KeepAlive = cfg
+	return nil
+}
+
+// keepAliveMaxFail returns how long pings may fail before reconnecting
+func (c *Client) keepAliveMaxFail() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.config.KeepAlive.MaxFailTime > 0 {
+		return c.config.KeepAlive.MaxFailTime
+	}
+	return defaultKeepAliveMaxFail
+}
+
+func (c *Client) newKeepAliveTimeoutEvent(evt *events.KeepAliveTimeout) *KeepAliveTimeoutEvent {
+	c.kaFails.Store(int32(evt.ErrorCount))
+	return &KeepAliveTimeoutEvent{
+		ErrorCount:  evt.ErrorCount,
+		LastSuccess: evt.LastSuccess.Unix(),
+	}
+}
+
+func (c *Client) newKeepAliveRestoredEvent() *KeepAliveRestoredEvent {
+	return &KeepAliveRestoredEvent{Failures: int(c.kaFails.Swap(0))}
+}
+
+// Ping sends the server the IQ ping keepalives use and returns the
+// round-trip time. It fails once timeout (zero means 10s) passes unanswered.
+func (c *Client) Ping(ctx context.Context, timeout time.Duration) (time.Duration, error) {
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+
+	start := time.Now()
+	_, err := c.client.DangerousInternals().SendIQ(ctx, whatsmeow.DangerousInfoQuery{
+		Namespace: "w:p",
+		Type:      "get",
+		To:        types.ServerJID,
+		Timeout:   timeout,
+		// A ping answered after a reconnect says nothing about this one
+		NoRetry: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ping failed: %w", err)
+	}
+	return time.Since(start), nil
+}
+
+// checkKeepAlive forces a reconnect when pings have been failing for longer
+// than MaxFailTime. whatsmeow does this itself only with its own
+// auto-reconnect, which the bridge replaces.
+func (c *Client) checkKeepAlive(evt *events.KeepAliveTimeout) {
+	if time.Since(evt.LastSuccess) <= c.keepAliveMaxFail() || c.reconnectConfig().Disabled {
+		return
+	}
+	c.client.Disconnect()
+	c.startReconnect()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrKeyExportDisabled is returned by ExportAppStateKeys until key export is
+// explicitly enabled
+var ErrKeyExportDisabled = errors.New("app state key export is disabled")
+
+// KeystoreSummary counts the encryption state stored for the account
+type KeystoreSummary struct {
+	JID              string           `json:"jid"`
+	Sessions         int64            `json:"sessions"`
+	Identities       int64            `json:"identities"`
+	PreKeys          int64            `json:"pre_keys"`
+	UploadedPreKeys  int64            `json:"uploaded_pre_keys"`
+	SenderKeys       int64            `json:"sender_keys"`
+	AppStateKeys     int64            `json:"app_state_keys"`
+	AppStateVersions map[string]int64 `json:"app_state_versions"`
+}
+
+// AppStateKey is an app state sync key as shared by the primary phone
+type AppStateKey struct {
+	ID          string `json:"id"`
+	Data        []byte `json:"data"`
+	Fingerprint []byte `json:"fingerprint"`
+	Timestamp   int64  `json:"timestamp"`
+	Latest      bool   `json:"latest"`
+}
+
+// AppStateKeyExport is the result of ExportAppStateKeys
+type AppStateKeyExport struct {
+	Keys    []AppStateKey   `json:"keys"`
+	Summary KeystoreSummary `json:"summary"`
+}
+
+// SetKeyExport allows (or forbids) ExportAppStateKeys. The keys decrypt the
+// account's synced chat settings, so only enable it while debugging.
+func (c *Client) SetKeyExport(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.KeyExport = enabled
+}
+
+// KeystoreSummary reports how much encryption state is stored, without any
+// key material
+func (c *Client) KeystoreSummary(ctx context.Context) (*KeystoreSummary, error) {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, ErrNotConnected
+	}
+	jid := own.String()
+	summary := &KeystoreSummary{JID: jid, AppStateVersions: make(map[string]int64)}
+
+	counts := []struct {
+		dest  *int64
+		query string
+	}{
+		{&summary.Sessions, "SELECT COUNT(*) FROM whatsmeow_sessions WHERE our_jid = ?"},
+		{&summary.Identities, "SELECT COUNT(*) FROM whatsmeow_identity_keys WHERE our_jid = ?"},
+		{&summary.PreKeys, "SELECT COUNT(*) FROM whatsmeow_pre_keys WHERE jid = ?"},
+		{&summary.UploadedPreKeys, "SELECT COUNT(*) FROM whatsmeow_pre_keys WHERE jid = ? AND uploaded = true"},
+		{&summary.SenderKeys, "SELECT COUNT(*) FROM whatsmeow_sender_keys WHERE our_jid = ?"},
+		{&summary.AppStateKeys, "SELECT COUNT(*) FROM whatsmeow_app_state_sync_keys WHERE jid = ?"},
+	}
+	for _, count := range counts {
+		if err := c.db.QueryRowContext(ctx, count.query, jid).Scan(count.dest); err != nil {
+			return nil, fmt.Errorf("failed to read keystore: %w", err)
+		}
+	}
+
+	rows, err := c.db.QueryContext(ctx, "SELECT name, version FROM whatsmeow_app_state_version WHERE jid = ?", jid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app state versions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var version int64
+		if err := rows.Scan(&name, &version); err != nil {
+			return nil, fmt.Errorf("failed to read app state versions: %w", err)
+		}
+		summary.AppStateVersions[name] = version
+	}
+	return summary, rows.Err()
+}
+
+// ExportAppStateKeys returns all app state sync keys with the keystore
+// summary, for debugging sync between devices. Fails with
+// ErrKeyExportDisabled unless SetKeyExport enabled it.
+func (c *Client) ExportAppStateKeys(ctx context.Context) (*AppStateKeyExport, error) {
+	c.mu.RLock()
+	allowed := c.config.KeyExport
+	c.mu.RUnlock()
+	if !allowed {
+		return nil, ErrKeyExportDisabled
+	}
+
+	summary, err := c.KeystoreSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := c.client.Store.AppStateKeys.GetLatestAppStateSyncKeyID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read latest app state key: %w", err)
+	}
+
+	rows, err := c.db.QueryContext(ctx,
+		"SELECT key_id, key_data, fingerprint, timestamp FROM whatsmeow_app_state_sync_keys WHERE jid = ? ORDER BY timestamp",
+		summary.JID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app state keys: %w", err)
+	}
+	defer rows.Close()
+
+	export := &AppStateKeyExport{Keys: []AppStateKey{}, Summary: *summary}
+	for rows.Next() {
+		var id []byte
+		var key AppStateKey
+		if err := rows.Scan(&id, &key.Data, &key.Fingerprint, &key.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to read app state keys: %w", err)
+		}
+		key.ID = hex.EncodeToString(id)
+		key.Latest = hex.EncodeToString(latest) == key.ID
+		export.Keys = append(export.Keys, key)
+	}
+	return export, rows.Err()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// LabelEditEvent is emitted when a label is created, edited or deleted
+type LabelEditEvent struct {
+	LabelID      string `json:"label_id" pb:"1"`
+	Name         string `json:"name" pb:"2"`
+	Color        int32  `json:"color" pb:"3"`
+	Deleted      bool   `json:"deleted" pb:"4"`
+	Timestamp    int64  `json:"timestamp" pb:"5"`
+	FromFullSync bool   `json:"from_full_sync" pb:"6"`
+}
+
+// LabelAssociationEvent is emitted when a label is added to or removed from
+// a chat, or a message when MessageID is set
+type LabelAssociationEvent struct {
+	LabelID      string `json:"label_id" pb:"1"`
+	Chat         string `json:"chat" pb:"2"`
+	MessageID    string `json:"message_id,omitempty" pb:"3"`
+	Labeled      bool   `json:"labeled" pb:"4"`
+	Timestamp    int64  `json:"timestamp" pb:"5"`
+	FromFullSync bool   `json:"from_full_sync" pb:"6"`
+}
+
+func newLabelEditEvent(evt *events.LabelEdit) *LabelEditEvent {
+	return &LabelEditEvent{
+		LabelID:      evt.LabelID,
+		Name:         evt.Action.GetName(),
+		Color:        evt.Action.GetColor(),
+		Deleted:      evt.Action.GetDeleted(),
+		Timestamp:    evt.Timestamp.Unix(),
+		FromFullSync: evt.FromFullSync,
+	}
+}
+
+func newLabelChatEvent(evt *events.LabelAssociationChat) *LabelAssociationEvent {
+	return &LabelAssociationEvent{
+		LabelID:      evt.LabelID,
+		Chat:         evt.JID.String(),
+		Labeled:      evt.Action.GetLabeled(),
+		Timestamp:    evt.Timestamp.Unix(),
+		FromFullSync: evt.FromFullSync,
+	}
+}
+
+func newLabelMessageEvent(evt *events.LabelAssociationMessage) *LabelAssociationEvent {
+	return &LabelAssociationEvent{
+		LabelID:      evt.LabelID,
+		Chat:         evt.JID.String(),
+		MessageID:    evt.MessageID,
+		Labeled:      evt.Action.GetLabeled(),
+		Timestamp:    evt.Timestamp.Unix(),
+		FromFullSync: evt.FromFullSync,
+	}
+}
+
+// EditLabel creates or updates a label; a new label ID creates a new label
+func (c *Client) EditLabel(ctx context.Context, labelID, name string, color int32, deleted bool) error {
+	if labelID == "" {
+		return argErrorf("label ID is required")
+	}
+
+	return c.sendAppState(ctx, appstate.BuildLabelEdit(labelID, name, color, deleted))
+}
+
+// LabelChat adds or removes a label from a chat
+func (c *Client) LabelChat(ctx context.Context, chatStr, labelID string, labeled bool) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, appstate.BuildLabelChat(chat, labelID, labeled))
+}
+
+// LabelMessage adds or removes a label from a message
+func (c *Client) LabelMessage(ctx context.Context, chatStr, labelID, messageID string, labeled bool) error {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+
+	return c.sendAppState(ctx, appstate.BuildLabelMessage(chat, labelID, messageID, labeled))
+}
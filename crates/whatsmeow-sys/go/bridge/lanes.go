@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Event lanes, polled in this order. Each lane drops its oldest event when
+// full, so a flood of receipts or presence updates can only evict its own
+// kind. The control lane is sized so that it only fills when the host stops
+// polling altogether, and superseded control events are collapsed. The
+// history lane never drops: history sync waits for room instead, so backfill
+// is neither lost nor able to push out live events.
+const (
+	laneControl = iota
+	laneNormal
+	laneBulk
+	laneHistory
+	laneCount
+)
+
+// Lane capacities. ClientConfig.QueueSize scales the droppable lanes; the
+// control and history lanes keep their size.
+var laneCapacity = [laneCount]int{
+	laneControl: 256,
+	laneNormal:  1024,
+	laneBulk:    2048,
+	// Each chunk holds up to historyChunkBytes
+	laneHistory: 16,
+}
+
+// fixedLane reports whether a lane keeps its capacity regardless of
+// ClientConfig.QueueSize
+func fixedLane(lane int) bool {
+	return lane == laneControl || lane == laneHistory
+}
+
+// controlEvents change the connection or login state the host acts on
+var controlEvents = map[string]bool{
+	"qr":                 true,
+	"qr_timeout":         true,
+	"pair_success":       true,
+	"connected":          true,
+	"disconnected":       true,
+	"logged_out":         true,
+	"stream_replaced":    true,
+	"connect_failure":    true,
+	"temporary_ban":      true,
+	"client_outdated":    true,
+	"keepalive_timeout":  true,
+	"keepalive_restored": true,
+	"reconnect_attempt":  true,
+	"frozen":             true,
+}
+
+// supersededEvents are control events that make the queued ones of their
+// type stale: a new QR code replaces the old one, and only the latest
+// reconnect attempt and keepalive failure count matter
+var supersededEvents = map[string]bool{
+	"qr":                true,
+	"reconnect_attempt": true,
+	"keepalive_timeout": true,
+}
+
+// bulkEvents arrive in high volume and are cheap to lose
+var bulkEvents = map[string]bool{
+	"receipt":       true,
+	"presence":      true,
+	"chat_presence": true,
+}
+
+// historyEvents stream a history sync; the summary shares the chunks' lane
+// so that it still arrives after them
+var historyEvents = map[string]bool{
+	"history_sync_chunk": true,
+	"history_sync":       true,
+}
+
+// eventLane picks the lane of an event type
+func eventLane(eventType string) int {
+	switch {
+	case controlEvents[eventType]:
+		return laneControl
+	case bulkEvents[eventType]:
+		return laneBulk
+	case historyEvents[eventType]:
+		return laneHistory
+	default:
+		return laneNormal
+	}
+}
+
+// eventRing is a FIFO of events that drops the oldest at its capacity
+type eventRing struct {
+	buf  []*queuedEvent
+	head int
+	n    int
+	max  int
+}
+
+// push appends evt and reports whether the oldest event was dropped for it
+func (r *eventRing) push(evt *queuedEvent) bool {
+	dropped := false
+	if r.max > 0 && r.n == r.max {
+		r.pop()
+		dropped = true
+	}
+	if r.n == len(r.buf) {
+		grown := make([]*queuedEvent, max(16, 2*len(r.buf)))
+		for i := 0; i < r.n; i++ {
+			grown[i] = r.buf[(r.head+i)%len(r.buf)]
+		}
+		r.buf, r.head = grown, 0
+	}
+	r.buf[(r.head+r.n)%len(r.buf)] = evt
+	r.n++
+	return dropped
+}
+
+// removeType removes the events of a type, keeping the order of the rest,
+// and returns how many were removed
+func (r *eventRing) removeType(eventType string) int {
+	kept := 0
+	for i := 0; i < r.n; i++ {
+		evt := r.buf[(r.head+i)%len(r.buf)]
+		if evt.eventType != eventType {
+			r.buf[(r.head+kept)%len(r.buf)] = evt
+			kept++
+		}
+	}
+	for i := kept; i < r.n; i++ {
+		r.buf[(r.head+i)%len(r.buf)] = nil
+	}
+	removed := r.n - kept
+	r.n = kept
+	return removed
+}
+
+// pop removes the oldest event, or returns nil if the ring is empty
+func (r *eventRing) pop() *queuedEvent {
+	if r.n == 0 {
+		return nil
+	}
+	evt := r.buf[r.head]
+	r.buf[r.head] = nil
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+	return evt
+}
+
+// laneQueue holds the events waiting for the host, one ring per lane
+type laneQueue struct {
+	mu    sync.Mutex
+	lanes [laneCount]eventRing
+	// ready is signaled when an event is pushed, for the callback dispatcher
+	ready chan struct{}
+	// room is closed and replaced when a history event is popped
+	room chan struct{}
+}
+
+// newLaneQueue creates a queue whose droppable lanes hold size events
+// together, in the proportions of laneCapacity; zero keeps laneCapacity
+func newLaneQueue(size int) *laneQueue {
+	q := &laneQueue{ready: make(chan struct{}, 1), room: make(chan struct{})}
+	total := 0
+	for lane, c := range laneCapacity {
+		if !fixedLane(lane) {
+			total += c
+		}
+	}
+	for lane := range q.lanes {
+		q.lanes[lane].max = laneCapacity[lane]
+		if size > 0 && !fixedLane(lane) {
+			q.lanes[lane].max = max(1, size*laneCapacity[lane]/total)
+		}
+	}
+	return q
+}
+
+// push queues an event on a lane and returns how many older ones were
+// dropped for it, by overflow or as superseded
+func (q *laneQueue) push(lane int, evt *queuedEvent) int {
+	q.mu.Lock()
+	dropped := 0
+	if lane == laneControl && supersededEvents[evt.eventType] {
+		dropped = q.lanes[lane].removeType(evt.eventType)
+	}
+	if q.lanes[lane].push(evt) {
+		dropped++
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return dropped
+}
+
+// pop returns the next event of the most important non-empty lane, or nil
+func (q *laneQueue) pop() *queuedEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for lane := range q.lanes {
+		if evt := q.lanes[lane].pop(); evt != nil {
+			if lane == laneHistory {
+				close(q.room)
+				q.room = make(chan struct{})
+			}
+			return evt
+		}
+	}
+	return nil
+}
+
+// waitRoom blocks until a lane has room for another event or ctx ends. It
+// only guarantees the room to a lane's single producer, as for history sync.
+func (q *laneQueue) waitRoom(ctx context.Context, lane int) error {
+	for {
+		q.mu.Lock()
+		if q.lanes[lane].n < q.lanes[lane].max {
+			q.mu.Unlock()
+			return nil
+		}
+		room := q.room
+		q.mu.Unlock()
+
+		select {
+		case <-room:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// len counts the queued events of all lanes
+func (q *laneQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for lane := range q.lanes {
+		n += q.lanes[lane].n
+	}
+	return n
+}
+
+// capacity is the number of events the lanes hold
+func (q *laneQueue) capacity() int {
+	n := 0
+	for lane := range q.lanes {
+		n += q.lanes[lane].max
+	}
+	return n
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// ringTypes lists the event types left in a ring, oldest first
+func ringTypes(r *eventRing) []string {
+	var types []string
+	for evt := r.pop(); evt != nil; evt = r.pop() {
+		types = append(types, evt.eventType)
+	}
+	return types
+}
+
+func TestEventRing(t *testing.T) {
+	tests := []struct {
+		name        string
+		max         int
+		push        []string
+		wantDropped int
+		want        []string
+	}{
+		{"empty", 4, nil, 0, nil},
+		{"under capacity", 4, []string{"a", "b"}, 0, []string{"a", "b"}},
+		{"at capacity", 3, []string{"a", "b", "c"}, 0, []string{"a", "b", "c"}},
+		{"drops oldest", 3, []string{"a", "b", "c", "d", "e"}, 2, []string{"c", "d", "e"}},
+		{"capacity one", 1, []string{"a", "b", "c"}, 2, []string{"c"}},
+		{"unbounded grows", 0, []string{"a", "b", "c", "d", "e"}, 0, []string{"a", "b", "c", "d", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &eventRing{max: tt.max}
+			dropped := 0
+			for _, eventType := range tt.push {
+				if r.push(&queuedEvent{eventType: eventType}) {
+					dropped++
+				}
+			}
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped %d events, want %d", dropped, tt.wantDropped)
+			}
+			if got := ringTypes(r); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ring holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEventRingWraps checks the order once the head has moved past the
+// start of the buffer and the ring then grows
+func TestEventRingWraps(t *testing.T) {
+	r := &eventRing{}
+	for i := 0; i < 16; i++ {
+		r.push(&queuedEvent{seq: uint64(i)})
+	}
+	for i := 0; i < 10; i++ {
+		r.pop()
+	}
+	for i := 16; i < 40; i++ {
+		r.push(&queuedEvent{seq: uint64(i)})
+	}
+	for want := uint64(10); want < 40; want++ {
+		if evt := r.pop(); evt == nil || evt.seq != want {
+			t.Fatalf("popped %+v, want seq %d", evt, want)
+		}
+	}
+	if evt := r.pop(); evt != nil {
+		t.Errorf("popped %+v from an empty ring", evt)
+	}
+}
+
+func TestEventRingRemoveType(t *testing.T) {
+	tests := []struct {
+		name        string
+		push        []string
+		remove      string
+		wantRemoved int
+		want        []string
+	}{
+		{"none", []string{"a", "b"}, "qr", 0, []string{"a", "b"}},
+		{"all", []string{"qr", "qr"}, "qr", 2, nil},
+		{"keeps order", []string{"qr", "a", "qr", "b", "qr"}, "qr", 3, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &eventRing{}
+			// Move the head so that the ring wraps
+			for i := 0; i < 15; i++ {
+				r.push(&queuedEvent{})
+				r.pop()
+			}
+			for _, eventType := range tt.push {
+				r.push(&queuedEvent{eventType: eventType})
+			}
+			if removed := r.removeType(tt.remove); removed != tt.wantRemoved {
+				t.Errorf("removed %d events, want %d", removed, tt.wantRemoved)
+			}
+			if got := ringTypes(r); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ring holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventLane(t *testing.T) {
+	tests := []struct {
+		eventType string
+		want      int
+	}{
+		{"qr", laneControl},
+		{"disconnected", laneControl},
+		{"message", laneNormal},
+		{"receipt", laneBulk},
+		{"presence", laneBulk},
+		{"history_sync_chunk", laneHistory},
+		{"history_sync", laneHistory},
+	}
+	for _, tt := range tests {
+		if got := eventLane(tt.eventType); got != tt.want {
+			t.Errorf("eventLane(%q) = %d, want %d", tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestNewLaneQueue(t *testing.T) {
+	q := newLaneQueue(0)
+	for lane := range q.lanes {
+		if q.lanes[lane].max != laneCapacity[lane] {
+			t.Errorf("lane %d holds %d, want %d", lane, q.lanes[lane].max, laneCapacity[lane])
+		}
+	}
+
+	q = newLaneQueue(300)
+	if q.lanes[laneNormal].max != 100 || q.lanes[laneBulk].max != 200 {
+		t.Errorf("scaled lanes hold %d and %d, want 100 and 200", q.lanes[laneNormal].max, q.lanes[laneBulk].max)
+	}
+	for _, lane := range []int{laneControl, laneHistory} {
+		if q.lanes[lane].max != laneCapacity[lane] {
+			t.Errorf("fixed lane %d was scaled to %d", lane, q.lanes[lane].max)
+		}
+	}
+}
+
+// TestLaneQueue checks priority between lanes and the collapsing of
+// superseded control events
+func TestLaneQueue(t *testing.T) {
+	q := newLaneQueue(0)
+	pushes := []struct {
+		eventType   string
+		wantDropped int
+	}{
+		{"history_sync_chunk", 0},
+		{"receipt", 0},
+		{"message", 0},
+		{"qr", 0},
+		{"connected", 0},
+		{"qr", 1},
+		{"reconnect_attempt", 0},
+		{"reconnect_attempt", 1},
+		// Only superseded types collapse
+		{"connected", 0},
+	}
+	for _, p := range pushes {
+		if dropped := q.push(eventLane(p.eventType), &queuedEvent{eventType: p.eventType}); dropped != p.wantDropped {
+			t.Errorf("push %s dropped %d, want %d", p.eventType, dropped, p.wantDropped)
+		}
+	}
+	if n := q.len(); n != 7 {
+		t.Errorf("queue holds %d events, want 7", n)
+	}
+
+	var got []string
+	for evt := q.pop(); evt != nil; evt = q.pop() {
+		got = append(got, evt.eventType)
+	}
+	want := []string{"connected", "qr", "reconnect_attempt", "connected", "message", "receipt", "history_sync_chunk"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("popped %v, want %v", got, want)
+	}
+}
+
+// TestLaneQueueHistoryRoom checks that history sync waits for room in its
+// lane instead of dropping, and stops waiting when its context ends
+func TestLaneQueueHistoryRoom(t *testing.T) {
+	q := newLaneQueue(0)
+	for i := 0; i < laneCapacity[laneHistory]; i++ {
+		if err := q.waitRoom(context.Background(), laneHistory); err != nil {
+			t.Fatal(err)
+		}
+		q.push(laneHistory, &queuedEvent{eventType: "history_sync_chunk"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.waitRoom(ctx, laneHistory); err != context.DeadlineExceeded {
+		t.Fatalf("waitRoom on a full lane = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- q.waitRoom(context.Background(), laneHistory)
+	}()
+	// Live events don't make room in the history lane
+	q.push(laneNormal, &queuedEvent{eventType: "message"})
+	q.pop()
+	select {
+	case err := <-waited:
+		t.Fatalf("waitRoom returned %v after a normal event was polled", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	q.pop()
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitRoom still blocked after a history event was polled")
+	}
+}
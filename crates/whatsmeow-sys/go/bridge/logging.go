@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// Log levels, in increasing severity; LogOff only works as a minimum level
+const (
+	LogDebug = iota
+	LogInfo
+	LogWarn
+	LogError
+	LogOff
+)
+
+// logLevelNames maps log levels to the names used in log lines
+var logLevelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR", "OFF"}
+
+// parseLogLevel parses a level name, case-insensitively
+func parseLogLevel(name string) (int, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return 0, argErrorf("unknown log level %q", name)
+}
+
+// logQueueSize bounds the log lines kept until the host polls them
+const logQueueSize = 512
+
+// LogLine is a whatsmeow or bridge log line, polled with wm_poll_log
+type LogLine struct {
+	Level string `json:"level"`
+	// Module is the logger path, e.g. "Client/Socket" or "Database"
+	Module  string `json:"module"`
+	Message string `json:"message"`
+	// Time is when the line was logged (unix milliseconds)
+	Time int64 `json:"time"`
+	// Dropped counts lines lost to queue overflow just before this one
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// logSink queues log lines of one client for the host. Lines below the
+// minimum level of their module are discarded before they are formatted.
+type logSink struct {
+	queue   chan []byte
+	dropped atomic.Uint64
+	mu      sync.RWMutex
+	// levels maps module paths to minimum levels; "" is the default
+	levels map[string]int
+}
+
+func newLogSink() *logSink {
+	return &logSink{
+		queue:  make(chan []byte, logQueueSize),
+		levels: map[string]int{"": LogWarn},
+	}
+}
+
+// setLevel sets the minimum level of a module and its submodules, or the
+// default for an empty module
+func (s *logSink) setLevel(module string, level int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.levels[module] = level
+}
+
+// clearLevel makes a module follow its parent's level again
+func (s *logSink) clearLevel(module string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.levels, module)
+}
+
+// minLevel returns the level of the closest configured module: for
+// "Client/Socket" that is "Client/Socket", then "Client", then the default
+func (s *logSink) minLevel(module string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for {
+		if level, ok := s.levels[module]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(module, '/')
+		if i < 0 {
+			return s.levels[""]
+		}
+		module = module[:i]
+	}
+}
+
+// logger returns a waLog.Logger writing to the sink under module
+func (s *logSink) logger(module string) waLog.Logger {
+	return &sinkLogger{sink: s, module: module}
+}
+
+// write queues a log line, dropping the oldest when the queue is full
+func (s *logSink) write(level int, module, msg string, args []interface{}) {
+	if level < s.minLevel(module) {
+		return
+	}
+	line := LogLine{
+		Level:   logLevelNames[level],
+		Module:  module,
+		Message: fmt.Sprintf(msg, args...),
+		Time:    time.Now().UnixMilli(),
+		Dropped: s.dropped.Swap(0),
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	for {
+		select {
+		case s.queue <- data:
+			return
+		default:
+		}
+		select {
+		case <-s.queue:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// poll returns the next queued log line, or nil if there is none
+func (s *logSink) poll() []byte {
+	select {
+	case data := <-s.queue:
+		return data
+	default:
+		return nil
+	}
+}
+
+// sinkLogger is the waLog.Logger handed to whatsmeow
+type sinkLogger struct {
+	sink   *logSink
+	module string
+}
+
+func (l *sinkLogger) Debugf(msg string, args ...interface{}) {
+	l.sink.write(LogDebug, l.module, msg, args)
+}
+
+func (l *sinkLogger) Infof(msg string, args ...interface{}) {
+	l.sink.write(LogInfo, l.module, msg, args)
+}
+
+func (l *sinkLogger) Warnf(msg string, args ...interface{}) {
+	l.sink.write(LogWarn, l.module, msg, args)
+}
+
+func (l *sinkLogger) Errorf(msg string, args ...interface{}) {
+	l.sink.write(LogError, l.module, msg, args)
+}
+
+func (l *sinkLogger) Sub(module string) waLog.Logger {
+	return &sinkLogger{sink: l.sink, module: l.module + "/" + module}
+}
+
+// PollLog returns the next log line as JSON, or nil if none is queued. Only
+// warnings and errors are queued unless SetLogLevel lowers the level.
+func (c *Client) PollLog() []byte {
+	return c.logs.poll()
+}
+
+// SetLogLevel sets the minimum level ("DEBUG", "INFO", "WARN", "ERROR" or
+// "OFF") of a logger module such as "Client/Socket" and its submodules, or
+// the default for every module when module is empty. An empty level on a
+// module makes it follow its parent again.
+func (c *Client) SetLogLevel(levelName, module string) error {
+	module = strings.Trim(module, "/")
+	if levelName == "" {
+		if module == "" {
+			return argErrorf("log level is required")
+		}
+		c.logs.clearLevel(module)
+		return nil
+	}
+
+	level, err := parseLogLevel(levelName)
+	if err != nil {
+		return err
+	}
+	c.logs.setLevel(module, level)
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Display name fallback orders
+const (
+	// DisplayNameOff leaves DisplayName empty
+	DisplayNameOff = ""
+	// DisplayNamePushFirst uses push name, then contact name, then phone
+	DisplayNamePushFirst = "push_name"
+	// DisplayNameContactFirst uses contact name, then push name, then phone
+	DisplayNameContactFirst = "contact"
+)
+
+// MessageEvent is the message payload delivered to the host: the complete
+// whatsmeow event plus fields computed by the bridge
+type MessageEvent struct {
+	*events.Message
+	DisplayName string `json:"DisplayName,omitempty"`
+	// RawProto is the serialized waE2E.Message as received (before unwrapping),
+	// set only when raw message passthrough is enabled
+	RawProto []byte `json:"RawProto,omitempty"`
+	// ExpirationSeconds is the disappearing timer of an ephemeral message
+	ExpirationSeconds uint32 `json:"ExpirationSeconds,omitempty"`
+	// QuotedMessageID, QuotedSender and QuotedText describe the message this
+	// one replies to; QuotedType is its messageType, so quoted media without
+	// a caption still shows what it was
+	QuotedMessageID string `json:"QuotedMessageID,omitempty"`
+	QuotedSender    string `json:"QuotedSender,omitempty"`
+	QuotedText      string `json:"QuotedText,omitempty"`
+	QuotedType      string `json:"QuotedType,omitempty"`
+}
+
+// newMessageEvent wraps a whatsmeow message with bridge-computed fields
+func (c *Client) newMessageEvent(evt *events.Message) *MessageEvent {
+	ctx := contextInfo(evt.Message)
+	// Disappearing and view-once content isn't always wrapped: newer clients
+	// only set the expiration in ContextInfo or the viewOnce flag of media.
+	// The flags are set on a copy, the event is shared with other handlers.
+	expiration := ctx.GetExpiration()
+	viewOnce := viewOnceMedia(evt.Message)
+	if (expiration > 0 && !evt.IsEphemeral) || (viewOnce && !evt.IsViewOnce) {
+		flagged := *evt
+		flagged.IsEphemeral = evt.IsEphemeral || expiration > 0
+		flagged.IsViewOnce = evt.IsViewOnce || viewOnce
+		evt = &flagged
+	}
+
+	out := &MessageEvent{
+		Message:           evt,
+		DisplayName:       c.displayName(evt.Info),
+		ExpirationSeconds: expiration,
+	}
+	if ctx.GetStanzaID() != "" {
+		out.QuotedMessageID = ctx.GetStanzaID()
+		out.QuotedSender = ctx.GetParticipant()
+		if quoted := ctx.GetQuotedMessage(); quoted != nil {
+			out.QuotedText = messageText(quoted)
+			out.QuotedType = messageType(quoted)
+		}
+	}
+	if c.rawMessages() {
+		raw := evt.RawMessage
+		if raw == nil {
+			raw = evt.Message
+		}
+		if data, err := proto.Marshal(raw); err == nil {
+			out.RawProto = data
+		}
+	}
+	return out
+}
+
+// contextInfo returns the ContextInfo of whichever content a message carries
+func contextInfo(msg *waProto.Message) *waProto.ContextInfo {
+	if msg == nil {
+		return nil
+	}
+	var info *waProto.ContextInfo
+	msg.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return true
+		}
+		content := v.Message()
+		field := content.Descriptor().Fields().ByName("contextInfo")
+		if field == nil || !content.Has(field) {
+			return true
+		}
+		info, _ = content.Get(field).Message().Interface().(*waProto.ContextInfo)
+		return info == nil
+	})
+	return info
+}
+
+// viewOnceMedia reports whether media is flagged view-once without the
+// ViewOnceMessage wrapper
+func viewOnceMedia(msg *waProto.Message) bool {
+	return msg.GetImageMessage().GetViewOnce() || msg.GetVideoMessage().GetViewOnce() || msg.GetAudioMessage().GetViewOnce()
+}
+
+// SetRawMessages toggles inclusion of the serialized message proto in message events
+func (c *Client) SetRawMessages(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.RawMessages = enabled
+}
+
+// rawMessages reports whether raw message passthrough is enabled
+func (c *Client) rawMessages() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.RawMessages
+}
+
+// displayName resolves the sender name using the configured fallback order
+func (c *Client) displayName(info types.MessageInfo) string {
+	c.mu.RLock()
+	mode := c.config.DisplayNameMode
+	c.mu.RUnlock()
+
+	if mode == DisplayNameOff {
+		return ""
+	}
+
+	var contactName, redactedPhone string
+	if contact, err := c.client.Store.Contacts.GetContact(c.ctx, info.Sender.ToNonAD()); err == nil && contact.Found {
+		contactName = contact.FullName
+		if contactName == "" {
+			contactName = contact.FirstName
+		}
+		if contactName == "" {
+			contactName = contact.BusinessName
+		}
+		redactedPhone = contact.RedactedPhone
+	}
+
+	candidates := []string{info.PushName, contactName}
+	if mode == DisplayNameContactFirst {
+		candidates = []string{contactName, info.PushName}
+	}
+	for _, name := range candidates {
+		if name != "" {
+			return name
+		}
+	}
+
+	return formatPhone(info.Sender, info.SenderAlt, redactedPhone)
+}
+
+// formatPhone renders the sender's phone number in international format
+func formatPhone(sender, senderAlt types.JID, redactedPhone string) string {
+	switch {
+	case sender.Server == types.DefaultUserServer:
+		return "+" + sender.User
+	case senderAlt.Server == types.DefaultUserServer:
+		return "+" + senderAlt.User
+	case redactedPhone != "":
+		return redactedPhone
+	default:
+		return sender.User
+	}
+}
+
+// messageType classifies the content of a message for normalized payloads
+func messageType(msg *waProto.Message) string {
+	switch {
+	case msg == nil:
+		return "unknown"
+	case msg.GetConversation() != "" || msg.ExtendedTextMessage != nil:
+		return "text"
+	case msg.ImageMessage != nil:
+		return "image"
+	case msg.VideoMessage != nil:
+		return "video"
+	case msg.AudioMessage != nil:
+		return "audio"
+	case msg.DocumentMessage != nil:
+		return "document"
+	case msg.StickerMessage != nil:
+		return "sticker"
+	case msg.LocationMessage != nil, msg.LiveLocationMessage != nil:
+		return "location"
+	case msg.ContactMessage != nil, msg.ContactsArrayMessage != nil:
+		return "contact"
+	case msg.ReactionMessage != nil:
+		return "reaction"
+	case msg.PollCreationMessage != nil, msg.PollCreationMessageV2 != nil, msg.PollCreationMessageV3 != nil:
+		return "poll"
+	case msg.PollUpdateMessage != nil:
+		return "poll_update"
+	case msg.ProtocolMessage != nil:
+		return "protocol"
+	default:
+		return "unknown"
+	}
+}
+
+// messageText extracts the user-visible text (body or caption) of a message
+func messageText(msg *waProto.Message) string {
+	switch {
+	case msg == nil:
+		return ""
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.ExtendedTextMessage != nil:
+		return msg.ExtendedTextMessage.GetText()
+	case msg.ImageMessage != nil:
+		return msg.ImageMessage.GetCaption()
+	case msg.VideoMessage != nil:
+		return msg.VideoMessage.GetCaption()
+	case msg.DocumentMessage != nil:
+		return msg.DocumentMessage.GetCaption()
+	case msg.ReactionMessage != nil:
+		return msg.ReactionMessage.GetText()
+	case msg.PollCreationMessage != nil:
+		return msg.PollCreationMessage.GetName()
+	case msg.PollCreationMessageV3 != nil:
+		return msg.PollCreationMessageV3.GetName()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/socket"
+)
+
+// TLSConfig customizes certificate verification for websocket and media connections
+type TLSConfig struct {
+	// RootCAsPEM holds extra trusted root certificates (e.g. a corporate MITM proxy CA)
+	RootCAsPEM string
+	// NoSystemRoots trusts only RootCAsPEM instead of adding it to the
+	// system's roots
+	NoSystemRoots bool
+	// PinnedSPKI lists base64 SHA-256 hashes of trusted SubjectPublicKeyInfo;
+	// when set, at least one certificate in the verified chain must match
+	PinnedSPKI []string
+}
+
+// MediaConfig controls the HTTP client used for media uploads and downloads,
+// independently of the websocket connection
+type MediaConfig struct {
+	// ProxyURL routes media traffic through an HTTP(S) or SOCKS5 proxy;
+	// empty falls back to ProxyConfig, then to the environment proxy settings
+	ProxyURL string
+	// Timeout bounds each media request; zero means no timeout
+	Timeout time.Duration
+	// MaxParallel caps concurrent media transfers; zero means unlimited
+	MaxParallel int
+}
+
+// ProxyConfig routes WhatsApp traffic through an egress proxy. It is applied
+// through the bridge's own HTTP clients rather than whatsmeow's
+// SetProxyAddress, which would discard the TLS and dialer settings.
+type ProxyConfig struct {
+	// URL is an http://, https:// or socks5:// proxy address; empty disables it
+	URL string
+	// Username and Password are proxy credentials, overriding any in URL
+	Username string
+	Password string
+	// OnlyLogin proxies the pre-login websocket but not the post-login one
+	OnlyLogin bool
+	// NoMedia keeps media transfers off the proxy; MediaConfig.ProxyURL still
+	// takes precedence when set
+	NoMedia bool
+}
+
+// DialerConfig controls how TCP connections to WhatsApp servers are established
+type DialerConfig struct {
+	// ForceIPv4 skips IPv6 addresses, for networks where they are unreachable
+	ForceIPv4 bool
+	// Resolver is a DNS server ("host:port") used instead of the system resolver
+	Resolver string
+	// Endpoints maps a "host:port" to a fixed address to dial instead
+	Endpoints map[string]string
+}
+
+// WebsocketConfig overrides the endpoint and timeouts of the websocket
+// connection, e.g. for traffic inspection setups or networks that interfere
+// with the default endpoint
+type WebsocketConfig struct {
+	// URL replaces the default ws:// or wss:// endpoint; empty keeps it
+	URL string
+	// Origin replaces the Origin header of the upgrade request
+	Origin string
+	// DialTimeout bounds establishing the TCP connection; zero keeps 30s
+	DialTimeout time.Duration
+	// HandshakeTimeout bounds the TLS handshake and the wait for the upgrade
+	// response; zero keeps the transport defaults
+	HandshakeTimeout time.Duration
+}
+
+// dialFunc matches http.Transport.DialContext
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// buildDialer creates the dial function for the configured dialer options
+// and connect timeout (zero means 30s)
+func buildDialer(cfg DialerConfig, timeout time.Duration) (dialFunc, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if cfg.Resolver != "" {
+		if _, _, err := net.SplitHostPort(cfg.Resolver); err != nil {
+			return nil, argErrorf("invalid resolver address %q: %w", cfg.Resolver, err)
+		}
+		resolverDialer := &net.Dialer{Timeout: 10 * time.Second}
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return resolverDialer.DialContext(ctx, network, cfg.Resolver)
+			},
+		}
+	}
+
+	for from, to := range cfg.Endpoints {
+		if _, _, err := net.SplitHostPort(to); err != nil {
+			return nil, argErrorf("invalid endpoint override %q: %w", from, err)
+		}
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if override, ok := cfg.Endpoints[addr]; ok {
+			addr = override
+		}
+		if cfg.ForceIPv4 {
+			switch network {
+			case "tcp", "tcp6":
+				network = "tcp4"
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}, nil
+}
+
+// buildTLSConfig converts the bridge TLS settings into a crypto/tls config
+func buildTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if cfg.RootCAsPEM == "" && len(cfg.PinnedSPKI) == 0 && !cfg.NoSystemRoots {
+		return nil, nil
+	}
+	if cfg.NoSystemRoots && cfg.RootCAsPEM == "" {
+		return nil, argErrorf("system roots are disabled but no root CA bundle is set")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.RootCAsPEM != "" {
+		pool := x509.NewCertPool()
+		if !cfg.NoSystemRoots {
+			if system, err := x509.SystemCertPool(); err == nil {
+				pool = system
+			}
+		}
+		if !pool.AppendCertsFromPEM([]byte(cfg.RootCAsPEM)) {
+			return nil, argErrorf("no valid certificates in root CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(cfg.PinnedSPKI) > 0 {
+		pins := make(map[string]bool, len(cfg.PinnedSPKI))
+		for _, pin := range cfg.PinnedSPKI {
+			pin = strings.TrimSpace(pin)
+			raw, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(raw) != sha256.Size {
+				return nil, argErrorf("invalid SPKI pin %q", pin)
+			}
+			pins[pin] = true
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, chain := range state.VerifiedChains {
+				for _, cert := range chain {
+					sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					if pins[base64.StdEncoding.EncodeToString(sum[:])] {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("certificate pin mismatch for %s", state.ServerName)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// newTransport creates an HTTP transport with the configured TLS and dialer settings
+func newTransport(tlsConfig *tls.Config, dial dialFunc) *http.Transport {
+	transport := (http.DefaultTransport.(*http.Transport)).Clone()
+	transport.DialContext = dial
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	return transport
+}
+
+// parseProxyURL validates an HTTP(S) or SOCKS5 proxy address
+func parseProxyURL(raw, what string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, argErrorf("invalid %s: %w", what, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, argErrorf("unsupported %s scheme %q", what, proxyURL.Scheme)
+	}
+	return proxyURL, nil
+}
+
+// proxyURL returns the configured proxy with credentials, or nil if disabled
+func (cfg ProxyConfig) proxyURL() (*url.URL, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	proxyURL, err := parseProxyURL(cfg.URL, "proxy")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Username != "" {
+		proxyURL.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	return proxyURL, nil
+}
+
+// defaultSocketURL is the upgrade request URL whatsmeow dials
+var defaultSocketURL = mustParseSocketURL(socket.URL)
+
+// mustParseSocketURL parses a built-in websocket URL
+func mustParseSocketURL(raw string) *url.URL {
+	u, err := parseSocketURL(raw)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// parseSocketURL validates a ws:// or wss:// endpoint and returns it as the
+// http:// or https:// URL of its upgrade request
+func parseSocketURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, argErrorf("invalid websocket URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return nil, argErrorf("unsupported websocket URL scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, argErrorf("websocket URL %q has no host", raw)
+	}
+	return u, nil
+}
+
+// socketRewriter redirects the websocket upgrade request to the configured
+// endpoint and Origin, and meters the upgraded stream. whatsmeow only exposes
+// the endpoint and Origin through MessengerConfig, which also switches the
+// client to the Messenger protocol, so the request is rewritten on its way
+// out instead. Other requests pass through untouched.
+type socketRewriter struct {
+	next     http.RoundTripper
+	endpoint *url.URL
+	origin   string
+	meter    *trafficMeter
+}
+
+func (rt *socketRewriter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != defaultSocketURL.Host || req.URL.Path != defaultSocketURL.Path {
+		return rt.next.RoundTrip(req)
+	}
+
+	if rt.endpoint != nil || rt.origin != "" {
+		req = req.Clone(req.Context())
+	}
+	if rt.endpoint != nil {
+		endpoint := *rt.endpoint
+		req.URL = &endpoint
+		req.Host = endpoint.Host
+	}
+	if rt.origin != "" {
+		req.Header.Set("Origin", rt.origin)
+	}
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// The websocket library takes over the body of the upgrade response
+	if stream, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+		resp.Body = &meteredSocket{ReadWriteCloser: stream, meter: rt.meter}
+	}
+	return resp, nil
+}
+
+// newSocketClient creates an HTTP client for websocket dials, optionally
+// proxied, that meters the socket into meter; endpoint is the parsed
+// WebsocketConfig URL or nil
+func newSocketClient(cfg WebsocketConfig, endpoint *url.URL, tlsConfig *tls.Config, dial dialFunc, proxyURL *url.URL, meter *trafficMeter) *http.Client {
+	transport := newTransport(tlsConfig, dial)
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.HandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.HandshakeTimeout
+		transport.ResponseHeaderTimeout = cfg.HandshakeTimeout
+	}
+	return &http.Client{Transport: &socketRewriter{next: transport, endpoint: endpoint, origin: cfg.Origin, meter: meter}}
+}
+
+// newMediaClient creates the HTTP client for media transfers; proxyURL is the
+// main proxy, used unless the media config names its own
+func newMediaClient(cfg MediaConfig, tlsConfig *tls.Config, dial dialFunc, proxyURL *url.URL) (*http.Client, error) {
+	transport := newTransport(tlsConfig, dial)
+	if cfg.ProxyURL != "" {
+		mediaProxy, err := parseProxyURL(cfg.ProxyURL, "media proxy")
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(mediaProxy)
+	} else if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.MaxParallel > 0 {
+		transport.MaxConnsPerHost = cfg.MaxParallel
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.Timeout}, nil
+}
+
+// applyNetworkConfig installs HTTP clients built from the client config.
+// Must be called before Connect to affect the websocket.
+func (c *Client) applyNetworkConfig() error {
+	tlsConfig, err := buildTLSConfig(c.config.TLS)
+	if err != nil {
+		return fmt.Errorf("invalid TLS config: %w", err)
+	}
+
+	dial, err := buildDialer(c.config.Dialer, 0)
+	if err != nil {
+		return fmt.Errorf("invalid dialer config: %w", err)
+	}
+	socketDial, err := buildDialer(c.config.Dialer, c.config.Websocket.DialTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid dialer config: %w", err)
+	}
+
+	var endpoint *url.URL
+	if c.config.Websocket.URL != "" {
+		if endpoint, err = parseSocketURL(c.config.Websocket.URL); err != nil {
+			return err
+		}
+	}
+
+	proxyURL, err := c.config.Proxy.proxyURL()
+	if err != nil {
+		return err
+	}
+
+	mediaProxy := proxyURL
+	if c.config.Proxy.NoMedia {
+		mediaProxy = nil
+	}
+	mediaHTTP, err := newMediaClient(c.config.Media, tlsConfig, dial, mediaProxy)
+	if err != nil {
+		return err
+	}
+
+	ws := c.config.Websocket
+	preLoginHTTP := newSocketClient(ws, endpoint, tlsConfig, socketDial, proxyURL, &c.stats.socket)
+	socketHTTP := preLoginHTTP
+	if c.config.Proxy.OnlyLogin {
+		socketHTTP = newSocketClient(ws, endpoint, tlsConfig, socketDial, nil, &c.stats.socket)
+	}
+	c.client.SetWebsocketHTTPClient(socketHTTP)
+	c.client.SetPreLoginHTTPClient(preLoginHTTP)
+	c.client.SetMediaHTTPClient(mediaHTTP)
+	c.mediaHTTP = mediaHTTP
+	c.mediaSlots.resize(c.config.Media.MaxParallel)
+	return nil
+}
+
+// mediaSemaphore caps concurrent media transfers. It is resized in place
+// rather than replaced, so transfers already running still count against a
+// new limit.
+type mediaSemaphore struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+	// wake is closed and replaced whenever a slot may have become free
+	wake chan struct{}
+}
+
+func newMediaSemaphore() *mediaSemaphore {
+	return &mediaSemaphore{wake: make(chan struct{})}
+}
+
+// resize sets the limit; zero means unlimited
+func (s *mediaSemaphore) resize(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	s.broadcast()
+}
+
+// broadcast wakes every waiter; s.mu must be held
+func (s *mediaSemaphore) broadcast() {
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
+
+// acquire blocks until a transfer may start or ctx ends, and returns the
+// slot's release func
+func (s *mediaSemaphore) acquire(ctx context.Context) (func(), error) {
+	for {
+		s.mu.Lock()
+		if s.limit <= 0 || s.active < s.limit {
+			s.active++
+			s.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(s.release) }, nil
+		}
+		wake := s.wake
+		s.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *mediaSemaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	s.broadcast()
+}
+
+// acquireMediaSlot blocks until a media transfer may start or ctx ends, and
+// returns its release func
+func (c *Client) acquireMediaSlot(ctx context.Context) (func(), error) {
+	return c.mediaSlots.acquire(ctx)
+}
+
+// SetTLSConfig replaces the TLS settings; takes effect on the next Connect
+func (c *Client) SetTLSConfig(cfg TLSConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.config.TLS
+	// System roots are chosen separately, by SetTLSSystemRoots
+	cfg.NoSystemRoots = prev.NoSystemRoots
+	c.config.TLS = cfg
+	if err := c.applyNetworkConfig(); err != nil {
+		c.config.TLS = prev
+		return err
+	}
+	return nil
+}
+
+// SetTLSSystemRoots chooses whether the system's root certificates are
+// trusted next to the configured root CA bundle
+func (c *Client) SetTLSSystemRoots(enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.config.TLS
+	c.config.TLS.NoSystemRoots = !enabled
+	if err := c.applyNetworkConfig(); err != nil {
+		c.config.TLS = prev
+		return err
+	}
+	return nil
+}
+
+// SetMediaConfig replaces the media HTTP settings
+func (c *Client) SetMediaConfig(cfg MediaConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.config.Media
+	c.config.Media = cfg
+	if err := c.applyNetworkConfig(); err != nil {
+		c.config.Media = prev
+		return err
+	}
+	return nil
+}
+
+// SetProxyConfig replaces the proxy settings; websocket changes take effect on
+// the next Connect
+func (c *Client) SetProxyConfig(cfg ProxyConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.config.Proxy
+	c.config.Proxy = cfg
+	if err := c.applyNetworkConfig(); err != nil {
+		c.config.Proxy = prev
+		return err
+	}
+	return nil
+}
+
+// SetDialerConfig replaces the dialer settings; takes effect on the next Connect
+func (c *Client) SetDialerConfig(cfg DialerConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.config.Dialer
+	c.config.Dialer = cfg
+	if err := c.applyNetworkConfig(); err != nil {
+		c.config.Dialer = prev
+		return err
+	}
+	return nil
+}
+
+// SetWebsocketConfig replaces the websocket endpoint and timeouts; takes
+// effect on the next Connect
+func (c *Client) SetWebsocketConfig(cfg WebsocketConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.config.Websocket
+	c.config.Websocket = cfg
+	if err := c.applyNetworkConfig(); err != nil {
+		c.config.Websocket = prev
+		return err
+	}
+	return nil
+}
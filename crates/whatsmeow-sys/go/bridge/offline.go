@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// OfflineSyncPreviewEvent announces what the server is about to replay after
+// connecting. BatchSize is how many messages the bridge requests at a time,
+// zero when the server's default is used; BatchError says why the request
+// couldn't be sent, leaving the server's default in place.
+type OfflineSyncPreviewEvent struct {
+	Total          int    `json:"total" pb:"1"`
+	AppDataChanges int    `json:"app_data_changes" pb:"2"`
+	Messages       int    `json:"messages" pb:"3"`
+	Notifications  int    `json:"notifications" pb:"4"`
+	Receipts       int    `json:"receipts" pb:"5"`
+	BatchSize      int    `json:"batch_size,omitempty" pb:"6"`
+	BatchError     string `json:"batch_error,omitempty" pb:"7"`
+}
+
+// OfflineSyncCompletedEvent is emitted once the server replayed everything
+// missed while offline. Total is the count from the preview and Pending what
+// it announced but didn't send; DurationMs is the time since the preview.
+type OfflineSyncCompletedEvent struct {
+	Count      int   `json:"count" pb:"1"`
+	Total      int   `json:"total" pb:"2"`
+	Pending    int   `json:"pending" pb:"3"`
+	DurationMs int64 `json:"duration_ms" pb:"4"`
+}
+
+// handleOfflinePreview records when the offline replay started and asks for
+// the configured batch size
+func (c *Client) handleOfflinePreview(evt *events.OfflineSyncPreview) *OfflineSyncPreviewEvent {
+	c.mu.Lock()
+	c.syncStart = time.Now()
+	c.syncTotal = evt.Total
+	batch := c.config.OfflineBatch
+	c.mu.Unlock()
+
+	out := &OfflineSyncPreviewEvent{
+		Total:          evt.Total,
+		AppDataChanges: evt.AppDataChanges,
+		Messages:       evt.Messages,
+		Notifications:  evt.Notifications,
+		Receipts:       evt.Receipts,
+		BatchSize:      batch,
+	}
+	if batch > 0 {
+		err := c.client.DangerousInternals().SendNode(c.ctx, waBinary.Node{
+			Tag: "ib",
+			Content: []waBinary.Node{{
+				Tag:   "offline_batch",
+				Attrs: waBinary.Attrs{"count": batch},
+			}},
+		})
+		if err != nil {
+			out.BatchError = fmt.Sprintf("failed to request offline batch size: %v", err)
+		}
+	}
+	return out
+}
+
+// handleOfflineCompleted reports the finished replay against its preview
+func (c *Client) handleOfflineCompleted(evt *events.OfflineSyncCompleted) *OfflineSyncCompletedEvent {
+	c.mu.Lock()
+	start, total := c.syncStart, c.syncTotal
+	c.syncStart, c.syncTotal = time.Time{}, 0
+	c.mu.Unlock()
+
+	out := &OfflineSyncCompletedEvent{Count: evt.Count, Total: total}
+	if total > evt.Count {
+		out.Pending = total - evt.Count
+	}
+	if !start.IsZero() {
+		out.DurationMs = time.Since(start).Milliseconds()
+	}
+	return out
+}
+
+// SetOfflineBatch sets how many offline messages are requested at a time
+// after connecting; zero leaves it to the server
+func (c *Client) SetOfflineBatch(size int) error {
+	if size < 0 {
+		return argErrorf("offline batch size must not be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.OfflineBatch = size
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// opRegistry tracks the cancellable operations of a client
+type opRegistry struct {
+	mu   sync.Mutex
+	next int64
+	ops  map[int64]*operation
+}
+
+// operation is the context a blocking call runs under
+type operation struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newOpRegistry() *opRegistry {
+	return &opRegistry{ops: make(map[int64]*operation)}
+}
+
+// BeginOp creates an operation that ends after timeout, if positive, or when
+// cancelled, and returns its ID. The next blocking call made on the same
+// host thread runs under it (see wm_op_begin).
+func (c *Client) BeginOp(timeout time.Duration) int64 {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(c.ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(c.ctx)
+	}
+
+	r := c.ops
+	r.mu.Lock()
+	r.next++
+	id := r.next
+	r.ops[id] = &operation{ctx: ctx, cancel: cancel}
+	r.mu.Unlock()
+
+	// Finished, cancelled and expired operations forget themselves
+	context.AfterFunc(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.ops, id)
+	})
+	return id
+}
+
+// CancelOp aborts an operation and reports whether it was still running
+func (c *Client) CancelOp(id int64) bool {
+	c.ops.mu.Lock()
+	op, ok := c.ops.ops[id]
+	c.ops.mu.Unlock()
+	if ok {
+		op.cancel()
+	}
+	return ok
+}
+
+// opContext returns the context of an operation and a func that ends it, or
+// the client context for id 0. An operation that already ended, cancelled or
+// timed out before the call started, yields a cancelled context so the call
+// fails instead of running without its deadline.
+func (c *Client) opContext(id int64) (context.Context, func()) {
+	if id == 0 {
+		return c.ctx, func() {}
+	}
+
+	c.ops.mu.Lock()
+	op, ok := c.ops.ops[id]
+	c.ops.mu.Unlock()
+	if !ok {
+		ctx, cancel := context.WithCancel(c.ctx)
+		cancel()
+		return ctx, cancel
+	}
+	return op.ctx, op.cancel
+}
+
+// callContext returns the context for a blocking call from the host: the
+// operation begun on the calling thread for this client, if any, else the
+// client context
+func (c *Client) callContext() (context.Context, func()) {
+	return c.opContext(takePendingOp(c.handle))
+}
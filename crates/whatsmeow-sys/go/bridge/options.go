@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ClientOptionsVersion is the schema version of ClientOptions understood by
+// this bridge. Hosts set it so that a bridge too old for their options
+// rejects them instead of silently ignoring fields.
+const ClientOptionsVersion = 1
+
+// ClientOptions is the JSON configuration taken by wm_client_new_with_options.
+// Unknown fields are rejected; omitted ones keep their defaults.
+type ClientOptions struct {
+	// Version must be set to ClientOptionsVersion or lower
+	Version int `json:"version"`
+	Store   struct {
+		// Driver is the database driver; the bridge is built with SQLite only
+		Driver string `json:"driver"`
+		// Path is the database file, or ":memory:" for an ephemeral store
+		Path          string `json:"path"`
+		EncryptionKey string `json:"encryption_key"`
+	} `json:"store"`
+	Device struct {
+		Name     string `json:"name"`
+		Platform string `json:"platform"`
+	} `json:"device"`
+	TLS struct {
+		RootCAsPEM string `json:"root_cas_pem"`
+		// SystemRoots defaults to true
+		SystemRoots *bool `json:"system_roots"`
+		// PinnedSPKI lists base64 SHA-256 hashes of trusted public keys
+		PinnedSPKI []string `json:"pinned_spki"`
+	} `json:"tls"`
+	Proxy struct {
+		URL       string `json:"url"`
+		Username  string `json:"username"`
+		Password  string `json:"password"`
+		OnlyLogin bool   `json:"only_login"`
+		NoMedia   bool   `json:"no_media"`
+	} `json:"proxy"`
+	QueueSize   int    `json:"queue_size"`
+	EventFormat string `json:"event_format"`
+	LogLevel    string `json:"log_level"`
+	// AutoReconnect defaults to true
+	AutoReconnect *bool `json:"auto_reconnect"`
+	Reconnect     struct {
+		InitialDelayMs int64   `json:"initial_delay_ms"`
+		MaxDelayMs     int64   `json:"max_delay_ms"`
+		Jitter         float64 `json:"jitter"`
+	} `json:"reconnect"`
+	HistorySync struct {
+		RequireFullSync bool   `json:"require_full_sync"`
+		FullSyncDays    uint32 `json:"full_sync_days"`
+		FullSyncSizeMb  uint32 `json:"full_sync_size_mb"`
+		RecentSyncDays  uint32 `json:"recent_sync_days"`
+		StorageQuotaMb  uint32 `json:"storage_quota_mb"`
+	} `json:"history_sync"`
+}
+
+// parseClientOptions decodes and validates the options JSON, naming the
+// offending field in errors
+func parseClientOptions(data []byte) (*ClientOptions, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	opts := &ClientOptions{}
+	if err := dec.Decode(opts); err != nil {
+		return nil, optionsError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, argErrorf("invalid client options: trailing data after the JSON object")
+	}
+
+	switch {
+	case opts.Version == 0:
+		return nil, argErrorf("invalid client options: version is required (this bridge supports %d)", ClientOptionsVersion)
+	case opts.Version < 0 || opts.Version > ClientOptionsVersion:
+		return nil, argErrorf("invalid client options: version %d is not supported (this bridge supports up to %d)", opts.Version, ClientOptionsVersion)
+	}
+	switch strings.ToLower(opts.Store.Driver) {
+	case "", "sqlite", "sqlite3":
+	default:
+		return nil, argErrorf("invalid client options: store.driver %q is not supported (only sqlite3)", opts.Store.Driver)
+	}
+	if opts.Store.Path == "" {
+		return nil, argErrorf("invalid client options: store.path is required")
+	}
+	switch opts.EventFormat {
+	case "", EventFormatJSON, EventFormatProtobuf, EventFormatMsgpack, EventFormatCBOR:
+	default:
+		return nil, argErrorf("invalid client options: unknown event_format %q", opts.EventFormat)
+	}
+	if opts.LogLevel != "" {
+		if _, err := parseLogLevel(opts.LogLevel); err != nil {
+			return nil, argErrorf("invalid client options: log_level: %w", err)
+		}
+	}
+	if opts.Reconnect.InitialDelayMs < 0 || opts.Reconnect.MaxDelayMs < 0 {
+		return nil, argErrorf("invalid client options: reconnect delays must not be negative")
+	}
+	return opts, nil
+}
+
+// optionsError rewords JSON decoding errors to name the field at fault
+func optionsError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return argErrorf("invalid client options: %s must be %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return argErrorf("invalid client options: malformed JSON at offset %d: %w", syntaxErr.Offset, err)
+	case errors.Is(err, io.EOF):
+		return argErrorf("invalid client options: empty JSON")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return argErrorf("invalid client options: truncated JSON")
+	}
+	// Unknown fields are reported as `json: unknown field "name"`
+	return argErrorf("invalid client options: %s", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// config translates the options into a client configuration
+func (opts *ClientOptions) config() ClientConfig {
+	config := ClientConfig{
+		DbPath:        opts.Store.Path,
+		EncryptionKey: opts.Store.EncryptionKey,
+		DeviceName:    opts.Device.Name,
+		Platform:      opts.Device.Platform,
+		TLS: TLSConfig{
+			RootCAsPEM: opts.TLS.RootCAsPEM,
+			PinnedSPKI: opts.TLS.PinnedSPKI,
+		},
+		Proxy: ProxyConfig{
+			URL:       opts.Proxy.URL,
+			Username:  opts.Proxy.Username,
+			Password:  opts.Proxy.Password,
+			OnlyLogin: opts.Proxy.OnlyLogin,
+			NoMedia:   opts.Proxy.NoMedia,
+		},
+		QueueSize:   opts.QueueSize,
+		EventFormat: opts.EventFormat,
+		Reconnect: ReconnectConfig{
+			InitialDelay: time.Duration(opts.Reconnect.InitialDelayMs) * time.Millisecond,
+			MaxDelay:     time.Duration(opts.Reconnect.MaxDelayMs) * time.Millisecond,
+			Jitter:       opts.Reconnect.Jitter,
+		},
+		HistorySync: HistorySyncConfig{
+			RequireFullSync: opts.HistorySync.RequireFullSync,
+			FullSyncDays:    opts.HistorySync.FullSyncDays,
+			FullSyncSizeMb:  opts.HistorySync.FullSyncSizeMb,
+			RecentSyncDays:  opts.HistorySync.RecentSyncDays,
+			StorageQuotaMb:  opts.HistorySync.StorageQuotaMb,
+		},
+	}
+	if opts.TLS.SystemRoots != nil {
+		config.TLS.NoSystemRoots = !*opts.TLS.SystemRoots
+	}
+	if opts.AutoReconnect != nil {
+		config.Reconnect.Disabled = !*opts.AutoReconnect
+	}
+	return config
+}
+
+// NewClientWithOptions creates a client from the options JSON taken by
+// wm_client_new_with_options
+func NewClientWithOptions(data []byte) (*Client, error) {
+	opts, err := parseClientOptions(data)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(opts.config())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	if opts.LogLevel != "" {
+		// Validated while parsing
+		client.SetLogLevel(opts.LogLevel, "")
+	}
+	return client, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClientOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"minimal", `{"version": 1, "store": {"path": "a.db"}}`, ""},
+		{"sqlite driver", `{"version": 1, "store": {"driver": "SQLite3", "path": "a.db"}}`, ""},
+		{"every format", `{"version": 1, "store": {"path": "a.db"}, "event_format": "cbor", "log_level": "debug"}`, ""},
+		{"empty", ``, "empty JSON"},
+		{"truncated", `{"version": 1`, "truncated JSON"},
+		{"malformed", `{"version": 1,}`, "malformed JSON at offset"},
+		{"trailing data", `{"version": 1, "store": {"path": "a.db"}} {}`, "trailing data"},
+		{"unknown field", `{"version": 1, "store": {"path": "a.db"}, "colour": "red"}`, `unknown field "colour"`},
+		{"wrong type", `{"version": "1"}`, "version must be int, not string"},
+		{"nested wrong type", `{"version": 1, "store": {"path": 5}}`, "store.path must be string, not number"},
+		{"no version", `{"store": {"path": "a.db"}}`, "version is required"},
+		{"newer version", `{"version": 2, "store": {"path": "a.db"}}`, "version 2 is not supported"},
+		{"negative version", `{"version": -1, "store": {"path": "a.db"}}`, "version -1 is not supported"},
+		{"other driver", `{"version": 1, "store": {"driver": "postgres", "path": "a.db"}}`, `store.driver "postgres"`},
+		{"no path", `{"version": 1}`, "store.path is required"},
+		{"unknown format", `{"version": 1, "store": {"path": "a.db"}, "event_format": "xml"}`, `unknown event_format "xml"`},
+		{"unknown log level", `{"version": 1, "store": {"path": "a.db"}, "log_level": "chatty"}`, "log_level"},
+		{"negative delay", `{"version": 1, "store": {"path": "a.db"}, "reconnect": {"max_delay_ms": -1}}`, "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseClientOptions([]byte(tt.json))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr == "":
+			case err == nil:
+				t.Fatalf("no error, want one containing %q", tt.wantErr)
+			case !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error %q doesn't contain %q", err, tt.wantErr)
+			case !errors.Is(err, ErrInvalidArgument):
+				t.Errorf("error %q is not an argument error", err)
+			}
+		})
+	}
+}
+
+func TestClientOptionsConfig(t *testing.T) {
+	opts, err := parseClientOptions([]byte(`{
+		"version": 1,
+		"store": {"path": "a.db"},
+		"tls": {"system_roots": false},
+		"auto_reconnect": false,
+		"reconnect": {"initial_delay_ms": 500, "max_delay_ms": 60000, "jitter": 0.2}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := opts.config()
+	if config.DbPath != "a.db" || !config.TLS.NoSystemRoots || !config.Reconnect.Disabled {
+		t.Errorf("config = %+v", config)
+	}
+	if config.Reconnect.InitialDelay != 500*time.Millisecond || config.Reconnect.MaxDelay != time.Minute || config.Reconnect.Jitter != 0.2 {
+		t.Errorf("reconnect config = %+v", config.Reconnect)
+	}
+
+	// Omitted switches keep their defaults
+	opts, err = parseClientOptions([]byte(`{"version": 1, "store": {"path": "a.db"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config := opts.config(); config.TLS.NoSystemRoots || config.Reconnect.Disabled {
+		t.Errorf("defaults config = %+v", config)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Kinds of queued sends
+const (
+	outboxText  = "text"
+	outboxImage = "image"
+)
+
+// maxOutboxAttempts is how many times a queued send is tried for retryable
+// errors before it fails, so one stuck send can't hold up those behind it
+const maxOutboxAttempts = 5
+
+// OutboxEvent reports the progress of a send accepted while offline; it is
+// emitted as outbox_queued, outbox_sent (with the server timestamp and, for
+// newsletters, the server ID) or outbox_failed
+type OutboxEvent struct {
+	MessageID string `json:"message_id" pb:"1"`
+	Chat      string `json:"chat" pb:"2"`
+	Kind      string `json:"kind" pb:"3"`
+	QueuedAt  int64  `json:"queued_at" pb:"4"`
+	Error     string `json:"error,omitempty" pb:"5"`
+	Timestamp int64  `json:"timestamp,omitempty" pb:"6"`
+	ServerID  int    `json:"server_id,omitempty" pb:"7"`
+}
+
+// outboxItem is a send persisted in wm_bridge_outbox
+type outboxItem struct {
+	id        int64
+	messageID types.MessageID
+	chat      types.JID
+	kind      string
+	text      string
+	mimeType  string
+	data      []byte
+	queuedAt  int64
+}
+
+// outboxDrain is the state of drainOutbox between runs; only the goroutine
+// holding Client.flushing touches it
+type outboxDrain struct {
+	// attempts counts the retryable failures of queued sends
+	attempts map[int64]int
+	// sent holds sends that went out but couldn't be removed from the outbox,
+	// so they are removed instead of sent again
+	sent map[int64]bool
+}
+
+func (item *outboxItem) event() OutboxEvent {
+	return OutboxEvent{
+		MessageID: item.messageID,
+		Chat:      item.chat.String(),
+		Kind:      item.kind,
+		QueuedAt:  item.queuedAt,
+	}
+}
+
+// SetOfflineQueue toggles the offline send queue. While enabled, sends made
+// while disconnected are stored and delivered in order after reconnecting.
+// Disabling it keeps already queued sends, which are still delivered.
+func (c *Client) SetOfflineQueue(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.OfflineQueue = enabled
+}
+
+// loadOutbox counts the sends left queued by a previous session
+func (c *Client) loadOutbox() error {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil
+	}
+
+	var n int64
+	err := c.db.QueryRowContext(c.ctx,
+		"SELECT COUNT(*) FROM wm_bridge_outbox WHERE our_jid = ?", own.ToNonAD().String()).Scan(&n)
+	if err != nil {
+		return err
+	}
+	c.outboxLen.Store(n)
+	return nil
+}
+
+// shouldQueue reports whether a send must go through the outbox: when
+// offline with the queue enabled, or while earlier sends are still queued so
+// that order is kept. Callers must hold c.mu.
+func (c *Client) shouldQueue() bool {
+	if c.outboxLen.Load() > 0 {
+		return true
+	}
+	return c.config.OfflineQueue && !c.client.IsLoggedIn()
+}
+
+// queueOutgoing persists a send, assigning its message ID unless the caller
+// chose one, and emits outbox_queued. Callers must not hold c.mu, which emit
+// takes.
+func (c *Client) queueOutgoing(item *outboxItem) (*SendResult, error) {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, ErrNotConnected
+	}
+
+	if item.messageID == "" {
+		item.messageID = c.client.GenerateMessageID()
+	}
+	item.queuedAt = time.Now().Unix()
+	res, err := c.db.ExecContext(c.ctx,
+		`INSERT INTO wm_bridge_outbox (our_jid, message_id, chat, kind, text, mime_type, data, queued_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		own.ToNonAD().String(), item.messageID, item.chat.String(), item.kind,
+		item.text, item.mimeType, item.data, item.queuedAt)
+	if err != nil {
+		return nil, err
+	}
+	item.id, _ = res.LastInsertId()
+	c.outboxLen.Add(1)
+	c.delivery.track(item.chat, item.messageID, StatusPending, time.Now())
+
+	c.emit("outbox_queued", item.event())
+	if c.client.IsLoggedIn() {
+		go c.flushOutbox()
+	}
+	return &SendResult{MessageID: item.messageID, Chat: item.chat.String(), Queued: true}, nil
+}
+
+// nextOutboxItem returns the oldest queued send, or nil if there is none
+func (c *Client) nextOutboxItem() (*outboxItem, error) {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, nil
+	}
+
+	item := &outboxItem{}
+	var chat string
+	err := c.db.QueryRowContext(c.ctx,
+		`SELECT id, message_id, chat, kind, text, mime_type, data, queued_at
+		FROM wm_bridge_outbox WHERE our_jid = ? ORDER BY id LIMIT 1`,
+		own.ToNonAD().String()).Scan(&item.id, &item.messageID, &chat, &item.kind,
+		&item.text, &item.mimeType, &item.data, &item.queuedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	item.chat, err = types.ParseJID(chat)
+	return item, err
+}
+
+// flushOutbox delivers queued sends in order. It stops at the first send
+// that fails for a retryable reason, leaving it for the next connection,
+// until the send has been tried maxOutboxAttempts times.
+func (c *Client) flushOutbox() {
+	for c.outboxLen.Load() > 0 && c.flushing.CompareAndSwap(false, true) {
+		drained := c.drainOutbox()
+		c.flushing.Store(false)
+		// Re-check the count: a send queued just before the flag was
+		// released would otherwise wait for the next connection
+		if !drained {
+			return
+		}
+	}
+}
+
+// drainOutbox sends queued items until the queue is empty (returning true)
+// or the client can't send right now (returning false)
+func (c *Client) drainOutbox() bool {
+	if c.drain.attempts == nil {
+		c.drain = outboxDrain{attempts: make(map[int64]int), sent: make(map[int64]bool)}
+	}
+	for {
+		if c.frozen.Load() || c.closing.Load() || !c.client.IsLoggedIn() {
+			return false
+		}
+
+		item, err := c.nextOutboxItem()
+		if err != nil {
+			return false
+		} else if item == nil {
+			return true
+		}
+		if c.drain.sent[item.id] {
+			// Sent already; only the removal failed last time
+			if !c.removeOutboxItem(item) {
+				return false
+			}
+			continue
+		}
+
+		var result *SendResult
+		switch item.kind {
+		case outboxImage:
+			result, err = c.sendImage(c.ctx, item.chat, item.data, item.mimeType, item.text, item.messageID)
+		default:
+			result, err = c.sendText(c.ctx, item.chat, item.text, item.messageID)
+		}
+		if err != nil && newErrorDetail(WM_ERR_CONNECT, err).Retryable {
+			c.drain.attempts[item.id]++
+			if c.drain.attempts[item.id] < maxOutboxAttempts {
+				return false
+			}
+			err = fmt.Errorf("gave up after %d attempts: %w", maxOutboxAttempts, err)
+		}
+		delete(c.drain.attempts, item.id)
+
+		evt := item.event()
+		if err != nil {
+			if !c.removeOutboxItem(item) {
+				return false
+			}
+			evt.Error = err.Error()
+			c.delivery.track(item.chat, item.messageID, StatusFailed, time.Now())
+			c.emit("outbox_failed", evt)
+			continue
+		}
+
+		// The send went out, so it must not be sent again even if it can't
+		// be removed right now
+		c.drain.sent[item.id] = true
+		removed := c.removeOutboxItem(item)
+		evt.Timestamp = result.Timestamp
+		evt.ServerID = result.ServerID
+		c.emit("outbox_sent", evt)
+		if !removed {
+			return false
+		}
+	}
+}
+
+// removeOutboxItem deletes a finished send from the outbox
+func (c *Client) removeOutboxItem(item *outboxItem) bool {
+	if _, err := c.db.ExecContext(c.ctx, "DELETE FROM wm_bridge_outbox WHERE id = ?", item.id); err != nil {
+		return false
+	}
+	delete(c.drain.sent, item.id)
+	c.outboxLen.Add(-1)
+	return true
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+	"unicode/utf8"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// HumanPacingConfig delays sends the way a person composing them would
+type HumanPacingConfig struct {
+	// MinDelay and MaxDelay bound the random delay before each send; a zero
+	// MaxDelay disables pacing
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// PerChar adds time for each character of text or caption, still capped
+	// at MaxDelay
+	PerChar time.Duration
+	// Typing shows "typing..." ("recording audio..." for voice notes) in the
+	// chat during the delay
+	Typing bool
+}
+
+// validate checks the delay bounds
+func (cfg HumanPacingConfig) validate() error {
+	if cfg.MinDelay < 0 || cfg.PerChar < 0 {
+		return argErrorf("pacing delays must not be negative")
+	}
+	if cfg.MaxDelay > 0 && cfg.MinDelay > cfg.MaxDelay {
+		return argErrorf("minimum pacing delay exceeds the maximum")
+	}
+	return nil
+}
+
+// delay picks the composition time of a message with textLen characters
+func (cfg HumanPacingConfig) delay(textLen int) time.Duration {
+	d := cfg.MinDelay
+	if spread := cfg.MaxDelay - cfg.MinDelay; spread > 0 {
+		d += rand.N(spread)
+	}
+	d += time.Duration(textLen) * cfg.PerChar
+	return min(d, cfg.MaxDelay)
+}
+
+// SetHumanPacing replaces the human pacing settings
+func (c *Client) SetHumanPacing(cfg HumanPacingConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.HumanPacing = cfg
+	return nil
+}
+
+// composes reports whether a message is something a person types or
+// records, as opposed to reactions, poll votes and protocol messages
+func composes(to types.JID, msg *waProto.Message) bool {
+	if to == types.StatusBroadcastJID || to.Server == types.NewsletterServer {
+		return false
+	}
+	switch messageType(msg) {
+	case "reaction", "poll_update", "protocol", "unknown":
+		return false
+	}
+	return true
+}
+
+// humanPacing returns the human pacing settings
+func (c *Client) humanPacing() HumanPacingConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.HumanPacing
+}
+
+// compose waits the human pacing delay of cfg before a send, showing a chat
+// presence meanwhile if configured. It sleeps and sends presences, so
+// callers must not hold c.mu.
+func (c *Client) compose(ctx context.Context, cfg HumanPacingConfig, to types.JID, msg *waProto.Message) error {
+	if cfg.MaxDelay == 0 || !composes(to, msg) {
+		return nil
+	}
+
+	if cfg.Typing {
+		media := types.ChatPresenceMediaText
+		if msg.AudioMessage.GetPTT() {
+			media = types.ChatPresenceMediaAudio
+		}
+		// Typing indicators are best effort
+		c.client.SendChatPresence(ctx, to, types.ChatPresenceComposing, media)
+		defer c.client.SendChatPresence(c.ctx, to, types.ChatPresencePaused, media)
+	}
+
+	timer := time.NewTimer(cfg.delay(utf8.RuneCountInString(messageText(msg))))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHumanPacingDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      HumanPacingConfig
+		textLen  int
+		min, max time.Duration
+	}{
+		{"fixed", HumanPacingConfig{MinDelay: time.Second, MaxDelay: time.Second}, 0, time.Second, time.Second},
+		{"spread", HumanPacingConfig{MinDelay: time.Second, MaxDelay: 3 * time.Second}, 0, time.Second, 3 * time.Second},
+		{"per char adds", HumanPacingConfig{MinDelay: time.Second, MaxDelay: time.Second + 500*time.Millisecond, PerChar: 100 * time.Millisecond}, 5, time.Second + 500*time.Millisecond, time.Second + 500*time.Millisecond},
+		{"capped", HumanPacingConfig{MinDelay: time.Second, MaxDelay: 2 * time.Second, PerChar: time.Second}, 100, 2 * time.Second, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				if d := tt.cfg.delay(tt.textLen); d < tt.min || d > tt.max {
+					t.Fatalf("delay(%d) = %v, want within %v..%v", tt.textLen, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestHumanPacingValidate(t *testing.T) {
+	tests := []struct {
+		cfg     HumanPacingConfig
+		wantErr bool
+	}{
+		{HumanPacingConfig{}, false},
+		{HumanPacingConfig{MinDelay: time.Second, MaxDelay: 2 * time.Second, PerChar: time.Millisecond}, false},
+		// The minimum only applies once pacing is on
+		{HumanPacingConfig{MinDelay: time.Second}, false},
+		{HumanPacingConfig{MinDelay: -1}, true},
+		{HumanPacingConfig{PerChar: -1}, true},
+		{HumanPacingConfig{MinDelay: 2 * time.Second, MaxDelay: time.Second}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v, want error %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
+
+func TestComposes(t *testing.T) {
+	user := types.JID{User: "15551111111", Server: types.DefaultUserServer}
+	newsletter := types.JID{User: "120363000000000000", Server: types.NewsletterServer}
+	text := &waProto.Message{Conversation: proto.String("hi")}
+	tests := []struct {
+		name string
+		to   types.JID
+		msg  *waProto.Message
+		want bool
+	}{
+		{"text", user, text, true},
+		{"status", types.StatusBroadcastJID, text, false},
+		{"newsletter", newsletter, text, false},
+		{"reaction", user, &waProto.Message{ReactionMessage: &waProto.ReactionMessage{Text: proto.String("👍")}}, false},
+		{"protocol", user, &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{}}, false},
+	}
+	for _, tt := range tests {
+		if got := composes(tt.to, tt.msg); got != tt.want {
+			t.Errorf("%s: composes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
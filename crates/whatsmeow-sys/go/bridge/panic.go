@@ -0,0 +1,50 @@
+package main
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// recordPanic stores the detail of a recovered panic for this thread
+func recordPanic(r interface{}) {
+	setCallError(ErrorDetail{
+		Code:     WM_ERR_PANIC,
+		Category: CategoryInternal,
+		Message:  fmt.Sprintf("bridge panic: %v", r),
+		Stack:    string(debug.Stack()),
+	})
+}
+
+// catchPanic is deferred first in every export: a panic unwinding into cgo
+// would abort the host process, so it is turned into WM_ERR_PANIC with the
+// stack in the call error detail. ret may be nil for exports without result.
+func catchPanic(ret *C.int) {
+	if r := recover(); r != nil {
+		recordPanic(r)
+		if ret != nil {
+			*ret = WM_ERR_PANIC
+		}
+	}
+}
+
+// catchPanicHandle is catchPanic for exports returning a handle, which
+// report failure as 0
+func catchPanicHandle(ret *C.uintptr_t) {
+	if r := recover(); r != nil {
+		recordPanic(r)
+		*ret = 0
+	}
+}
+
+// catchPanicLong is catchPanic for exports returning a long long
+func catchPanicLong(ret *C.longlong) {
+	if r := recover(); r != nil {
+		recordPanic(r)
+		*ret = WM_ERR_PANIC
+	}
+}
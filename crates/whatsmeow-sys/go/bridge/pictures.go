@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// avatarFetchTimeout bounds looking up and downloading a changed avatar
+const avatarFetchTimeout = 30 * time.Second
+
+// PictureUpdateEvent is emitted when a contact's or group's picture changed
+// or was removed. With an avatar directory configured the new picture is
+// downloaded first and Path names the file, or FetchError says why not; the
+// low-bandwidth profile skips the download and sets FetchSkipped.
+type PictureUpdateEvent struct {
+	JID string `json:"jid" pb:"1"`
+	// Author is who changed the picture; for contacts that is the contact
+	Author    string `json:"author,omitempty" pb:"2"`
+	PictureID string `json:"picture_id,omitempty" pb:"3"`
+	Removed   bool   `json:"removed" pb:"4"`
+	Timestamp int64  `json:"timestamp" pb:"5"`
+	// Path is the downloaded avatar, FetchError why it couldn't be fetched
+	Path       string `json:"path,omitempty" pb:"6"`
+	FetchError string `json:"fetch_error,omitempty" pb:"7"`
+	// FetchSkipped is set when the low-bandwidth profile skipped the download
+	FetchSkipped bool `json:"fetch_skipped,omitempty" pb:"8"`
+}
+
+func newPictureUpdateEvent(evt *events.Picture) *PictureUpdateEvent {
+	out := &PictureUpdateEvent{
+		JID:       evt.JID.ToNonAD().String(),
+		PictureID: evt.PictureID,
+		Removed:   evt.Remove,
+		Timestamp: evt.Timestamp.Unix(),
+	}
+	if !evt.Author.IsEmpty() {
+		out.Author = evt.Author.ToNonAD().String()
+	}
+	return out
+}
+
+// avatarDir returns the directory changed avatars are downloaded to, or ""
+func (c *Client) avatarDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.AvatarDir
+}
+
+// handlePicture reports a picture change, downloading the new avatar in the
+// background first when an avatar directory is set
+func (c *Client) handlePicture(evt *events.Picture) *PictureUpdateEvent {
+	out := newPictureUpdateEvent(evt)
+	dir := c.avatarDir()
+	if dir == "" || evt.Remove {
+		return out
+	}
+	if c.lowBandwidth() {
+		out.FetchSkipped = true
+		return out
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(c.ctx, avatarFetchTimeout)
+		defer cancel()
+		if path, err := c.fetchAvatar(ctx, evt, dir); err != nil {
+			out.FetchError = err.Error()
+		} else {
+			out.Path = path
+		}
+		c.emit("picture_update", out)
+	}()
+	return nil
+}
+
+// fetchAvatar downloads the full-size picture of evt.JID into dir, named
+// after the JID
+func (c *Client) fetchAvatar(ctx context.Context, evt *events.Picture, dir string) (string, error) {
+	info, err := c.client.GetProfilePictureInfo(ctx, evt.JID.ToNonAD(), &whatsmeow.GetProfilePictureParams{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get picture info: %w", err)
+	}
+	if info == nil {
+		return "", whatsmeow.ErrProfilePictureNotSet
+	}
+
+	c.mu.RLock()
+	httpClient := c.mediaHTTP
+	c.mu.RUnlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	release, err := c.acquireMediaSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download picture: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download picture: HTTP %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create avatar directory: %w", err)
+	}
+	path := filepath.Join(dir, evt.JID.ToNonAD().String()+".jpg")
+	// Write next to the old avatar and swap it in, so readers never see a
+	// partial file
+	tmp, err := os.CreateTemp(dir, ".avatar-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write avatar: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write avatar: %w", err)
+	}
+	c.stats.downloaded.Add(uint64(n))
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write avatar: %w", err)
+	}
+	return path, nil
+}
+
+// SetAvatarDir sets the directory changed contact and group pictures are
+// downloaded to before their picture_update event; empty disables it
+func (c *Client) SetAvatarDir(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.AvatarDir = dir
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// maxPollsTracked bounds how many polls keep their options and votes
+const maxPollsTracked = 1024
+
+// PollCreatedEvent is emitted instead of a message event for a new poll
+type PollCreatedEvent struct {
+	MessageID string   `json:"message_id" pb:"1"`
+	Chat      string   `json:"chat" pb:"2"`
+	Sender    string   `json:"sender" pb:"3"`
+	FromMe    bool     `json:"from_me" pb:"4"`
+	Timestamp int64    `json:"timestamp" pb:"5"`
+	Name      string   `json:"name" pb:"6"`
+	Options   []string `json:"options" pb:"7"`
+	// SelectableCount is how many options a voter may pick; 0 means any
+	SelectableCount int `json:"selectable_count" pb:"8"`
+}
+
+// PollVoteEvent is emitted instead of a message event for a poll vote. A
+// vote replaces the voter's earlier one; an empty Selected retracts it.
+type PollVoteEvent struct {
+	PollID    string `json:"poll_id" pb:"1"`
+	VoteID    string `json:"vote_id" pb:"2"`
+	Chat      string `json:"chat" pb:"3"`
+	Voter     string `json:"voter" pb:"4"`
+	FromMe    bool   `json:"from_me" pb:"5"`
+	Timestamp int64  `json:"timestamp" pb:"6"`
+	// Selected holds option names; options of polls the bridge hasn't seen
+	// are given as hex SHA-256 hashes
+	Selected []string `json:"selected" pb:"7"`
+	// Tallies counts the voters of each option, when the poll is known
+	Tallies map[string]int `json:"tallies,omitempty" pb:"8"`
+	// Error is set when the vote couldn't be decrypted
+	Error string `json:"error,omitempty" pb:"9"`
+}
+
+// pollTracker keeps the options and latest vote of each voter per poll
+type pollTracker struct {
+	mu      sync.Mutex
+	entries map[types.MessageID]*pollEntry
+	order   []types.MessageID
+}
+
+type pollEntry struct {
+	options []string
+	// byHash maps hex option hashes to option names
+	byHash  map[string]string
+	byVoter map[string][]string
+}
+
+func newPollTracker() *pollTracker {
+	return &pollTracker{entries: make(map[types.MessageID]*pollEntry)}
+}
+
+// add starts tracking a poll's options
+func (t *pollTracker) add(poll types.MessageID, options []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.entries[poll]; !ok {
+		if len(t.order) >= maxPollsTracked {
+			delete(t.entries, t.order[0])
+			t.order = t.order[1:]
+		}
+		t.order = append(t.order, poll)
+	}
+	entry := &pollEntry{options: options, byHash: make(map[string]string, len(options)), byVoter: make(map[string][]string)}
+	for _, option := range options {
+		hash := sha256.Sum256([]byte(option))
+		entry.byHash[hex.EncodeToString(hash[:])] = option
+	}
+	t.entries[poll] = entry
+}
+
+// vote records a voter's selected option hashes and returns the selected
+// names with the new tallies, which are nil for an unknown poll
+func (t *pollTracker) vote(poll types.MessageID, voter types.JID, hashes [][]byte) ([]string, map[string]int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry := t.entries[poll]
+	selected := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		key := hex.EncodeToString(hash)
+		if entry != nil {
+			if name, ok := entry.byHash[key]; ok {
+				key = name
+			}
+		}
+		selected = append(selected, key)
+	}
+	if entry == nil {
+		return selected, nil
+	}
+
+	voterStr := voter.ToNonAD().String()
+	if len(selected) == 0 {
+		delete(entry.byVoter, voterStr)
+	} else {
+		entry.byVoter[voterStr] = selected
+	}
+	return selected, entry.tallies()
+}
+
+func (e *pollEntry) tallies() map[string]int {
+	tallies := make(map[string]int, len(e.options))
+	for _, option := range e.options {
+		tallies[option] = 0
+	}
+	for _, selected := range e.byVoter {
+		for _, option := range selected {
+			tallies[option]++
+		}
+	}
+	return tallies
+}
+
+// pollCreation returns the poll of a message, whichever version it uses
+func pollCreation(msg *waProto.Message) *waProto.PollCreationMessage {
+	for _, poll := range []*waProto.PollCreationMessage{
+		msg.GetPollCreationMessage(),
+		msg.GetPollCreationMessageV2(),
+		msg.GetPollCreationMessageV3(),
+		msg.GetPollCreationMessageV5(),
+	} {
+		if poll != nil {
+			return poll
+		}
+	}
+	return nil
+}
+
+// newPollEvent tracks a poll or decrypts and tallies a vote, returning the
+// event to emit, or nil for other messages
+func (c *Client) newPollEvent(evt *events.Message) interface{} {
+	if poll := pollCreation(evt.Message); poll != nil {
+		options := make([]string, 0, len(poll.GetOptions()))
+		for _, option := range poll.GetOptions() {
+			options = append(options, option.GetOptionName())
+		}
+		c.polls.add(evt.Info.ID, options)
+		return &PollCreatedEvent{
+			MessageID:       evt.Info.ID,
+			Chat:            evt.Info.Chat.String(),
+			Sender:          evt.Info.Sender.ToNonAD().String(),
+			FromMe:          evt.Info.IsFromMe,
+			Timestamp:       evt.Info.Timestamp.Unix(),
+			Name:            poll.GetName(),
+			Options:         options,
+			SelectableCount: int(poll.GetSelectableOptionsCount()),
+		}
+	}
+
+	update := evt.Message.GetPollUpdateMessage()
+	if update == nil {
+		return nil
+	}
+	out := &PollVoteEvent{
+		PollID:    update.GetPollCreationMessageKey().GetID(),
+		VoteID:    evt.Info.ID,
+		Chat:      evt.Info.Chat.String(),
+		Voter:     evt.Info.Sender.ToNonAD().String(),
+		FromMe:    evt.Info.IsFromMe,
+		Timestamp: evt.Info.Timestamp.Unix(),
+		Selected:  []string{},
+	}
+	if ms := update.GetSenderTimestampMS(); ms > 0 {
+		out.Timestamp = ms / 1000
+	}
+	vote, err := c.client.DecryptPollVote(c.ctx, evt)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Selected, out.Tallies = c.polls.vote(out.PollID, evt.Info.Sender, vote.GetSelectedOptions())
+	return out
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// preKeyCheckInterval is how often the server's prekey count is checked
+const preKeyCheckInterval = time.Hour
+
+// defaultPreKeyThreshold is the prekey count below which the bridge refills;
+// whatsmeow itself only uploads once fewer than 5 are left
+const defaultPreKeyThreshold = 20
+
+// PreKeyLowEvent is emitted when the server holds fewer one-time prekeys
+// than the threshold. Without prekeys, contacts can't start new sessions
+// with this device, so the bridge uploads a batch right away.
+type PreKeyLowEvent struct {
+	ServerCount int `json:"server_count" pb:"1"`
+	Threshold   int `json:"threshold" pb:"2"`
+}
+
+// PreKeysUploadedEvent is emitted after the bridge refilled prekeys
+type PreKeysUploadedEvent struct {
+	Before int    `json:"before" pb:"1"`
+	After  int    `json:"after" pb:"2"`
+	Error  string `json:"error,omitempty" pb:"3"`
+}
+
+// SetPreKeyThreshold sets the server prekey count that triggers a refill;
+// zero means 20
+func (c *Client) SetPreKeyThreshold(threshold int) error {
+	if threshold < 0 {
+		return argErrorf("prekey threshold must not be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.PreKeyThreshold = threshold
+	return nil
+}
+
+// preKeyThreshold returns the configured refill threshold
+func (c *Client) preKeyThreshold() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.config.PreKeyThreshold > 0 {
+		return c.config.PreKeyThreshold
+	}
+	return defaultPreKeyThreshold
+}
+
+// watchPreKeys checks the server prekey count periodically and after each connect
+func (c *Client) watchPreKeys() {
+	ticker := time.NewTicker(preKeyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.pkCheck:
+		}
+		c.checkPreKeys(c.ctx)
+	}
+}
+
+// triggerPreKeyCheck schedules a prekey count check without blocking
+func (c *Client) triggerPreKeyCheck() {
+	select {
+	case c.pkCheck <- struct{}{}:
+	default:
+	}
+}
+
+// checkPreKeys emits prekey_low and refills when the server runs short
+func (c *Client) checkPreKeys(ctx context.Context) {
+	if !c.client.IsLoggedIn() || c.frozen.Load() {
+		return
+	}
+	internals := c.client.DangerousInternals()
+	count, err := internals.GetServerPreKeyCount(ctx)
+	threshold := c.preKeyThreshold()
+	if err != nil || count >= threshold {
+		return
+	}
+
+	c.emit("prekey_low", PreKeyLowEvent{ServerCount: count, Threshold: threshold})
+	after, err := c.UploadPreKeys(ctx)
+	evt := PreKeysUploadedEvent{Before: count, After: after}
+	if err != nil {
+		evt.Error = err.Error()
+	}
+	c.emit("prekeys_uploaded", evt)
+}
+
+// UploadPreKeys uploads a batch of one-time prekeys and returns the server's
+// count afterwards. whatsmeow skips the upload when it uploaded less than
+// 10 minutes ago and the server already holds a full batch.
+func (c *Client) UploadPreKeys(ctx context.Context) (int, error) {
+	if err := c.checkOutgoing(); err != nil {
+		return 0, err
+	}
+
+	internals := c.client.DangerousInternals()
+	internals.UploadPreKeys(ctx, false)
+	count, err := internals.GetServerPreKeyCount(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get prekey count: %w", err)
+	}
+	return count, nil
+}
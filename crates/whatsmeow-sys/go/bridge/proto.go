@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Envelope field numbers, see proto/events.proto
+const (
+	protoEventType      protowire.Number = 1
+	protoEventTimestamp protowire.Number = 2
+	protoEventPayload   protowire.Number = 3
+	protoEventJSON      protowire.Number = 4
+	protoEventSeq       protowire.Number = 5
+)
+
+// protoField is a struct field carrying a `pb:"<number>"` tag
+type protoField struct {
+	index int
+	num   protowire.Number
+}
+
+var protoFields sync.Map // reflect.Type -> []protoField
+
+// NewProtoEvent wraps a payload in the protobuf Event envelope. Payloads with
+// a schema (bridge structs with pb tags) are encoded into the payload field;
+// everything else, such as raw whatsmeow events, is carried as JSON.
+func NewProtoEvent(eventType string, seq uint64, timestamp int64, payload interface{}) ([]byte, error) {
+	// The payload is encoded into pooled scratch space first so the event
+	// can be allocated once at its final size
+	buf := getEventBuffer()
+	defer putEventBuffer(buf)
+
+	var data []byte
+	field := protoEventJSON
+	if hasProtoSchema(reflect.TypeOf(payload)) {
+		msg, err := appendProtoMessage(buf.AvailableBuffer(), reflect.ValueOf(payload))
+		if err != nil {
+			return nil, err
+		}
+		data, field = msg, protoEventPayload
+	} else {
+		if err := json.NewEncoder(buf).Encode(payload); err != nil {
+			return nil, err
+		}
+		// Encode terminates the value with a newline
+		data = buf.Bytes()[:buf.Len()-1]
+	}
+
+	b := make([]byte, 0, 32+len(eventType)+len(data))
+	b = protowire.AppendTag(b, protoEventType, protowire.BytesType)
+	b = protowire.AppendString(b, eventType)
+	b = protowire.AppendTag(b, protoEventSeq, protowire.VarintType)
+	b = protowire.AppendVarint(b, seq)
+	b = protowire.AppendTag(b, protoEventTimestamp, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(timestamp))
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, data), nil
+}
+
+// hasProtoSchema reports whether t is a (pointer to a) struct with pb tags
+func hasProtoSchema(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && len(protoFieldsOf(t)) > 0
+}
+
+func protoFieldsOf(t reflect.Type) []protoField {
+	if cached, ok := protoFields.Load(t); ok {
+		return cached.([]protoField)
+	}
+
+	var fields []protoField
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("pb")
+		if tag == "" {
+			continue
+		}
+		num, err := strconv.Atoi(tag)
+		if err != nil || !protowire.Number(num).IsValid() {
+			panic(fmt.Sprintf("invalid pb tag %q on %s.%s", tag, t.Name(), t.Field(i).Name))
+		}
+		fields = append(fields, protoField{index: i, num: protowire.Number(num)})
+	}
+
+	protoFields.Store(t, fields)
+	return fields
+}
+
+// appendProtoMessage appends the fields of struct v in proto3 encoding
+func appendProtoMessage(b []byte, v reflect.Value) ([]byte, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return b, nil
+		}
+		v = v.Elem()
+	}
+
+	var err error
+	for _, f := range protoFieldsOf(v.Type()) {
+		b, err = appendProtoField(b, f.num, v.Field(f.index), false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendProtoField encodes one value; zero scalars are skipped unless the
+// value is an element of a repeated field or map entry
+func appendProtoField(b []byte, num protowire.Number, v reflect.Value, keepZero bool) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 && !keepZero {
+			return b, nil
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendString(b, v.String()), nil
+	case reflect.Bool:
+		if !v.Bool() && !keepZero {
+			return b, nil
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() == 0 && !keepZero {
+			return b, nil
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() == 0 && !keepZero {
+			return b, nil
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, v.Uint()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Len() == 0 && !keepZero {
+				return b, nil
+			}
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			return protowire.AppendBytes(b, v.Bytes()), nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if b, err = appendProtoField(b, num, v.Index(i), true); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			entry, err := appendProtoField(nil, 1, key, true)
+			if err != nil {
+				return nil, err
+			}
+			if entry, err = appendProtoField(entry, 2, v.MapIndex(key), true); err != nil {
+				return nil, err
+			}
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, entry)
+		}
+		return b, nil
+	case reflect.Struct, reflect.Ptr:
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return b, nil
+		}
+		msg, err := appendProtoMessage(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, msg), nil
+	default:
+		return nil, fmt.Errorf("unsupported protobuf field kind %s", v.Kind())
+	}
+}
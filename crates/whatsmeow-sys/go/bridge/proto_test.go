@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protoSchemaPayloads holds one payload of every bridge type with pb tags;
+// each is decoded as the events.proto message of the same name
+var protoSchemaPayloads = []interface{}{
+	&FrozenEvent{},
+	&HistorySyncChunkEvent{},
+	&HistoryConversation{},
+	&HistoryMessage{},
+	&HistoryParticipant{},
+	&HistorySyncEvent{},
+	&PlayedBy{},
+	&PlayedListener{},
+	&ReactionCounts{},
+	&StarEvent{},
+	&LabelEditEvent{},
+	&LabelAssociationEvent{},
+	&CompanionDeviceEvent{},
+	&ConnectFailureEvent{},
+	&TemporaryBanEvent{},
+	&ClientOutdatedEvent{},
+	&ReconnectAttemptEvent{},
+	&KeepAliveTimeoutEvent{},
+	&KeepAliveRestoredEvent{},
+	&StreamReplacedEvent{},
+	&PreKeyLowEvent{},
+	&PreKeysUploadedEvent{},
+	&IdentityChangeEvent{},
+	&UndecryptableEvent{},
+	&RetryRequestEvent{},
+	&CallEvent{},
+	&StatusUpdateEvent{},
+	&NewsletterMessageEvent{},
+	&OutboxEvent{},
+	&SendResultEvent{},
+	&MessageEditedEvent{},
+	&MessageRevokedEvent{},
+	&ReactionEvent{},
+	&PollCreatedEvent{},
+	&PollVoteEvent{},
+	&ContactsSyncedEvent{},
+	&ContactInfo{},
+	&QRTimeoutEvent{},
+	&GroupMemberAddModeEvent{},
+	&PictureUpdateEvent{},
+	&UserAboutEvent{},
+	&ChatArchiveEvent{},
+	&ChatPinEvent{},
+	&ChatMuteEvent{},
+	&ChatDeletedEvent{},
+	&ChatClearedEvent{},
+	&OfflineSyncPreviewEvent{},
+	&OfflineSyncCompletedEvent{},
+}
+
+var (
+	protoFieldLine = regexp.MustCompile(`^(repeated )?(\w+) (\w+) = (\d+);$`)
+	protoMapLine   = regexp.MustCompile(`^map<(\w+), (\w+)> (\w+) = (\d+);$`)
+	protoScalars   = map[string]descriptorpb.FieldDescriptorProto_Type{
+		"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+		"bytes":  descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+		"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+		"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+		"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+		"uint32": descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+		"uint64": descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	}
+)
+
+// protoFieldDescriptor describes a singular or repeated field of a type
+// named in events.proto
+func protoFieldDescriptor(name, typeName string, num int, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(int32(num)),
+		Label:    label.Enum(),
+	}
+	if scalar, ok := protoScalars[typeName]; ok {
+		field.Type = scalar.Enum()
+	} else {
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String(".whatsmeow.bridge." + typeName)
+	}
+	return field
+}
+
+// loadEventsProto parses proto/events.proto, which only uses top-level
+// messages with scalar, message, repeated and map fields
+func loadEventsProto(t *testing.T) protoreflect.FileDescriptor {
+	f, err := os.Open("../proto/events.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("events.proto"),
+		Package: proto.String("whatsmeow.bridge"),
+		Syntax:  proto.String("proto3"),
+	}
+	var msg *descriptorpb.DescriptorProto
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "syntax "), strings.HasPrefix(line, "package "), strings.HasPrefix(line, "reserved "):
+		case strings.HasPrefix(line, "message ") && strings.HasSuffix(line, " {"):
+			msg = &descriptorpb.DescriptorProto{Name: proto.String(strings.Fields(line)[1])}
+			file.MessageType = append(file.MessageType, msg)
+		case line == "}":
+			msg = nil
+		case msg != nil && protoMapLine.MatchString(line):
+			m := protoMapLine.FindStringSubmatch(line)
+			num, _ := strconv.Atoi(m[4])
+			entry := strings.ToUpper(m[3][:1]) + m[3][1:] + "Entry"
+			msg.NestedType = append(msg.NestedType, &descriptorpb.DescriptorProto{
+				Name: proto.String(entry),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					protoFieldDescriptor("key", m[1], 1, optional),
+					protoFieldDescriptor("value", m[2], 2, optional),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			})
+			field := protoFieldDescriptor(m[3], "", num, repeated)
+			field.TypeName = proto.String(".whatsmeow.bridge." + msg.GetName() + "." + entry)
+			msg.Field = append(msg.Field, field)
+		case msg != nil && protoFieldLine.MatchString(line):
+			m := protoFieldLine.FindStringSubmatch(line)
+			num, _ := strconv.Atoi(m[4])
+			label := optional
+			if m[1] != "" {
+				label = repeated
+			}
+			msg.Field = append(msg.Field, protoFieldDescriptor(m[3], m[2], num, label))
+		default:
+			t.Fatalf("events.proto:%d: unsupported syntax %q", lineNo, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	desc, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatalf("events.proto: %v", err)
+	}
+	return desc
+}
+
+// fillProtoSample sets every pb field of struct v to a distinct non-zero
+// value, with two elements in slices and maps
+func fillProtoSample(v reflect.Value, next *int) {
+	for _, f := range protoFieldsOf(v.Type()) {
+		fillProtoValue(v.Field(f.index), next)
+	}
+}
+
+func fillProtoValue(v reflect.Value, next *int) {
+	*next++
+	n := *next
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(fmt.Sprintf("value %d", n))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// Negative values check the sign survives the varint encoding
+		v.SetInt(int64(-n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(n))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		for i := 0; i < 2; i++ {
+			fillProtoValue(v.Index(i), next)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := 0; i < 2; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			value := reflect.New(v.Type().Elem()).Elem()
+			fillProtoValue(key, next)
+			fillProtoValue(value, next)
+			v.SetMapIndex(key, value)
+		}
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillProtoValue(v.Elem(), next)
+	case reflect.Struct:
+		fillProtoSample(v, next)
+	}
+}
+
+// checkProtoMessage compares the pb fields of struct v with the decoded
+// message, by number, name and value
+func checkProtoMessage(t *testing.T, path string, v reflect.Value, msg protoreflect.Message) {
+	t.Helper()
+	if unknown := msg.GetUnknown(); len(unknown) > 0 {
+		t.Errorf("%s: %d bytes of fields missing from events.proto or of the wrong wire type", path, len(unknown))
+	}
+	for _, f := range protoFieldsOf(v.Type()) {
+		sf := v.Type().Field(f.index)
+		fieldPath := path + "." + sf.Name
+		fd := msg.Descriptor().Fields().ByNumber(f.num)
+		if fd == nil {
+			t.Errorf("%s: field %d is not in events.proto", fieldPath, f.num)
+			continue
+		}
+		if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != string(fd.Name()) {
+			t.Errorf("%s: field %d is %q in JSON but %q in events.proto", fieldPath, f.num, name, fd.Name())
+		}
+		checkProtoValue(t, fieldPath, fd, v.Field(f.index), msg.Get(fd))
+	}
+}
+
+func checkProtoValue(t *testing.T, path string, fd protoreflect.FieldDescriptor, v reflect.Value, value protoreflect.Value) {
+	t.Helper()
+	switch {
+	case fd.IsMap():
+		m := value.Map()
+		if v.Kind() != reflect.Map || m.Len() != v.Len() {
+			t.Errorf("%s: decoded %d map entries from %s", path, m.Len(), v.Type())
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := protoreflect.ValueOf(iter.Key().Interface()).MapKey()
+			checkProtoScalar(t, path+"["+fmt.Sprint(iter.Key())+"]", fd.MapValue(), iter.Value(), m.Get(key))
+		}
+	case fd.IsList() && !(v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8):
+		list := value.List()
+		if v.Kind() != reflect.Slice || list.Len() != v.Len() {
+			t.Errorf("%s: decoded %d list elements from %s", path, list.Len(), v.Type())
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			checkProtoScalar(t, fmt.Sprintf("%s[%d]", path, i), fd, v.Index(i), list.Get(i))
+		}
+	default:
+		if fd.IsList() {
+			t.Errorf("%s: %s is repeated in events.proto", path, v.Type())
+			return
+		}
+		checkProtoScalar(t, path, fd, v, value)
+	}
+}
+
+// checkProtoScalar compares one Go value with a decoded singular value
+func checkProtoScalar(t *testing.T, path string, fd protoreflect.FieldDescriptor, v reflect.Value, value protoreflect.Value) {
+	t.Helper()
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	var ok bool
+	switch v.Kind() {
+	case reflect.String:
+		ok = fd.Kind() == protoreflect.StringKind && value.String() == v.String()
+	case reflect.Bool:
+		ok = fd.Kind() == protoreflect.BoolKind && value.Bool() == v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ok = (fd.Kind() == protoreflect.Int32Kind || fd.Kind() == protoreflect.Int64Kind) && value.Int() == v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		ok = (fd.Kind() == protoreflect.Uint32Kind || fd.Kind() == protoreflect.Uint64Kind) && value.Uint() == v.Uint()
+	case reflect.Slice:
+		ok = fd.Kind() == protoreflect.BytesKind && string(value.Bytes()) == string(v.Bytes())
+	case reflect.Struct:
+		if fd.Kind() != protoreflect.MessageKind {
+			t.Errorf("%s: %s is %s in events.proto", path, v.Type(), fd.Kind())
+			return
+		}
+		checkProtoMessage(t, path, v, value.Message())
+		return
+	}
+	if !ok {
+		t.Errorf("%s: %s %v decoded as %s %v", path, v.Type(), v, fd.Kind(), value)
+	}
+}
+
+// TestProtoSchema encodes every payload type and decodes it with the schema
+// of proto/events.proto, which hosts generate their decoders from
+func TestProtoSchema(t *testing.T) {
+	schema := loadEventsProto(t)
+	covered := map[string]bool{"Event": true}
+	for _, payload := range protoSchemaPayloads {
+		v := reflect.ValueOf(payload).Elem()
+		name := v.Type().Name()
+		covered[name] = true
+		t.Run(name, func(t *testing.T) {
+			desc := schema.Messages().ByName(protoreflect.Name(name))
+			if desc == nil {
+				t.Fatalf("events.proto has no message %s", name)
+			}
+			next := 0
+			fillProtoSample(v, &next)
+			data, err := appendProtoMessage(nil, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := dynamicpb.NewMessage(desc)
+			if err := proto.Unmarshal(data, msg); err != nil {
+				t.Fatal(err)
+			}
+			checkProtoMessage(t, name, v, msg)
+		})
+	}
+
+	messages := schema.Messages()
+	for i := 0; i < messages.Len(); i++ {
+		if name := string(messages.Get(i).Name()); !covered[name] {
+			t.Errorf("events.proto message %s has no payload in protoSchemaPayloads", name)
+		}
+	}
+}
+
+// TestProtoEventEnvelope checks the Event envelope around schema payloads
+// and the JSON fallback for payloads without one
+func TestProtoEventEnvelope(t *testing.T) {
+	envelope := loadEventsProto(t).Messages().ByName("Event")
+	fields := envelope.Fields()
+	tests := []struct {
+		name      string
+		payload   interface{}
+		wantField protoreflect.Name
+		want      []byte
+	}{
+		{"schema", &FrozenEvent{Frozen: true}, "payload", []byte{0x08, 0x01}},
+		{"json", map[string]int{"count": 3}, "json", []byte(`{"count":3}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := NewProtoEvent("frozen", 42, 1700000000000, tt.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := dynamicpb.NewMessage(envelope)
+			if err := proto.Unmarshal(data, msg); err != nil {
+				t.Fatal(err)
+			}
+			if len(msg.GetUnknown()) > 0 {
+				t.Error("envelope has fields missing from events.proto")
+			}
+			if got := msg.Get(fields.ByName("type")).String(); got != "frozen" {
+				t.Errorf("type = %q, want frozen", got)
+			}
+			if got := msg.Get(fields.ByName("seq")).Uint(); got != 42 {
+				t.Errorf("seq = %d, want 42", got)
+			}
+			if got := msg.Get(fields.ByName("timestamp")).Int(); got != 1700000000000 {
+				t.Errorf("timestamp = %d, want 1700000000000", got)
+			}
+			if got := msg.Get(fields.ByName(tt.wantField)).Bytes(); string(got) != string(tt.want) {
+				t.Errorf("%s = %q, want %q", tt.wantField, got, tt.want)
+			}
+		})
+	}
+}
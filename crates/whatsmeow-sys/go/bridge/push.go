@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// Push notification platforms accepted by RegisterPush
+const (
+	PushPlatformFCM  = "fcm"
+	PushPlatformAPNs = "apns"
+	PushPlatformWeb  = "web"
+)
+
+// parsePushConfig decodes the JSON push settings of a platform: {"token"}
+// for FCM, {"token", "voip_token", "msg_id_enc_key"} for APNs and
+// {"endpoint", "auth", "p256dh"} for web push, with binary keys in base64
+func parsePushConfig(platform, config string) (whatsmeow.PushConfig, error) {
+	var pc whatsmeow.PushConfig
+	switch strings.ToLower(platform) {
+	case PushPlatformFCM:
+		pc = &whatsmeow.FCMPushConfig{}
+	case PushPlatformAPNs:
+		pc = &whatsmeow.APNsPushConfig{}
+	case PushPlatformWeb:
+		pc = &whatsmeow.WebPushConfig{}
+	default:
+		return nil, argErrorf("unknown push platform %q", platform)
+	}
+	if err := json.Unmarshal([]byte(config), pc); err != nil {
+		return nil, argErrorf("invalid push config: %w", err)
+	}
+
+	switch pc := pc.(type) {
+	case *whatsmeow.FCMPushConfig:
+		if pc.Token == "" {
+			return nil, argErrorf("push token is required")
+		}
+	case *whatsmeow.APNsPushConfig:
+		if pc.Token == "" {
+			return nil, argErrorf("push token is required")
+		}
+	case *whatsmeow.WebPushConfig:
+		if pc.Endpoint == "" || len(pc.Auth) == 0 || len(pc.P256DH) == 0 {
+			return nil, argErrorf("web push needs endpoint, auth and p256dh")
+		}
+	}
+	return pc, nil
+}
+
+// RegisterPush registers a push token with the server, which then sends
+// wake-up pushes for new messages while the websocket is disconnected
+func (c *Client) RegisterPush(ctx context.Context, platform, config string) error {
+	pc, err := parsePushConfig(platform, config)
+	if err != nil {
+		return err
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return err
+	}
+
+	if err := c.client.RegisterForPushNotifications(ctx, pc); err != nil {
+		return fmt.Errorf("push registration failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// qrDisconnectWait bounds how long a QR restart waits for whatsmeow to drop
+// the socket of the expired login
+const qrDisconnectWait = 5 * time.Second
+
+// QRTimeoutEvent is emitted when every QR code of a login expired unscanned.
+// Regenerating is set when the bridge restarts the login for fresh codes;
+// Attempt counts the automatic restarts since the last manual one.
+type QRTimeoutEvent struct {
+	Regenerating bool `json:"regenerating" pb:"1"`
+	Attempt      int  `json:"attempt" pb:"2"`
+}
+
+// watchQR forwards the items of a QR channel and handles its timeout
+func (c *Client) watchQR(qrChan <-chan whatsmeow.QRChannelItem) {
+	timedOut := false
+	for evt := range qrChan {
+		if evt.Event == whatsmeow.QRChannelTimeout.Event {
+			timedOut = true
+			continue
+		}
+		c.handleEvent(evt)
+	}
+	if !timedOut {
+		return
+	}
+
+	c.mu.Lock()
+	attempt := c.qrRetries + 1
+	regenerate := attempt <= c.config.QRRefresh && !c.closing.Load()
+	if regenerate {
+		c.qrRetries = attempt
+	}
+	c.mu.Unlock()
+
+	c.emit("qr_timeout", &QRTimeoutEvent{Regenerating: regenerate, Attempt: attempt})
+	if regenerate {
+		c.restartQR()
+	}
+}
+
+// restartQR starts a new QR login once the expired one is disconnected
+func (c *Client) restartQR() {
+	deadline := time.Now().Add(qrDisconnectWait)
+	for c.client.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if c.ctx.Err() != nil {
+		return
+	}
+	c.Disconnect()
+	c.Connect()
+}
+
+// RefreshQR restarts an unpaired login so it shows fresh QR codes, e.g.
+// after a qr_timeout event without regeneration. It also resets the count
+// of automatic restarts.
+func (c *Client) RefreshQR() error {
+	if c.client.Store.ID != nil {
+		return argErrorf("client is already paired")
+	}
+
+	c.mu.Lock()
+	c.qrRetries = 0
+	c.mu.Unlock()
+
+	go c.restartQR()
+	return nil
+}
+
+// SetQRRefresh sets how many times a login whose QR codes all expired is
+// restarted automatically; zero only emits qr_timeout
+func (c *Client) SetQRRefresh(limit int) error {
+	if limit < 0 {
+		return argErrorf("QR refresh limit must not be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.QRRefresh = limit
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ErrRateLimited is returned by sends over the configured rate in reject mode
+var ErrRateLimited = errors.New("outgoing rate limit exceeded")
+
+// maxIdleChatBuckets bounds the per-chat buckets kept before full (idle)
+// ones are forgotten
+const maxIdleChatBuckets = 1024
+
+// RateLimitConfig paces outgoing messages with token buckets, one for all
+// chats and one per chat
+type RateLimitConfig struct {
+	// Global and PerChat are sustained rates in messages per minute; zero
+	// disables that limit
+	Global  int
+	PerChat int
+	// Burst is how many messages may go out at once before pacing starts;
+	// zero means 1
+	Burst int
+	// Reject fails sends over the limit with ErrRateLimited instead of
+	// holding them until a token is available
+	Reject bool
+}
+
+// validate checks the limiter settings
+func (cfg RateLimitConfig) validate() error {
+	if cfg.Global < 0 || cfg.PerChat < 0 || cfg.Burst < 0 {
+		return argErrorf("rate limits must not be negative")
+	}
+	return nil
+}
+
+// tokenBucket holds up to burst tokens and refills at rate tokens per
+// second; tokens go negative for reservations that are waiting
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last update
+func (b *tokenBucket) refill(now time.Time, rate, burst float64) {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+}
+
+// wait is how long until the bucket has a token to spare
+func (b *tokenBucket) wait(rate float64) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// rateLimiter applies a RateLimitConfig to outgoing messages
+type rateLimiter struct {
+	mu     sync.Mutex
+	cfg    RateLimitConfig
+	global tokenBucket
+	chats  map[types.JID]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{chats: make(map[types.JID]*tokenBucket)}
+}
+
+// configure replaces the limits; buckets start full again
+func (l *rateLimiter) configure(cfg RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+	l.global = tokenBucket{}
+	l.chats = make(map[types.JID]*tokenBucket)
+}
+
+// reserve takes a token from the global and the chat bucket and returns how
+// long the send must wait for it. In reject mode it takes nothing and fails
+// if the send would have to wait.
+func (l *rateLimiter) reserve(chat types.JID) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cfg.Global == 0 && l.cfg.PerChat == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	burst := float64(max(l.cfg.Burst, 1))
+	globalRate, chatRate := float64(l.cfg.Global)/60, float64(l.cfg.PerChat)/60
+
+	var wait time.Duration
+	if globalRate > 0 {
+		l.global.refill(now, globalRate, burst)
+		wait = l.global.wait(globalRate)
+	}
+	var bucket *tokenBucket
+	if chatRate > 0 {
+		bucket = l.chatBucket(chat, now, chatRate, burst)
+		wait = max(wait, bucket.wait(chatRate))
+	}
+
+	if wait > 0 && l.cfg.Reject {
+		return 0, ErrRateLimited
+	}
+	if globalRate > 0 {
+		l.global.tokens--
+	}
+	if bucket != nil {
+		bucket.tokens--
+	}
+	return wait, nil
+}
+
+// chatBucket returns the refilled bucket of a chat, forgetting idle buckets
+// once there are too many
+func (l *rateLimiter) chatBucket(chat types.JID, now time.Time, rate, burst float64) *tokenBucket {
+	if len(l.chats) >= maxIdleChatBuckets {
+		for jid, b := range l.chats {
+			if b.refill(now, rate, burst); b.tokens >= burst {
+				delete(l.chats, jid)
+			}
+		}
+	}
+	bucket, ok := l.chats[chat]
+	if !ok {
+		bucket = &tokenBucket{}
+		l.chats[chat] = bucket
+	}
+	bucket.refill(now, rate, burst)
+	return bucket
+}
+
+// release returns a reserved token when the send gave up waiting for it
+func (l *rateLimiter) release(chat types.JID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cfg.Global > 0 {
+		l.global.tokens++
+	}
+	if bucket, ok := l.chats[chat]; ok && l.cfg.PerChat > 0 {
+		bucket.tokens++
+	}
+}
+
+// pace holds a send to chat until the rate limits allow it, or fails it in
+// reject mode or when ctx ends first
+func (c *Client) pace(ctx context.Context, chat types.JID) error {
+	wait, err := c.limiter.reserve(chat)
+	if err != nil || wait == 0 {
+		return err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		c.limiter.release(chat)
+		return ctx.Err()
+	}
+}
+
+// SetRateLimit replaces the outgoing rate limits; all zero disables them
+func (c *Client) SetRateLimit(cfg RateLimitConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.config.RateLimit = cfg
+	c.mu.Unlock()
+	c.limiter.configure(cfg)
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		fresh    bool
+		elapsed  time.Duration
+		tokens   float64
+		want     float64
+		wantWait time.Duration
+	}{
+		{"new starts full", true, 0, 0, 3, 0},
+		{"refills", false, time.Second, 0, 2, 0},
+		{"partial token", false, 500 * time.Millisecond, 0, 1, 0},
+		{"caps at burst", false, time.Minute, 0, 3, 0},
+		{"reserved", false, 0, -1, -1, time.Second},
+		{"reserved refilling", false, 500 * time.Millisecond, -1, 0, 500 * time.Millisecond},
+	}
+	const rate, burst = 2, 3
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &tokenBucket{}
+			if !tt.fresh {
+				b.tokens, b.last = tt.tokens, start
+			}
+			b.refill(start.Add(tt.elapsed), rate, burst)
+			if b.tokens != tt.want {
+				t.Errorf("tokens = %v, want %v", b.tokens, tt.want)
+			}
+			if wait := b.wait(rate); wait != tt.wantWait {
+				t.Errorf("wait = %v, want %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+// reserveAll reserves n sends to chat and returns the waits
+func reserveAll(t *testing.T, l *rateLimiter, chat types.JID, n int) []time.Duration {
+	t.Helper()
+	waits := make([]time.Duration, n)
+	for i := range waits {
+		wait, err := l.reserve(chat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waits[i] = wait
+	}
+	return waits
+}
+
+func TestRateLimiter(t *testing.T) {
+	alice := types.JID{User: "15551111111", Server: types.DefaultUserServer}
+	bob := types.JID{User: "15552222222", Server: types.DefaultUserServer}
+
+	l := newRateLimiter()
+	if waits := reserveAll(t, l, alice, 5); waits[4] != 0 {
+		t.Errorf("unlimited sends waited %v", waits)
+	}
+
+	// 60 a minute with a burst of 2: two go out, then one a second
+	l.configure(RateLimitConfig{PerChat: 60, Burst: 2})
+	waits := reserveAll(t, l, alice, 4)
+	if waits[0] != 0 || waits[1] != 0 {
+		t.Errorf("burst waited %v", waits[:2])
+	}
+	if waits[2] < 900*time.Millisecond || waits[2] > time.Second || waits[3] < 1900*time.Millisecond || waits[3] > 2*time.Second {
+		t.Errorf("paced sends waited %v, want about 1s and 2s", waits[2:])
+	}
+	if wait, _ := l.reserve(bob); wait != 0 {
+		t.Errorf("another chat waited %v", wait)
+	}
+
+	// Released reservations give their token back
+	l.configure(RateLimitConfig{PerChat: 60})
+	reserveAll(t, l, alice, 1)
+	wait, _ := l.reserve(alice)
+	l.release(alice)
+	if again, _ := l.reserve(alice); again > wait {
+		t.Errorf("wait after a release = %v, want at most %v", again, wait)
+	}
+
+	// The global limit spans chats
+	l.configure(RateLimitConfig{Global: 60})
+	reserveAll(t, l, alice, 1)
+	if wait, _ := l.reserve(bob); wait == 0 {
+		t.Error("the global limit didn't pace another chat")
+	}
+}
+
+func TestRateLimiterReject(t *testing.T) {
+	chat := types.JID{User: "15551111111", Server: types.DefaultUserServer}
+	l := newRateLimiter()
+	l.configure(RateLimitConfig{Global: 60, Burst: 1, Reject: true})
+	reserveAll(t, l, chat, 1)
+	for i := 0; i < 2; i++ {
+		if _, err := l.reserve(chat); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("send over the limit = %v, want %v", err, ErrRateLimited)
+		}
+	}
+	// Rejected sends take no token, so the bucket is only one token short
+	if l.global.tokens < -0.1 || l.global.tokens > 0.1 {
+		t.Errorf("tokens after rejections = %v, want 0", l.global.tokens)
+	}
+}
+
+func TestRateLimiterForgetsIdleChats(t *testing.T) {
+	l := newRateLimiter()
+	l.configure(RateLimitConfig{PerChat: 60})
+	for i := 0; i < maxIdleChatBuckets; i++ {
+		l.chats[types.JID{User: fmt.Sprintf("1555%07d", i), Server: types.DefaultUserServer}] = &tokenBucket{tokens: 1, last: time.Now()}
+	}
+	reserveAll(t, l, types.JID{User: "15551111111", Server: types.DefaultUserServer}, 1)
+	if len(l.chats) != 1 {
+		t.Errorf("%d chat buckets kept, want only the active one", len(l.chats))
+	}
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	for _, cfg := range []RateLimitConfig{{Global: -1}, {PerChat: -1}, {Burst: -1}} {
+		if err := cfg.validate(); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("validate(%+v) = %v, want an argument error", cfg, err)
+		}
+	}
+	if err := (RateLimitConfig{Global: 30, PerChat: 10, Burst: 5}).validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// maxReactionsTracked bounds how many messages keep aggregated reaction counts
+const maxReactionsTracked = 4096
+
+// ReactionCounts is the aggregated reaction state of a message
+type ReactionCounts struct {
+	MessageID string         `json:"message_id" pb:"1"`
+	Chat      string         `json:"chat" pb:"2"`
+	Reactor   string         `json:"reactor,omitempty" pb:"3"`
+	Emoji     string         `json:"emoji,omitempty" pb:"4"`
+	Counts    map[string]int `json:"counts" pb:"5"`
+}
+
+// ReactionEvent is emitted instead of a message event for a reaction; an
+// empty Emoji means the reactor removed their reaction
+type ReactionEvent struct {
+	// MessageID is the message reacted to, ReactionID the reaction itself
+	MessageID  string `json:"message_id" pb:"1"`
+	ReactionID string `json:"reaction_id" pb:"2"`
+	Chat       string `json:"chat" pb:"3"`
+	Reactor    string `json:"reactor" pb:"4"`
+	Emoji      string `json:"emoji,omitempty" pb:"5"`
+	FromMe     bool   `json:"from_me" pb:"6"`
+	// TargetFromMe is set when the reacted-to message is ours
+	TargetFromMe bool  `json:"target_from_me" pb:"7"`
+	Timestamp    int64 `json:"timestamp" pb:"8"`
+}
+
+// newReactionEvent describes a reaction message, or returns nil for other
+// messages
+func newReactionEvent(evt *events.Message) *ReactionEvent {
+	reaction := evt.Message.GetReactionMessage()
+	if reaction.GetKey().GetID() == "" {
+		return nil
+	}
+	ts := evt.Info.Timestamp.Unix()
+	if ms := reaction.GetSenderTimestampMS(); ms > 0 {
+		ts = ms / 1000
+	}
+	return &ReactionEvent{
+		MessageID:    reaction.GetKey().GetID(),
+		ReactionID:   evt.Info.ID,
+		Chat:         evt.Info.Chat.String(),
+		Reactor:      evt.Info.Sender.ToNonAD().String(),
+		Emoji:        reaction.GetText(),
+		FromMe:       evt.Info.IsFromMe,
+		TargetFromMe: reaction.GetKey().GetFromMe(),
+		Timestamp:    ts,
+	}
+}
+
+// reactionTracker keeps the latest reaction of each reactor per message
+type reactionTracker struct {
+	mu      sync.Mutex
+	entries map[types.MessageID]*reactionEntry
+	order   []types.MessageID
+}
+
+type reactionEntry struct {
+	chat      string
+	byReactor map[string]string
+}
+
+func newReactionTracker() *reactionTracker {
+	return &reactionTracker{entries: make(map[types.MessageID]*reactionEntry)}
+}
+
+// record stores a reaction (empty emoji removes it) and returns the new counts
+func (t *reactionTracker) record(chat types.JID, target types.MessageID, reactor types.JID, emoji string, normalize bool) *ReactionCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[target]
+	if !ok {
+		if len(t.order) >= maxReactionsTracked {
+			delete(t.entries, t.order[0])
+			t.order = t.order[1:]
+		}
+		entry = &reactionEntry{chat: chat.String(), byReactor: make(map[string]string)}
+		t.entries[target] = entry
+		t.order = append(t.order, target)
+	}
+
+	reactorStr := reactor.ToNonAD().String()
+	if emoji == "" {
+		delete(entry.byReactor, reactorStr)
+	} else {
+		entry.byReactor[reactorStr] = emoji
+	}
+
+	counts := entry.counts(target, normalize)
+	counts.Reactor = reactorStr
+	counts.Emoji = emoji
+	return counts
+}
+
+// get returns the aggregated counts of a message, or nil if none are tracked
+func (t *reactionTracker) get(target types.MessageID, normalize bool) *ReactionCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[target]
+	if !ok {
+		return nil
+	}
+	return entry.counts(target, normalize)
+}
+
+func (e *reactionEntry) counts(target types.MessageID, normalize bool) *ReactionCounts {
+	counts := make(map[string]int, len(e.byReactor))
+	for _, emoji := range e.byReactor {
+		if normalize {
+			emoji = normalizeEmoji(emoji)
+		}
+		counts[emoji]++
+	}
+	return &ReactionCounts{MessageID: target, Chat: e.chat, Counts: counts}
+}
+
+// normalizeEmoji strips skin-tone modifiers and variation selectors so that
+// e.g. 👍🏽 and 👍 count as the same reaction
+func normalizeEmoji(emoji string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0x1F3FB && r <= 0x1F3FF: // Fitzpatrick skin tones
+			return -1
+		case r == 0xFE0E || r == 0xFE0F: // text/emoji presentation selectors
+			return -1
+		}
+		return r
+	}, emoji)
+}
+
+// trackReaction aggregates an incoming reaction message
+func (c *Client) trackReaction(evt *events.Message) {
+	reaction := evt.Message.GetReactionMessage()
+	if reaction == nil {
+		return
+	}
+	target := reaction.GetKey().GetID()
+	if target == "" {
+		return
+	}
+
+	counts := c.reactions.record(evt.Info.Chat, target, evt.Info.Sender, reaction.GetText(), c.normalizeReactions())
+	c.emit("reaction_counts", counts)
+}
+
+// normalizeReactions reports whether reaction counts merge emoji variants
+func (c *Client) normalizeReactions() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.NormalizeReactions
+}
+
+// SetNormalizeReactions toggles emoji normalization in aggregated reaction counts
+func (c *Client) SetNormalizeReactions(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.NormalizeReactions = enabled
+}
+
+// Reactions returns the aggregated reaction counts of a message, or nil if unknown
+func (c *Client) Reactions(messageID string) *ReactionCounts {
+	return c.reactions.get(messageID, c.normalizeReactions())
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// maxPlayedTracked bounds how many group voice notes keep a played-by list
+const maxPlayedTracked = 2048
+
+// PlayedListener is a group participant who played a voice note
+type PlayedListener struct {
+	JID       string `json:"jid" pb:"1"`
+	Timestamp int64  `json:"timestamp" pb:"2"`
+}
+
+// PlayedBy aggregates played receipts for one group voice note
+type PlayedBy struct {
+	MessageID string           `json:"message_id" pb:"1"`
+	Chat      string           `json:"chat" pb:"2"`
+	Listeners []PlayedListener `json:"listeners" pb:"3"`
+}
+
+// playedTracker keeps played-by lists for recent group messages
+type playedTracker struct {
+	mu      sync.Mutex
+	entries map[types.MessageID]*PlayedBy
+	order   []types.MessageID
+}
+
+func newPlayedTracker() *playedTracker {
+	return &playedTracker{entries: make(map[types.MessageID]*PlayedBy)}
+}
+
+// record adds a listener to a message, returning a snapshot if it changed
+func (t *playedTracker) record(chat types.JID, id types.MessageID, listener types.JID, ts time.Time) *PlayedBy {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[id]
+	if !ok {
+		if len(t.order) >= maxPlayedTracked {
+			delete(t.entries, t.order[0])
+			t.order = t.order[1:]
+		}
+		entry = &PlayedBy{MessageID: id, Chat: chat.String()}
+		t.entries[id] = entry
+		t.order = append(t.order, id)
+	}
+
+	jid := listener.ToNonAD().String()
+	for _, l := range entry.Listeners {
+		if l.JID == jid {
+			return nil
+		}
+	}
+	entry.Listeners = append(entry.Listeners, PlayedListener{JID: jid, Timestamp: ts.Unix()})
+
+	return entry.snapshot()
+}
+
+// get returns a snapshot of the played-by list for a message
+func (t *playedTracker) get(id types.MessageID) *PlayedBy {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[id]
+	if !ok {
+		return nil
+	}
+	return entry.snapshot()
+}
+
+func (p *PlayedBy) snapshot() *PlayedBy {
+	cp := *p
+	cp.Listeners = append([]PlayedListener(nil), p.Listeners...)
+	return &cp
+}
+
+// trackPlayed aggregates played receipts from group participants
+func (c *Client) trackPlayed(evt *events.Receipt) {
+	if evt.Type != types.ReceiptTypePlayed || !evt.IsGroup {
+		return
+	}
+	for _, id := range evt.MessageIDs {
+		if update := c.played.record(evt.Chat, id, evt.Sender, evt.Timestamp); update != nil {
+			c.emit("played_by_update", update)
+		}
+	}
+}
+
+// PlayedBy returns which participants played a group voice note, or nil if unknown
+func (c *Client) PlayedBy(messageID string) *PlayedBy {
+	return c.played.get(messageID)
+}
+
+// ReadMark is a message to mark as read
+type ReadMark struct {
+	Chat string `json:"chat"`
+	// Sender is the author of a group message; empty in 1:1 chats
+	Sender    string `json:"sender,omitempty"`
+	MessageID string `json:"message_id"`
+}
+
+// readBatch is one read receipt stanza: the messages of one chat and sender
+type readBatch struct {
+	chat, sender types.JID
+	ids          []types.MessageID
+}
+
+// groupReadMarks coalesces marks into one batch per chat and sender, in the
+// order they first appear, dropping duplicate IDs
+func groupReadMarks(marks []ReadMark) ([]*readBatch, error) {
+	type batchKey struct{ chat, sender types.JID }
+	var batches []*readBatch
+	byKey := make(map[batchKey]*readBatch)
+	seen := make(map[batchKey]map[types.MessageID]bool)
+
+	for _, mark := range marks {
+		chat, err := types.ParseJID(mark.Chat)
+		if err != nil {
+			return nil, argErrorf("invalid chat JID %q: %w", mark.Chat, err)
+		}
+		var sender types.JID
+		if mark.Sender != "" {
+			if sender, err = types.ParseJID(mark.Sender); err != nil {
+				return nil, argErrorf("invalid sender JID %q: %w", mark.Sender, err)
+			}
+			sender = sender.ToNonAD()
+		}
+		if mark.MessageID == "" {
+			return nil, argErrorf("message ID is required")
+		}
+
+		key := batchKey{chat, sender}
+		batch, ok := byKey[key]
+		if !ok {
+			batch = &readBatch{chat: chat, sender: sender}
+			byKey[key] = batch
+			seen[key] = make(map[types.MessageID]bool)
+			batches = append(batches, batch)
+		}
+		if !seen[key][mark.MessageID] {
+			seen[key][mark.MessageID] = true
+			batch.ids = append(batch.ids, mark.MessageID)
+		}
+	}
+	return batches, nil
+}
+
+// MarkRead sends read receipts for many messages with one stanza per chat
+// and sender rather than one per message. It returns how many stanzas were
+// sent, which on error is the number sent before the failure.
+func (c *Client) MarkRead(ctx context.Context, marks []ReadMark) (int, error) {
+	if len(marks) == 0 {
+		return 0, argErrorf("no messages to mark read")
+	}
+	batches, err := groupReadMarks(marks)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for i, batch := range batches {
+		if err := c.client.MarkRead(ctx, batch.ids, now, batch.chat, batch.sender); err != nil {
+			return i, fmt.Errorf("failed to send read receipt: %w", err)
+		}
+	}
+	return len(batches), nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// Backoff defaults used when ReconnectConfig leaves a delay unset
+const (
+	defaultReconnectDelay    = 2 * time.Second
+	defaultMaxReconnectDelay = 2 * time.Minute
+)
+
+// ReconnectConfig controls automatic reconnection after an unexpected
+// disconnect. It replaces whatsmeow's own EnableAutoReconnect loop, whose
+// linear delay cannot be tuned, with an exponential backoff.
+type ReconnectConfig struct {
+	// Disabled turns automatic reconnection off
+	Disabled bool
+	// InitialDelay is the wait before the first attempt; zero means 2s
+	InitialDelay time.Duration
+	// MaxDelay caps the doubling delay between attempts; zero means 2m
+	MaxDelay time.Duration
+	// Jitter randomizes each delay by up to this fraction (0 to 1) either way
+	Jitter float64
+}
+
+// ReconnectAttemptEvent is emitted before each automatic reconnect attempt
+type ReconnectAttemptEvent struct {
+	Attempt   int    `json:"attempt" pb:"1"`
+	DelayMs   int64  `json:"delay_ms" pb:"2"`
+	LastError string `json:"last_error,omitempty" pb:"3"`
+}
+
+// validate checks the backoff parameters
+func (cfg ReconnectConfig) validate() error {
+	if cfg.InitialDelay < 0 || cfg.MaxDelay < 0 {
+		return argErrorf("reconnect delays must not be negative")
+	}
+	if cfg.Jitter < 0 || cfg.Jitter > 1 {
+		return argErrorf("reconnect jitter %v is outside 0..1", cfg.Jitter)
+	}
+	return nil
+}
+
+// delay returns the wait before the given attempt (starting at 1)
+func (cfg ReconnectConfig) delay(attempt int) time.Duration {
+	initial, maxDelay := cfg.InitialDelay, cfg.MaxDelay
+	if initial == 0 {
+		initial = defaultReconnectDelay
+	}
+	if maxDelay == 0 {
+		maxDelay = defaultMaxReconnectDelay
+	}
+	if maxDelay < initial {
+		maxDelay = initial
+	}
+
+	d := initial
+	for i := 1; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+
+	if cfg.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * cfg.Jitter * float64(d))
+	}
+	return d
+}
+
+// SetReconnectConfig replaces the reconnect settings; a running reconnect
+// loop picks them up from its next attempt
+func (c *Client) SetReconnectConfig(cfg ReconnectConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.Reconnect = cfg
+	if cfg.Disabled {
+		c.stopReconnect()
+	}
+	return nil
+}
+
+// reconnectConfig returns the configured reconnect settings
+func (c *Client) reconnectConfig() ReconnectConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config.Reconnect
+}
+
+// startReconnect launches the reconnect loop after an unexpected disconnect,
+// unless it is disabled or already running
+func (c *Client) startReconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.config.Reconnect.Disabled || c.halted || c.reconnect != nil || c.client.Store.ID == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.reconnect = cancel
+	go c.runReconnect(ctx)
+}
+
+// haltReconnect stops automatic reconnection until the next Connect, after
+// the server rejected the session in a way retrying can't fix
+func (c *Client) haltReconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.halted = true
+	c.stopReconnect()
+}
+
+// stopReconnect cancels a running reconnect loop. Callers must hold c.mu.
+func (c *Client) stopReconnect() {
+	if c.reconnect != nil {
+		c.reconnect()
+		c.reconnect = nil
+	}
+}
+
+// finishReconnect ends the loop after a successful Connect and reports
+// whether it may stop. A Disconnected event handled while the loop was still
+// registered didn't start a new one, so the connection is checked again
+// after the loop is cleared; if it already dropped, the loop carries on.
+func (c *Client) finishReconnect(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ctx.Err() != nil {
+		return true
+	}
+	// whatsmeow clears the socket before dispatching Disconnected, so a drop
+	// whose event was ignored shows here
+	if !c.client.IsConnected() {
+		return false
+	}
+	c.stopReconnect()
+	return true
+}
+
+// runReconnect retries Connect with backoff until it succeeds, the client is
+// logged out or the loop is cancelled by Disconnect
+func (c *Client) runReconnect(ctx context.Context) {
+	defer func() {
+		c.mu.Lock()
+		if ctx.Err() == nil {
+			c.stopReconnect()
+		}
+		c.mu.Unlock()
+	}()
+
+	var lastErr string
+	for attempt := 1; ; attempt++ {
+		delay := c.reconnectConfig().delay(attempt)
+		c.emit("reconnect_attempt", ReconnectAttemptEvent{
+			Attempt:   attempt,
+			DelayMs:   delay.Milliseconds(),
+			LastError: lastErr,
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if c.client.Store.ID == nil {
+			return
+		}
+
+		c.stats.attempts.Add(1)
+		c.refreshVersion(false)
+		err := c.client.Connect()
+		if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+			c.stats.reconnects.Add(1)
+			if c.finishReconnect(ctx) {
+				return
+			}
+			err = errors.New("connection dropped right after reconnecting")
+		}
+		lastErr = err.Error()
+		c.setError(WM_ERR_CONNECT, err)
+	}
+}
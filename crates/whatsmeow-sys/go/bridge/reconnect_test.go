@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ReconnectConfig
+		attempt int
+		want    time.Duration
+	}{
+		{"default first", ReconnectConfig{}, 1, 2 * time.Second},
+		{"default doubles", ReconnectConfig{}, 3, 8 * time.Second},
+		{"default cap", ReconnectConfig{}, 20, 2 * time.Minute},
+		{"custom first", ReconnectConfig{InitialDelay: time.Second, MaxDelay: 10 * time.Second}, 1, time.Second},
+		{"custom doubles", ReconnectConfig{InitialDelay: time.Second, MaxDelay: 10 * time.Second}, 4, 8 * time.Second},
+		{"custom cap", ReconnectConfig{InitialDelay: time.Second, MaxDelay: 10 * time.Second}, 5, 10 * time.Second},
+		{"max below initial", ReconnectConfig{InitialDelay: time.Minute, MaxDelay: time.Second}, 3, time.Minute},
+		{"huge attempt", ReconnectConfig{}, 1 << 20, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.delay(tt.attempt); got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconnectDelayJitter(t *testing.T) {
+	cfg := ReconnectConfig{InitialDelay: time.Second, Jitter: 0.25}
+	for i := 0; i < 100; i++ {
+		if d := cfg.delay(1); d < 750*time.Millisecond || d > 1250*time.Millisecond {
+			t.Fatalf("delay with 25%% jitter = %v, want within 750ms..1.25s", d)
+		}
+	}
+}
+
+func TestReconnectConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg     ReconnectConfig
+		wantErr bool
+	}{
+		{ReconnectConfig{}, false},
+		{ReconnectConfig{InitialDelay: time.Second, MaxDelay: time.Minute, Jitter: 1}, false},
+		{ReconnectConfig{InitialDelay: -1}, true},
+		{ReconnectConfig{MaxDelay: -1}, true},
+		{ReconnectConfig{Jitter: -0.1}, true},
+		{ReconnectConfig{Jitter: 1.5}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v, want error %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
+
+// TestFinishReconnect checks that the loop keeps going when the connection
+// dropped right after a successful reconnect
+func TestFinishReconnect(t *testing.T) {
+	c, err := NewClient(ClientConfig{DbPath: MemoryDbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Destroy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.reconnect = cancel
+	// The client never connected, as after a drop whose event was ignored
+	if c.finishReconnect(ctx) {
+		t.Error("the loop stopped although the connection is down")
+	}
+	if c.reconnect == nil || ctx.Err() != nil {
+		t.Error("the loop was unregistered although it carries on")
+	}
+
+	cancel()
+	if !c.finishReconnect(ctx) {
+		t.Error("a cancelled loop carried on")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// A handle packs a registry slot (low bits) with the generation of that slot
+// (high bits). Slots are reused after destroy, but each reuse bumps the
+// generation, so a stale handle from the host never reaches the new client.
+const (
+	handleSlotBits = 16
+	handleSlotMask = 1<<handleSlotBits - 1
+	maxClients     = handleSlotMask
+)
+
+// errTooManyClients is returned when every registry slot is in use
+var errTooManyClients = errors.New("too many clients")
+
+var (
+	// clientsMu serializes registering and unregistering; lookups only read
+	// live, so exports never contend on it
+	clientsMu sync.Mutex
+	// live maps the handle of every registered client to it
+	live sync.Map
+	// slotGens holds the generation of each slot. Slot 0 is never handed
+	// out, so 0 stays the invalid handle.
+	slotGens  = make([]uintptr, 1)
+	freeSlots []uintptr
+)
+
+// registerClient stores c in a free slot and returns its handle
+func registerClient(c *Client) (uintptr, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	var slot uintptr
+	if n := len(freeSlots); n > 0 {
+		slot = freeSlots[n-1]
+		freeSlots = freeSlots[:n-1]
+	} else {
+		if len(slotGens) > maxClients {
+			return 0, errTooManyClients
+		}
+		slot = uintptr(len(slotGens))
+		slotGens = append(slotGens, 0)
+	}
+
+	gen := (slotGens[slot] + 1) & (^uintptr(0) >> handleSlotBits)
+	if gen == 0 {
+		gen = 1
+	}
+	slotGens[slot] = gen
+
+	handle := gen<<handleSlotBits | slot
+	c.handle = handle
+	live.Store(handle, c)
+	return handle, nil
+}
+
+// lookupClient returns the client of a live handle, or nil if the handle was
+// never issued or its slot has since been destroyed or reused. The handle
+// includes the generation, so a stale one never matches the slot's new client.
+func lookupClient(handle uintptr) *Client {
+	if client, ok := live.Load(handle); ok {
+		return client.(*Client)
+	}
+	return nil
+}
+
+// unregisterClient frees the slot of a live handle and returns its client
+func unregisterClient(handle uintptr) *Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	client, ok := live.LoadAndDelete(handle)
+	if !ok {
+		return nil
+	}
+	freeSlots = append(freeSlots, handle&handleSlotMask)
+	return client.(*Client)
+}
+
+// storeInUse reports whether a live client has the store file at dbPath open
+func storeInUse(dbPath string) bool {
+	target, err := filepath.Abs(dbPath)
+	if err != nil {
+		return false
+	}
+
+	inUse := false
+	live.Range(func(_, value interface{}) bool {
+		client := value.(*Client)
+		if client.config.inMemory() {
+			return true
+		}
+		if path, err := filepath.Abs(client.config.DbPath); err == nil && path == target {
+			inUse = true
+		}
+		return !inUse
+	})
+	return inUse
+}
+
+// ClientSummary describes a live handle for wm_list_clients
+type ClientSummary struct {
+	Handle     uint64 `json:"handle"`
+	DbPath     string `json:"db_path"`
+	Connected  bool   `json:"connected"`
+	LoggedIn   bool   `json:"logged_in"`
+	JID        string `json:"jid,omitempty"`
+	QueueDepth int    `json:"queue_depth"`
+}
+
+// ListClients describes every live handle, in handle order
+func ListClients() []ClientSummary {
+	out := []ClientSummary{}
+	live.Range(func(key, value interface{}) bool {
+		handle, client := key.(uintptr), value.(*Client)
+		summary := ClientSummary{
+			Handle:     uint64(handle),
+			DbPath:     client.config.DbPath,
+			Connected:  client.IsConnected(),
+			LoggedIn:   client.IsLoggedIn(),
+			QueueDepth: client.pendingEvents(),
+		}
+		if id := client.OwnJID(); id != nil {
+			summary.JID = id.JID
+		}
+		out = append(out, summary)
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Handle < out[j].Handle })
+	return out
+}
+
+// ClientCount returns the number of live handles
+func ClientCount() int {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	return len(slotGens) - 1 - len(freeSlots)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// benchClients is how many live clients the lookup benchmarks spread over,
+// as for a host running hundreds of accounts
+const benchClients = 256
+
+// registerBenchClients fills the registry with placeholder clients and
+// returns their handles, unregistering them when the benchmark ends
+func registerBenchClients(b *testing.B) []uintptr {
+	handles := make([]uintptr, benchClients)
+	for i := range handles {
+		handle, err := registerClient(&Client{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		handles[i] = handle
+	}
+	b.Cleanup(func() {
+		for _, handle := range handles {
+			unregisterClient(handle)
+		}
+	})
+	return handles
+}
+
+// BenchmarkRegistryLookup measures handle lookups from all CPUs, the path
+// every export takes
+func BenchmarkRegistryLookup(b *testing.B) {
+	handles := registerBenchClients(b)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if lookupClient(handles[i%len(handles)]) == nil {
+				b.Error("live handle not found")
+				return
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkRegistryLookupChurn measures lookups while clients are created
+// and destroyed, which take clientsMu
+func BenchmarkRegistryLookupChurn(b *testing.B) {
+	handles := registerBenchClients(b)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if handle, err := registerClient(&Client{}); err == nil {
+				unregisterClient(handle)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			lookupClient(handles[i%len(handles)])
+			i++
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+}
+
+// rwMutexRegistry is the registry as it was before the sync.Map, with every
+// lookup taking a shared RWMutex; it is the baseline for the benchmarks above
+type rwMutexRegistry struct {
+	mu      sync.RWMutex
+	clients map[uintptr]*Client
+}
+
+func (r *rwMutexRegistry) lookup(handle uintptr) *Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clients[handle]
+}
+
+func newRWMutexRegistry() (*rwMutexRegistry, []uintptr) {
+	r := &rwMutexRegistry{clients: make(map[uintptr]*Client)}
+	handles := make([]uintptr, benchClients)
+	for i := range handles {
+		handles[i] = uintptr(i + 1)
+		r.clients[handles[i]] = &Client{}
+	}
+	return r, handles
+}
+
+func BenchmarkRegistryLookupRWMutex(b *testing.B) {
+	r, handles := newRWMutexRegistry()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if r.lookup(handles[i%len(handles)]) == nil {
+				b.Error("live handle not found")
+				return
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkRegistryLookupRWMutexChurn(b *testing.B) {
+	r, handles := newRWMutexRegistry()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		next := uintptr(len(handles) + 1)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			r.mu.Lock()
+			r.clients[next] = &Client{}
+			r.mu.Unlock()
+			r.mu.Lock()
+			delete(r.clients, next)
+			r.mu.Unlock()
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			r.lookup(handles[i%len(handles)])
+			i++
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+}
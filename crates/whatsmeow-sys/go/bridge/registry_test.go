@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestStoreInUse checks that only clients with the store file open hold it
+func TestStoreInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+
+	ephemeral, err := NewClient(ClientConfig{DbPath: path, Ephemeral: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ephemeral.Destroy()
+	handle, err := registerClient(ephemeral)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregisterClient(handle)
+	if storeInUse(path) {
+		t.Error("an ephemeral client holds the store file it was given")
+	}
+
+	onDisk, err := NewClient(ClientConfig{DbPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer onDisk.Destroy()
+	handle, err = registerClient(onDisk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregisterClient(handle)
+	if !storeInUse(path) {
+		t.Error("the store file of a live client is not in use")
+	}
+}
+
+// TestRegistryGenerations checks that a reused slot gets a new handle and
+// that the handle of its previous client no longer resolves
+func TestRegistryGenerations(t *testing.T) {
+	first := &Client{}
+	stale, err := registerClient(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookupClient(stale) != first {
+		t.Fatal("live handle not found")
+	}
+	if unregisterClient(stale) != first {
+		t.Fatal("unregister didn't return the client")
+	}
+	if lookupClient(stale) != nil || unregisterClient(stale) != nil {
+		t.Error("destroyed handle still resolves")
+	}
+
+	second := &Client{}
+	handle, err := registerClient(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregisterClient(handle)
+	if handle&handleSlotMask != stale&handleSlotMask {
+		t.Fatalf("slot %d wasn't reused for the next client, got %d", stale&handleSlotMask, handle&handleSlotMask)
+	}
+	if handle == stale {
+		t.Fatal("reused slot kept its handle")
+	}
+	if lookupClient(stale) != nil {
+		t.Error("stale handle resolves to the slot's new client")
+	}
+	if lookupClient(handle) != second || second.handle != handle {
+		t.Error("new handle doesn't resolve to its client")
+	}
+	if lookupClient(0) != nil {
+		t.Error("handle 0 resolves")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// RetryConfig controls how the bridge answers retry receipts, which a
+// recipient sends when it couldn't decrypt one of our messages. Retry
+// receipts for messages we couldn't decrypt are always sent by whatsmeow.
+type RetryConfig struct {
+	// Disabled stops resending messages on retry receipts
+	Disabled bool
+	// MaxRetries caps how many retries of one message are answered; zero
+	// leaves it to whatsmeow
+	MaxRetries int
+}
+
+// UndecryptableEvent is emitted when an incoming message couldn't be
+// decrypted. whatsmeow asks the sender to retry; a successful resend arrives
+// as a normal message event with the same ID.
+type UndecryptableEvent struct {
+	MessageID string `json:"message_id" pb:"1"`
+	Chat      string `json:"chat" pb:"2"`
+	Sender    string `json:"sender" pb:"3"`
+	Timestamp int64  `json:"timestamp" pb:"4"`
+	// Unavailable means the sender's device sent no ciphertext for this
+	// device at all, e.g. for view-once messages (UnavailableType)
+	Unavailable     bool   `json:"unavailable" pb:"5"`
+	UnavailableType string `json:"unavailable_type,omitempty" pb:"6"`
+	// Hidden is set when the sender asked for no failure placeholder
+	Hidden bool `json:"hidden" pb:"7"`
+}
+
+func newUndecryptableEvent(e *events.UndecryptableMessage) *UndecryptableEvent {
+	return &UndecryptableEvent{
+		MessageID:       e.Info.ID,
+		Chat:            e.Info.Chat.String(),
+		Sender:          e.Info.Sender.ToNonAD().String(),
+		Timestamp:       e.Info.Timestamp.Unix(),
+		Unavailable:     e.IsUnavailable,
+		UnavailableType: string(e.UnavailableType),
+		Hidden:          e.DecryptFailMode == events.DecryptFailHide,
+	}
+}
+
+// RetryRequestEvent is emitted for each retry receipt received for one of
+// our messages; Accepted reports whether the message is being resent
+type RetryRequestEvent struct {
+	MessageID string `json:"message_id" pb:"1"`
+	Chat      string `json:"chat" pb:"2"`
+	Requester string `json:"requester" pb:"3"`
+	Count     int    `json:"count" pb:"4"`
+	Accepted  bool   `json:"accepted" pb:"5"`
+}
+
+// validate checks the retry limits
+func (cfg RetryConfig) validate() error {
+	if cfg.MaxRetries < 0 {
+		return argErrorf("max retries must not be negative")
+	}
+	return nil
+}
+
+// SetRetryConfig replaces the retry receipt settings
+func (c *Client) SetRetryConfig(cfg RetryConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.Retry = cfg
+	return nil
+}
+
+// preRetry decides whether to resend a message a recipient failed to decrypt
+func (c *Client) preRetry(receipt *events.Receipt, id types.MessageID, count int, _ *waProto.Message) bool {
+	c.mu.RLock()
+	cfg := c.config.Retry
+	c.mu.RUnlock()
+
+	accept := !cfg.Disabled && !c.frozen.Load() && (cfg.MaxRetries == 0 || count <= cfg.MaxRetries)
+	c.emit("retry_request", RetryRequestEvent{
+		MessageID: id,
+		Chat:      receipt.Chat.String(),
+		Requester: receipt.Sender.String(),
+		Count:     count,
+		Accepted:  accept,
+	})
+	return accept
+}
+
+// SetRequestFromPhone toggles asking the primary phone for messages that
+// stay undecryptable: whatsmeow waits 5s for the sender's resend, then
+// requests a copy. Recovered messages arrive as message events with
+// UnavailableRequestID set.
+func (c *Client) SetRequestFromPhone(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.RequestFromPhone = enabled
+	c.client.AutomaticMessageRerequestFromPhone = enabled
+}
+
+// RequestFromPhone asks the primary phone to resend a message this device
+// couldn't decrypt and returns the request ID. The copy arrives as a message
+// event whose UnavailableRequestID is that ID.
+func (c *Client) RequestFromPhone(ctx context.Context, chatStr, senderStr, messageID string) (string, error) {
+	chat, err := types.ParseJID(chatStr)
+	if err != nil {
+		return "", argErrorf("invalid chat JID: %w", err)
+	}
+	sender, err := types.ParseJID(senderStr)
+	if err != nil {
+		return "", argErrorf("invalid sender JID: %w", err)
+	}
+	if messageID == "" {
+		return "", argErrorf("message ID is required")
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return "", err
+	}
+	own := c.client.Store.ID
+	if own == nil {
+		return "", ErrNotConnected
+	}
+
+	req := c.client.BuildUnavailableMessageRequest(chat, sender, messageID)
+	resp, err := c.sendMessage(ctx, own.ToNonAD(), req, whatsmeow.SendRequestExtra{Peer: true})
+	if err != nil {
+		return "", fmt.Errorf("request from phone failed: %w", err)
+	}
+	return resp.ID, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ErrSearchUnavailable is returned by SearchMessages when the linked SQLite
+// lacks FTS5; the bundled one needs the sqlite_fts5 build tag
+var ErrSearchUnavailable = errors.New("message search requires SQLite with FTS5 (build tag sqlite_fts5)")
+
+// defaultSearchLimit caps searches that don't set a limit
+const defaultSearchLimit = 50
+
+// archiveSearchSchema indexes the text of archived messages. The index holds
+// no copy of the text (external content); triggers keep it in sync.
+var archiveSearchSchema = []string{
+	`CREATE VIRTUAL TABLE wm_bridge_archive_fts USING fts5(
+		text, content='wm_bridge_archive', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+	)`,
+	`CREATE TRIGGER wm_bridge_archive_ai AFTER INSERT ON wm_bridge_archive BEGIN
+		INSERT INTO wm_bridge_archive_fts (rowid, text) VALUES (new.id, new.text);
+	END`,
+	`CREATE TRIGGER wm_bridge_archive_ad AFTER DELETE ON wm_bridge_archive BEGIN
+		INSERT INTO wm_bridge_archive_fts (wm_bridge_archive_fts, rowid, text) VALUES ('delete', old.id, old.text);
+	END`,
+	`CREATE TRIGGER wm_bridge_archive_au AFTER UPDATE ON wm_bridge_archive BEGIN
+		INSERT INTO wm_bridge_archive_fts (wm_bridge_archive_fts, rowid, text) VALUES ('delete', old.id, old.text);
+		INSERT INTO wm_bridge_archive_fts (rowid, text) VALUES (new.id, new.text);
+	END`,
+	// Messages archived before the index existed
+	`INSERT INTO wm_bridge_archive_fts (wm_bridge_archive_fts) VALUES ('rebuild')`,
+}
+
+// ensureArchiveSearch creates the full-text index of the message archive and
+// reports whether search is available
+func ensureArchiveSearch(ctx context.Context, db *sql.DB) bool {
+	var n int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE name = 'wm_bridge_archive_fts'").Scan(&n)
+	if err != nil {
+		return false
+	} else if n > 0 {
+		return true
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false
+	}
+	defer tx.Rollback()
+	for _, stmt := range archiveSearchSchema {
+		// Fails with "no such module: fts5" without FTS5 support
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return false
+		}
+	}
+	return tx.Commit() == nil
+}
+
+// SearchResult is an archived message matching a search; lower Rank is better
+type SearchResult struct {
+	ArchivedMessage
+	Rank float64 `json:"rank"`
+}
+
+// searchQuery turns user input into an FTS5 query matching messages that
+// contain every word, the last one as a prefix so results follow typing
+func searchQuery(input string) string {
+	words := strings.Fields(input)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// SearchMessages searches the text of archived messages, best matches first;
+// chat restricts the search to one conversation and limit 0 means 50
+func (c *Client) SearchMessages(ctx context.Context, input, chatStr string, limit int) ([]SearchResult, error) {
+	own := c.client.Store.ID
+	if own == nil {
+		return nil, ErrNotConnected
+	}
+	if !c.fts {
+		return nil, ErrSearchUnavailable
+	}
+	query := searchQuery(input)
+	if query == "" {
+		return nil, argErrorf("search query is required")
+	}
+	if limit < 0 {
+		return nil, argErrorf("limit must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	where := "wm_bridge_archive_fts MATCH ? AND a.our_jid = ?"
+	args := []any{query, own.ToNonAD().String()}
+	if chatStr != "" {
+		chat, err := types.ParseJID(chatStr)
+		if err != nil {
+			return nil, argErrorf("invalid chat JID: %w", err)
+		}
+		where += " AND a.chat = ?"
+		args = append(args, chat.String())
+	}
+	args = append(args, limit)
+
+	columns := "a." + strings.ReplaceAll(archiveColumns, ", ", ", a.")
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT %s, bm25(wm_bridge_archive_fts) AS rank
+		FROM wm_bridge_archive_fts JOIN wm_bridge_archive a ON a.id = wm_bridge_archive_fts.rowid
+		WHERE %s ORDER BY rank LIMIT ?`, columns, where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		msg := &res.ArchivedMessage
+		if err := rows.Scan(&msg.MessageID, &msg.Chat, &msg.Sender, &msg.FromMe, &msg.Timestamp,
+			&msg.Type, &msg.Text, &msg.MimeType, &msg.MediaPath, &msg.Raw, &res.Rank); err != nil {
+			return nil, fmt.Errorf("search failed: %w", err)
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waAdv"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestSearchAfterMaintain checks that search hits still point at the right
+// messages once maintenance has vacuumed the store
+func TestSearchAfterMaintain(t *testing.T) {
+	c, err := NewClient(ClientConfig{
+		DbPath:         filepath.Join(t.TempDir(), "store.db"),
+		MessageArchive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Destroy()
+	if !c.fts {
+		t.Skip("SQLite built without FTS5 (build tag sqlite_fts5)")
+	}
+	c.client.Store.ID = &types.JID{User: "15550000000", Server: types.DefaultUserServer}
+	c.client.Store.Account = &waAdv.ADVSignedDeviceIdentity{
+		Details:             []byte{},
+		AccountSignature:    make([]byte, 64),
+		AccountSignatureKey: make([]byte, 32),
+		DeviceSignature:     make([]byte, 64),
+	}
+	if err := c.client.Store.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	chat := types.JID{User: "15551111111", Server: types.DefaultUserServer}
+
+	for i := range 200 {
+		info := types.MessageInfo{
+			MessageSource: types.MessageSource{Chat: chat, Sender: chat},
+			ID:            types.MessageID(fmt.Sprintf("MSG%03d", i)),
+			Timestamp:     time.Unix(int64(1700000000+i), 0),
+		}
+		c.archive(info, &waProto.Message{Conversation: proto.String(fmt.Sprintf("note number%03d", i))})
+	}
+	// Leave holes in the rowids for VACUUM to close
+	if _, err := c.db.Exec("DELETE FROM wm_bridge_archive WHERE message_id < 'MSG150'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Maintain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"MSG150", "MSG173", "MSG199"} {
+		word := "number" + id[3:]
+		results, err := c.SearchMessages(context.Background(), word, "", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].MessageID != id || results[0].Text != "note "+word {
+			t.Errorf("search %q = %+v, want only %s", word, results, id)
+		}
+	}
+	results, err := c.SearchMessages(context.Background(), "number000", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("search for a deleted message = %+v, want no results", results)
+	}
+}
+
+// TestMigrateArchiveID checks that an archive created before the id column
+// keeps its messages, in order, when rebuilt
+func TestMigrateArchiveID(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "old.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE wm_bridge_archive (
+			our_jid TEXT NOT NULL, chat TEXT NOT NULL, message_id TEXT NOT NULL,
+			sender TEXT NOT NULL, from_me BOOLEAN NOT NULL, timestamp BIGINT NOT NULL,
+			type TEXT NOT NULL, text TEXT NOT NULL, mime_type TEXT NOT NULL,
+			media_path TEXT NOT NULL, raw BLOB,
+			PRIMARY KEY (our_jid, chat, message_id)
+		)`,
+		`CREATE INDEX wm_bridge_archive_time ON wm_bridge_archive (our_jid, timestamp)`,
+		`INSERT INTO wm_bridge_archive VALUES
+			('me', 'chat', 'B', 'them', 0, 2, 'text', 'second', '', '', NULL),
+			('me', 'chat', 'A', 'them', 0, 1, 'text', 'first', '', '', NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ensureBridgeSchema(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	// A second run finds the id column and leaves the table alone
+	if err := migrateArchiveID(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT id, message_id, text FROM wm_bridge_archive ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id int64
+		var messageID, text string
+		if err := rows.Scan(&id, &messageID, &text); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d:%s:%s", id, messageID, text))
+	}
+	want := []string{"1:B:second", "2:A:first"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("migrated archive = %v, want %v", got, want)
+	}
+}
+
+// TestSearchQuery checks that input is quoted word by word, so FTS5 syntax
+// in it is matched literally
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"hello", `"hello"*`},
+		{"hello world", `"hello" "world"*`},
+		{"  spaced\tout  ", `"spaced" "out"*`},
+		{`say "hi"`, `"say" """hi"""*`},
+		{"a OR b", `"a" "OR" "b"*`},
+		{"NEAR(x y)", `"NEAR(x" "y)"*`},
+	}
+	for _, tt := range tests {
+		if got := searchQuery(tt.input); got != tt.want {
+			t.Errorf("searchQuery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
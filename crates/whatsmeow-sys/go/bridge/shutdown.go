@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ErrShuttingDown is returned by sends started after Shutdown began
+var ErrShuttingDown = errors.New("client is shutting down")
+
+// drainPollInterval is how often Shutdown checks whether the host has
+// drained the event queue
+const drainPollInterval = 10 * time.Millisecond
+
+// sendMessage sends through whatsmeow once the rate limiter and human pacing
+// allow it, and lets Shutdown wait for the send to finish; it fails once
+// shutdown began. Callers must not hold c.mu, as the rate limiter may hold
+// the send for minutes.
+func (c *Client) sendMessage(ctx context.Context, to types.JID, msg *waProto.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error) {
+	// Peer messages to our own devices are protocol traffic, not sends
+	if len(extra) == 0 || !extra[0].Peer {
+		pacing := c.humanPacing()
+		if err := c.pace(ctx, to); err != nil {
+			return whatsmeow.SendResponse{}, err
+		}
+		if err := c.compose(ctx, pacing, to, msg); err != nil {
+			return whatsmeow.SendResponse{}, err
+		}
+	}
+
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+	if c.closing.Load() {
+		return whatsmeow.SendResponse{}, ErrShuttingDown
+	}
+	return c.client.SendMessage(ctx, to, msg, extra...)
+}
+
+// waitUntil runs wait in the background and reports whether it returned
+// before the deadline
+func waitUntil(deadline time.Time, wait func()) bool {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(time.Until(deadline)):
+		return false
+	}
+}
+
+// Shutdown tears the client down without losing events: it rejects new
+// sends, waits for in-flight ones, disconnects, stops queueing events and
+// gives the host until the timeout to drain the queue by polling or through
+// the event callback. Events still queued then are written to spillPath, if
+// set, each as a 4-byte big-endian length followed by the event. It returns
+// how many events were left over; the client must not be used afterwards.
+func (c *Client) Shutdown(timeout time.Duration, spillPath string) (int, error) {
+	deadline := time.Now().Add(timeout)
+	c.closing.Store(true)
+
+	// Sends hold sendMu for reading while they run
+	waitUntil(deadline, func() {
+		c.sendMu.Lock()
+		c.sendMu.Unlock()
+	})
+	c.Disconnect()
+	c.closed.Store(true)
+
+	for c.pendingEvents() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	// The dispatcher must not take events while they are spilled
+	c.stopEventCallback()
+
+	left := 0
+	var err error
+	if spillPath != "" && c.pendingEvents() > 0 {
+		left, err = c.spillEvents(spillPath)
+	} else {
+		left = c.pendingEvents()
+	}
+	c.Destroy()
+	return left, err
+}
+
+// spillEvents writes the queued events to path
+func (c *Client) spillEvents(path string) (int, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return c.pendingEvents(), fmt.Errorf("failed to create event spill file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	n := 0
+	for data := c.PollEvent(); data != nil; data = c.PollEvent() {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+		w.Write(size[:])
+		w.Write(data)
+		n++
+	}
+	if err := w.Flush(); err != nil {
+		return n, fmt.Errorf("failed to write event spill file: %w", err)
+	}
+	return n, file.Close()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of a client's counters since it was created
+type Stats struct {
+	Since int64 `json:"since"`
+	// MessagesSent and MessagesReceived count messages by type (see messageType)
+	MessagesSent     map[string]uint64 `json:"messages_sent"`
+	MessagesReceived map[string]uint64 `json:"messages_received"`
+	BytesUploaded    uint64            `json:"bytes_uploaded"`
+	BytesDownloaded  uint64            `json:"bytes_downloaded"`
+	// ReconnectAttempts counts automatic reconnect attempts, Reconnects the
+	// ones that succeeded
+	ReconnectAttempts uint64 `json:"reconnect_attempts"`
+	Reconnects        uint64 `json:"reconnects"`
+	EventsDropped     uint64 `json:"events_dropped"`
+	QueueDepth        int    `json:"queue_depth"`
+	QueueCapacity     int    `json:"queue_capacity"`
+	OutboxDepth       int64  `json:"outbox_depth"`
+	// Socket is the websocket traffic across all connections of the client
+	Socket SocketStats `json:"socket"`
+}
+
+// statsCounters holds the counters behind Stats
+type statsCounters struct {
+	since      time.Time
+	mu         sync.Mutex
+	sent       map[string]uint64
+	received   map[string]uint64
+	uploaded   atomic.Uint64
+	downloaded atomic.Uint64
+	attempts   atomic.Uint64
+	reconnects atomic.Uint64
+	socket     trafficMeter
+}
+
+func newStatsCounters() *statsCounters {
+	return &statsCounters{
+		since:    time.Now(),
+		sent:     make(map[string]uint64),
+		received: make(map[string]uint64),
+	}
+}
+
+// countSent counts a message sent through the bridge
+func (s *statsCounters) countSent(msgType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent[msgType]++
+}
+
+// countReceived counts a received message
+func (s *statsCounters) countReceived(msgType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received[msgType]++
+}
+
+// copyCounts copies a counter map for a snapshot
+func copyCounts(counts map[string]uint64) map[string]uint64 {
+	out := make(map[string]uint64, len(counts))
+	for k, v := range counts {
+		out[k] = v
+	}
+	return out
+}
+
+// Stats returns the client's counters and current queue depths
+func (c *Client) Stats() Stats {
+	s := c.stats
+	s.mu.Lock()
+	sent, received := copyCounts(s.sent), copyCounts(s.received)
+	s.mu.Unlock()
+
+	return Stats{
+		Since:             s.since.Unix(),
+		MessagesSent:      sent,
+		MessagesReceived:  received,
+		BytesUploaded:     s.uploaded.Load(),
+		BytesDownloaded:   s.downloaded.Load(),
+		ReconnectAttempts: s.attempts.Load(),
+		Reconnects:        s.reconnects.Load(),
+		EventsDropped:     c.dropped.Load(),
+		QueueDepth:        c.eventQueue.len(),
+		QueueCapacity:     c.eventQueue.capacity(),
+		OutboxDepth:       c.outboxLen.Load(),
+		Socket:            s.socket.snapshot(),
+	}
+}
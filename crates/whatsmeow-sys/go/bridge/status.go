@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// TextStatus is a text status update. Colors are 0xAARRGGBB, zero leaves the
+// app default; Font is one of the ExtendedTextMessage font types (0 = system).
+type TextStatus struct {
+	Text       string
+	Background uint32
+	TextColor  uint32
+	Font       int32
+}
+
+// PostTextStatus publishes a text status to the audience chosen in the
+// account's status privacy settings
+func (c *Client) PostTextStatus(ctx context.Context, status TextStatus) (*SendResult, error) {
+	if status.Text == "" {
+		return nil, argErrorf("status text is required")
+	}
+	if _, ok := waProto.ExtendedTextMessage_FontType_name[status.Font]; !ok {
+		return nil, argErrorf("unknown status font %d", status.Font)
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return nil, err
+	}
+
+	text := &waProto.ExtendedTextMessage{
+		Text: proto.String(status.Text),
+		Font: waProto.ExtendedTextMessage_FontType(status.Font).Enum(),
+	}
+	if status.Background != 0 {
+		text.BackgroundArgb = proto.Uint32(status.Background)
+	}
+	if status.TextColor != 0 {
+		text.TextArgb = proto.Uint32(status.TextColor)
+	}
+
+	resp, err := c.sendMessage(ctx, types.StatusBroadcastJID, &waProto.Message{ExtendedTextMessage: text})
+	if err != nil {
+		return nil, fmt.Errorf("status post failed: %w", err)
+	}
+	c.stats.countSent("text")
+	return c.sendResult(types.StatusBroadcastJID, resp), nil
+}
+
+// PostMediaStatus publishes an image or video status; the kind follows from
+// mimeType (image/* or video/*)
+func (c *Client) PostMediaStatus(ctx context.Context, data []byte, mimeType, caption string) (*SendResult, error) {
+	if len(data) == 0 {
+		return nil, argErrorf("status media is empty")
+	}
+	video := strings.HasPrefix(mimeType, "video/")
+	if !video && !strings.HasPrefix(mimeType, "image/") {
+		return nil, argErrorf("status media must be an image or video, got %q", mimeType)
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return nil, err
+	}
+
+	mediaType := whatsmeow.MediaImage
+	if video {
+		mediaType = whatsmeow.MediaVideo
+	}
+	release, err := c.acquireMediaSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	uploaded, err := c.client.Upload(ctx, data, mediaType)
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	c.stats.uploaded.Add(uint64(len(data)))
+
+	var captionPtr *string
+	if caption != "" {
+		captionPtr = proto.String(caption)
+	}
+	msg := &waProto.Message{}
+	if video {
+		msg.VideoMessage = &waProto.VideoMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(data))),
+			Caption:       captionPtr,
+		}
+	} else {
+		msg.ImageMessage = &waProto.ImageMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			Mimetype:      proto.String(mimeType),
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uint64(len(data))),
+			Caption:       captionPtr,
+		}
+	}
+
+	resp, err := c.sendMessage(ctx, types.StatusBroadcastJID, msg)
+	if err != nil {
+		return nil, fmt.Errorf("status post failed: %w", err)
+	}
+	c.stats.countSent(messageType(msg))
+	return c.sendResult(types.StatusBroadcastJID, resp), nil
+}
+
+// StatusAudience is a status privacy list: "contacts" (all contacts),
+// "blacklist" (contacts except List) or "whitelist" (only List). WhatsApp
+// keeps a list per type; Default marks the one status posts go to.
+type StatusAudience struct {
+	Type    string   `json:"type"`
+	List    []string `json:"list,omitempty"`
+	Default bool     `json:"default"`
+}
+
+// StatusPrivacy returns the account's status audience lists, default first
+func (c *Client) StatusPrivacy(ctx context.Context) ([]StatusAudience, error) {
+	if err := c.checkOutgoing(); err != nil {
+		return nil, err
+	}
+
+	lists, err := c.client.GetStatusPrivacy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status privacy: %w", err)
+	}
+
+	out := make([]StatusAudience, 0, len(lists))
+	for _, list := range lists {
+		audience := StatusAudience{Type: string(list.Type), Default: list.IsDefault}
+		for _, jid := range list.List {
+			audience.List = append(audience.List, jid.String())
+		}
+		out = append(out, audience)
+	}
+	return out, nil
+}
+
+// SetStatusPrivacy makes the given list the default status audience. whatsmeow
+// only reads this setting, so the bridge sends the same IQ WhatsApp Web does;
+// later status posts go to the new audience.
+func (c *Client) SetStatusPrivacy(ctx context.Context, listType string, jids []string) error {
+	switch types.StatusPrivacyType(listType) {
+	case types.StatusPrivacyTypeContacts:
+		if len(jids) > 0 {
+			return argErrorf("the contacts audience takes no list")
+		}
+	case types.StatusPrivacyTypeBlacklist, types.StatusPrivacyTypeWhitelist:
+	default:
+		return argErrorf("unknown status audience %q", listType)
+	}
+
+	list := waBinary.Node{Tag: "list", Attrs: waBinary.Attrs{"type": listType}}
+	if len(jids) > 0 {
+		users := make([]waBinary.Node, 0, len(jids))
+		for _, jidStr := range jids {
+			jid, err := types.ParseJID(jidStr)
+			if err != nil {
+				return argErrorf("invalid JID %q: %w", jidStr, err)
+			}
+			users = append(users, waBinary.Node{Tag: "user", Attrs: waBinary.Attrs{"jid": jid.ToNonAD()}})
+		}
+		list.Content = users
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return err
+	}
+
+	_, err := c.client.DangerousInternals().SendIQ(ctx, whatsmeow.DangerousInfoQuery{
+		Namespace: "status",
+		Type:      "set",
+		To:        types.ServerJID,
+		Content: []waBinary.Node{{
+			Tag:     "privacy",
+			Content: []waBinary.Node{list},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set status privacy: %w", err)
+	}
+	return nil
+}
+
+// StatusUpdateEvent is a contact's status post, emitted as status_update
+// instead of a message from status@broadcast
+type StatusUpdateEvent struct {
+	MessageID  string `json:"message_id" pb:"1"`
+	Sender     string `json:"sender" pb:"2"`
+	SenderName string `json:"sender_name,omitempty" pb:"3"`
+	Timestamp  int64  `json:"timestamp" pb:"4"`
+	Type       string `json:"type" pb:"5"`
+	Text       string `json:"text,omitempty" pb:"6"`
+	Background uint32 `json:"background,omitempty" pb:"7"`
+	Font       int32  `json:"font,omitempty" pb:"8"`
+	FromMe     bool   `json:"from_me,omitempty" pb:"9"`
+	// RawProto is set as for message events when raw passthrough is enabled,
+	// e.g. to download the media of image and video posts
+	RawProto []byte `json:"raw_proto,omitempty" pb:"10"`
+}
+
+func (c *Client) newStatusUpdateEvent(evt *events.Message) *StatusUpdateEvent {
+	msg := c.newMessageEvent(evt)
+	out := &StatusUpdateEvent{
+		MessageID:  evt.Info.ID,
+		Sender:     evt.Info.Sender.ToNonAD().String(),
+		SenderName: msg.DisplayName,
+		Timestamp:  evt.Info.Timestamp.Unix(),
+		Type:       messageType(evt.Message),
+		Text:       messageText(evt.Message),
+		FromMe:     evt.Info.IsFromMe,
+		RawProto:   msg.RawProto,
+	}
+	if text := evt.Message.GetExtendedTextMessage(); text != nil {
+		out.Background = text.GetBackgroundArgb()
+		out.Font = int32(text.GetFont())
+	}
+	return out
+}
+
+// MarkStatusViewed sends view receipts for a contact's status posts
+func (c *Client) MarkStatusViewed(ctx context.Context, senderStr string, ids []string) error {
+	sender, err := types.ParseJID(senderStr)
+	if err != nil {
+		return argErrorf("invalid JID: %w", err)
+	}
+	if len(ids) == 0 {
+		return argErrorf("no status message IDs given")
+	}
+	if err := c.checkOutgoing(); err != nil {
+		return err
+	}
+
+	if err := c.client.MarkRead(ctx, ids, time.Now(), types.StatusBroadcastJID, sender); err != nil {
+		return fmt.Errorf("failed to mark status viewed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// MemoryDbPath selects the in-memory store when passed as DbPath
+const MemoryDbPath = ":memory:"
+
+// memoryStoreID gives each in-memory store its own shared-cache name
+var memoryStoreID atomic.Uint64
+
+// inMemory reports whether the client keeps its store in memory
+func (config ClientConfig) inMemory() bool {
+	return config.Ephemeral || config.DbPath == MemoryDbPath
+}
+
+// storeDSN builds the sqlite DSN for the configured store
+func (config ClientConfig) storeDSN() string {
+	if config.inMemory() {
+		// A named shared-cache database keeps all pooled connections on the
+		// same in-memory store while isolating it from other clients
+		return fmt.Sprintf("file:wm-memory-%d?mode=memory&cache=shared&_foreign_keys=on",
+			memoryStoreID.Add(1))
+	}
+	if config.EncryptionKey != "" {
+		// Foreign keys are enabled by the connect hook once the key is applied
+		return fmt.Sprintf("file:%s", config.DbPath)
+	}
+	return fmt.Sprintf("file:%s?_foreign_keys=on", config.DbPath)
+}
+
+// sqliteConnector opens connections through a per-client driver so that
+// connect hooks (such as the SQLCipher key) don't leak between clients
+type sqliteConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (sc *sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return sc.driver.Open(sc.dsn)
+}
+
+func (sc *sqliteConnector) Driver() driver.Driver {
+	return sc.driver
+}
+
+// keyHook returns a connect hook that unlocks a SQLCipher database
+func keyHook(key string) func(*sqlite3.SQLiteConn) error {
+	pragma := fmt.Sprintf("PRAGMA key = '%s'", strings.ReplaceAll(key, "'", "''"))
+	return func(conn *sqlite3.SQLiteConn) error {
+		// The key must be applied before anything reads the database file
+		if _, err := conn.Exec(pragma, nil); err != nil {
+			return fmt.Errorf("failed to apply store key: %w", err)
+		}
+		if _, err := conn.Exec("PRAGMA foreign_keys = ON", nil); err != nil {
+			return fmt.Errorf("failed to enable foreign keys: %w", err)
+		}
+		return nil
+	}
+}
+
+// bridgeSchema holds tables owned by the bridge itself, next to whatsmeow's
+var bridgeSchema = []string{
+	`CREATE TABLE IF NOT EXISTS wm_bridge_known_devices (
+		our_jid    TEXT    NOT NULL,
+		device_jid TEXT    NOT NULL,
+		first_seen BIGINT  NOT NULL,
+		PRIMARY KEY (our_jid, device_jid)
+	)`,
+	`CREATE TABLE IF NOT EXISTS wm_bridge_outbox (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		our_jid    TEXT    NOT NULL,
+		message_id TEXT    NOT NULL,
+		chat       TEXT    NOT NULL,
+		kind       TEXT    NOT NULL,
+		text       TEXT    NOT NULL,
+		mime_type  TEXT    NOT NULL,
+		data       BLOB,
+		queued_at  BIGINT  NOT NULL
+	)`,
+	archiveSchema,
+	`CREATE INDEX IF NOT EXISTS wm_bridge_archive_time ON wm_bridge_archive (our_jid, timestamp)`,
+}
+
+// archiveSchema creates the message archive. The explicit id keeps the rowids
+// the search index refers to stable; VACUUM may renumber implicit ones.
+const archiveSchema = `CREATE TABLE IF NOT EXISTS wm_bridge_archive (
+		id         INTEGER PRIMARY KEY,
+		our_jid    TEXT    NOT NULL,
+		chat       TEXT    NOT NULL,
+		message_id TEXT    NOT NULL,
+		sender     TEXT    NOT NULL,
+		from_me    BOOLEAN NOT NULL,
+		timestamp  BIGINT  NOT NULL,
+		type       TEXT    NOT NULL,
+		text       TEXT    NOT NULL,
+		mime_type  TEXT    NOT NULL,
+		media_path TEXT    NOT NULL,
+		raw        BLOB,
+		UNIQUE (our_jid, chat, message_id)
+	)`
+
+// archiveColumnList lists the archive columns kept when migrating it
+const archiveColumnList = "our_jid, chat, message_id, sender, from_me, timestamp, type, text, mime_type, media_path, raw"
+
+// migrateArchiveID rebuilds an archive created without the id column. The
+// search index built on its implicit rowids is dropped; opening the client
+// recreates it from the new ids.
+func migrateArchiveID(ctx context.Context, db *sql.DB) error {
+	var n int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info('wm_bridge_archive') WHERE name = 'id'").Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS wm_bridge_archive_ai",
+		"DROP TRIGGER IF EXISTS wm_bridge_archive_ad",
+		"DROP TRIGGER IF EXISTS wm_bridge_archive_au",
+		"DROP TABLE IF EXISTS wm_bridge_archive_fts",
+		"DROP INDEX IF EXISTS wm_bridge_archive_time",
+		"ALTER TABLE wm_bridge_archive RENAME TO wm_bridge_archive_old",
+		archiveSchema,
+		fmt.Sprintf("INSERT INTO wm_bridge_archive (%[1]s) SELECT %[1]s FROM wm_bridge_archive_old ORDER BY rowid",
+			archiveColumnList),
+		"DROP TABLE wm_bridge_archive_old",
+		`CREATE INDEX wm_bridge_archive_time ON wm_bridge_archive (our_jid, timestamp)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ensureBridgeSchema creates the bridge tables if they don't exist yet
+func ensureBridgeSchema(ctx context.Context, db *sql.DB) error {
+	for _, stmt := range bridgeSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return migrateArchiveID(ctx, db)
+}
+
+// openStore opens the sqlite database and wraps it in a whatsmeow store container
+func openStore(ctx context.Context, config ClientConfig, log waLog.Logger) (*sql.DB, *sqlstore.Container, error) {
+	drv := &sqlite3.SQLiteDriver{}
+	if config.EncryptionKey != "" {
+		drv.ConnectHook = keyHook(config.EncryptionKey)
+	}
+	db := sql.OpenDB(&sqliteConnector{dsn: config.storeDSN(), driver: drv})
+	if config.inMemory() {
+		// A shared-cache memory database lives only as long as one of its
+		// connections, so never let the pool close the last idle one
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	}
+
+	if config.EncryptionKey != "" {
+		// Plain SQLite silently ignores PRAGMA key, so refuse to continue
+		// unless the linked library really is SQLCipher
+		var version string
+		err := db.QueryRowContext(ctx, "PRAGMA cipher_version").Scan(&version)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && version == "") {
+			db.Close()
+			return nil, nil, fmt.Errorf("store encryption requires a SQLCipher build (-tags libsqlite3, linked against libsqlcipher)")
+		} else if err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("failed to open store: %w", err)
+		}
+	}
+
+	container := sqlstore.NewWithDB(db, "sqlite3", log)
+	if err := container.Upgrade(ctx); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	if err := ensureBridgeSchema(ctx, db); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("failed to create bridge tables: %w", err)
+	}
+
+	return db, container, nil
+}
+
+// MaintenanceStats reports the outcome of a store maintenance run
+type MaintenanceStats struct {
+	IntegrityOK bool     `json:"integrity_ok"`
+	Integrity   []string `json:"integrity"`
+	StaleRows   int64    `json:"stale_rows_removed"`
+	SizeBefore  int64    `json:"size_before"`
+	SizeAfter   int64    `json:"size_after"`
+	DurationMs  int64    `json:"duration_ms"`
+}
+
+// deviceScopedTables lists session tables keyed by the owning device JID
+var deviceScopedTables = []string{
+	"whatsmeow_identity_keys",
+	"whatsmeow_sessions",
+	"whatsmeow_sender_keys",
+	"whatsmeow_contacts",
+	"whatsmeow_chat_settings",
+	"whatsmeow_message_secrets",
+	"whatsmeow_privacy_tokens",
+	"whatsmeow_event_buffer",
+}
+
+// storeSize returns the database size in bytes
+func storeSize(ctx context.Context, db *sql.DB) (int64, error) {
+	var size int64
+	err := db.QueryRowContext(ctx,
+		"SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	return size, err
+}
+
+// Maintain removes stale session data, checks integrity and compacts the store
+func (c *Client) Maintain(ctx context.Context) (*MaintenanceStats, error) {
+	start := time.Now()
+	stats := &MaintenanceStats{}
+
+	var err error
+	if stats.SizeBefore, err = storeSize(ctx, c.db); err != nil {
+		return nil, fmt.Errorf("failed to read store size: %w", err)
+	}
+
+	// Rows left behind by devices that no longer exist (e.g. after logout)
+	for _, table := range deviceScopedTables {
+		res, err := c.db.ExecContext(ctx, fmt.Sprintf(
+			"DELETE FROM %s WHERE our_jid NOT IN (SELECT jid FROM whatsmeow_device)", table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to clean %s: %w", table, err)
+		}
+		n, _ := res.RowsAffected()
+		stats.StaleRows += n
+	}
+
+	// The server only buffers events for 14 days
+	if c.client.Store.ID != nil {
+		if err := c.client.Store.EventBuffer.DeleteOldBufferedHashes(ctx); err != nil {
+			return nil, fmt.Errorf("failed to clean event buffer: %w", err)
+		}
+	}
+
+	rows, err := c.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("integrity check failed: %w", err)
+		}
+		stats.Integrity = append(stats.Integrity, line)
+	}
+	rows.Close()
+	stats.IntegrityOK = len(stats.Integrity) == 1 && stats.Integrity[0] == "ok"
+
+	if _, err := c.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return nil, fmt.Errorf("vacuum failed: %w", err)
+	}
+
+	if stats.SizeAfter, err = storeSize(ctx, c.db); err != nil {
+		return nil, fmt.Errorf("failed to read store size: %w", err)
+	}
+	stats.DurationMs = time.Since(start).Milliseconds()
+
+	return stats, nil
+}
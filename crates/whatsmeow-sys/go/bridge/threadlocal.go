@@ -0,0 +1,156 @@
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+
+// Per-thread results of the last bridge call, indexed by slot
+enum { WM_SLOT_CALL_ERROR, WM_SLOT_SEND_RESULT, WM_SLOT_CALL_RESULT, WM_SLOT_COUNT };
+
+static _Thread_local char *wm_slot_data[WM_SLOT_COUNT];
+static _Thread_local int wm_slot_len[WM_SLOT_COUNT];
+
+static void wm_store_slot(int slot, char *data, int len) {
+	free(wm_slot_data[slot]);
+	wm_slot_data[slot] = data;
+	wm_slot_len[slot] = len;
+}
+
+// Operations the next blocking call of this thread runs under, one per client
+// handle. Operation IDs are per client.
+struct wm_pending_op {
+	uintptr_t handle;
+	long long op;
+};
+
+static _Thread_local struct wm_pending_op *wm_pending;
+static _Thread_local int wm_pending_len, wm_pending_cap;
+
+static struct wm_pending_op *wm_find_pending_op(uintptr_t handle) {
+	for (int i = 0; i < wm_pending_len; i++) {
+		if (wm_pending[i].handle == handle) {
+			return &wm_pending[i];
+		}
+	}
+	return NULL;
+}
+
+static void wm_set_pending_op(uintptr_t handle, long long op) {
+	struct wm_pending_op *p = wm_find_pending_op(handle);
+	if (p != NULL) {
+		p->op = op;
+		return;
+	}
+	if (wm_pending_len == wm_pending_cap) {
+		int cap = wm_pending_cap > 0 ? 2 * wm_pending_cap : 4;
+		struct wm_pending_op *grown = realloc(wm_pending, cap * sizeof(*grown));
+		if (grown == NULL) {
+			return;
+		}
+		wm_pending = grown;
+		wm_pending_cap = cap;
+	}
+	wm_pending[wm_pending_len++] = (struct wm_pending_op){handle, op};
+}
+
+static long long wm_take_pending_op(uintptr_t handle) {
+	struct wm_pending_op *p = wm_find_pending_op(handle);
+	if (p == NULL) {
+		return 0;
+	}
+	long long op = p->op;
+	*p = wm_pending[--wm_pending_len];
+	return op;
+}
+
+static int wm_load_slot(int slot, char *buf, int buf_len) {
+	if (wm_slot_data[slot] == NULL) {
+		return 0;
+	}
+	if (wm_slot_len[slot] > buf_len) {
+		return -1;
+	}
+	memcpy(buf, wm_slot_data[slot], wm_slot_len[slot]);
+	return wm_slot_len[slot];
+}
+*/
+import "C"
+
+import (
+	"encoding/json"
+)
+
+// storeThreadResult stores v as JSON in a slot of the calling OS thread.
+// Exports run on the host thread that invoked them, so the host reads back
+// the result of its own call even while other threads use the same client.
+func storeThreadResult(slot C.int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	storeThreadData(slot, data)
+}
+
+// storeThreadData stores data in a slot of the calling OS thread
+func storeThreadData(slot C.int, data []byte) {
+	C.wm_store_slot(slot, (*C.char)(C.CBytes(data)), C.int(len(data)))
+}
+
+// loadThreadResult copies a slot of the calling thread into buf
+func loadThreadResult(slot C.int, buf *C.char, bufLen C.int) C.int {
+	n := C.wm_load_slot(slot, buf, bufLen)
+	if n < 0 {
+		return WM_ERR_BUFFER_TOO_SMALL
+	}
+	return n
+}
+
+// setCallError stores the error detail of a failed call for this thread
+func setCallError(detail ErrorDetail) {
+	storeThreadResult(C.WM_SLOT_CALL_ERROR, detail)
+}
+
+// setSendResult stores the result of a successful send for this thread
+func setSendResult(result *SendResult) {
+	storeThreadResult(C.WM_SLOT_SEND_RESULT, result)
+}
+
+// copyCallResult copies the result of a call that changed state, on the
+// server or in the store, into buf and keeps it for the calling thread, so
+// a host retrying after WM_ERR_BUFFER_TOO_SMALL reads it with
+// wm_get_call_result instead of repeating the change
+func copyCallResult(data []byte, buf *C.char, bufLen C.int) C.int {
+	storeThreadData(C.WM_SLOT_CALL_RESULT, data)
+	return copyToBuffer(data, buf, bufLen)
+}
+
+// setPendingOp makes the next blocking call of this thread on the client
+// with handle run under op
+func setPendingOp(handle uintptr, op int64) {
+	C.wm_set_pending_op(C.uintptr_t(handle), C.longlong(op))
+}
+
+// takePendingOp returns and clears the operation set for this thread on the
+// client with handle; operations begun for other clients stay pending
+func takePendingOp(handle uintptr) int64 {
+	return int64(C.wm_take_pending_op(C.uintptr_t(handle)))
+}
+
+//export wm_get_call_error
+func wm_get_call_error(buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	return loadThreadResult(C.WM_SLOT_CALL_ERROR, buf, bufLen)
+}
+
+//export wm_get_send_result
+func wm_get_send_result(buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	return loadThreadResult(C.WM_SLOT_SEND_RESULT, buf, bufLen)
+}
+
+//export wm_get_call_result
+func wm_get_call_result(buf *C.char, bufLen C.int) (ret C.int) {
+	defer catchPanic(&ret)
+	return loadThreadResult(C.WM_SLOT_CALL_RESULT, buf, bufLen)
+}
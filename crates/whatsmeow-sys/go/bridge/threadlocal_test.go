@@ -0,0 +1,38 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+// TestPendingOpPerHandle checks that an operation begun for one client is
+// neither overwritten nor taken by a call on another
+func TestPendingOpPerHandle(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	setPendingOp(1, 10)
+	setPendingOp(2, 20)
+	if op := takePendingOp(3); op != 0 {
+		t.Errorf("takePendingOp(3) = %d, want 0", op)
+	}
+	if op := takePendingOp(1); op != 10 {
+		t.Errorf("takePendingOp(1) = %d, want 10", op)
+	}
+	if op := takePendingOp(1); op != 0 {
+		t.Errorf("second takePendingOp(1) = %d, want 0", op)
+	}
+
+	setPendingOp(2, 21)
+	for handle := uintptr(3); handle < 12; handle++ {
+		setPendingOp(handle, int64(handle)*10)
+	}
+	if op := takePendingOp(2); op != 21 {
+		t.Errorf("takePendingOp(2) = %d, want 21", op)
+	}
+	for handle := uintptr(3); handle < 12; handle++ {
+		if op := takePendingOp(handle); op != int64(handle)*10 {
+			t.Errorf("takePendingOp(%d) = %d, want %d", handle, op, handle*10)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// trafficWindow is how many seconds the traffic rates are averaged over
+const trafficWindow = 60
+
+// SocketStats describes the websocket traffic of a client, measured on the
+// websocket stream inside TLS. Frames are websocket data messages, each
+// carrying one WhatsApp frame; pings and other control frames aren't counted.
+type SocketStats struct {
+	BytesSent      uint64 `json:"bytes_sent"`
+	BytesReceived  uint64 `json:"bytes_received"`
+	FramesSent     uint64 `json:"frames_sent"`
+	FramesReceived uint64 `json:"frames_received"`
+	// Rates are per second, averaged over the last minute
+	BytesSentRate      float64 `json:"bytes_sent_rate"`
+	BytesReceivedRate  float64 `json:"bytes_received_rate"`
+	FramesSentRate     float64 `json:"frames_sent_rate"`
+	FramesReceivedRate float64 `json:"frames_received_rate"`
+}
+
+// trafficCounts holds byte and frame counts in both directions
+type trafficCounts struct {
+	bytesSent, bytesReceived, framesSent, framesReceived uint64
+}
+
+func (t *trafficCounts) add(o trafficCounts) {
+	t.bytesSent += o.bytesSent
+	t.bytesReceived += o.bytesReceived
+	t.framesSent += o.framesSent
+	t.framesReceived += o.framesReceived
+}
+
+// trafficMeter totals a client's socket traffic, keeping one bucket per
+// second of the last minute for the rates
+type trafficMeter struct {
+	mu      sync.Mutex
+	total   trafficCounts
+	buckets [trafficWindow]trafficCounts
+	// seconds holds the Unix second each bucket was last used for
+	seconds [trafficWindow]int64
+}
+
+func (m *trafficMeter) record(counts trafficCounts) {
+	now := time.Now().Unix()
+	i := now % trafficWindow
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total.add(counts)
+	if m.seconds[i] != now {
+		m.buckets[i], m.seconds[i] = trafficCounts{}, now
+	}
+	m.buckets[i].add(counts)
+}
+
+// snapshot returns the totals with the rates over the last minute
+func (m *trafficMeter) snapshot() SocketStats {
+	now := time.Now().Unix()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var recent trafficCounts
+	for i, second := range m.seconds {
+		if now-second < trafficWindow {
+			recent.add(m.buckets[i])
+		}
+	}
+	return SocketStats{
+		BytesSent:          m.total.bytesSent,
+		BytesReceived:      m.total.bytesReceived,
+		FramesSent:         m.total.framesSent,
+		FramesReceived:     m.total.framesReceived,
+		BytesSentRate:      float64(recent.bytesSent) / trafficWindow,
+		BytesReceivedRate:  float64(recent.bytesReceived) / trafficWindow,
+		FramesSentRate:     float64(recent.framesSent) / trafficWindow,
+		FramesReceivedRate: float64(recent.framesReceived) / trafficWindow,
+	}
+}
+
+// wsFrameParser follows the websocket frame headers of one direction of a
+// stream, so data frames can be counted without buffering payloads
+type wsFrameParser struct {
+	header [14]byte
+	n      int
+	// size is the length of the current header once its second byte is in
+	size int
+	// skip is what's left of the current frame's payload
+	skip uint64
+}
+
+// feed consumes stream bytes and returns how many data frame headers they
+// completed
+func (p *wsFrameParser) feed(data []byte) (frames uint64) {
+	for len(data) > 0 {
+		if p.skip > 0 {
+			n := min(p.skip, uint64(len(data)))
+			p.skip -= n
+			data = data[n:]
+			continue
+		}
+
+		p.header[p.n] = data[0]
+		p.n++
+		data = data[1:]
+		if p.n == 2 {
+			p.size = 2
+			switch p.header[1] & 0x7f {
+			case 126:
+				p.size += 2
+			case 127:
+				p.size += 8
+			}
+			// Masking key of client frames
+			if p.header[1]&0x80 != 0 {
+				p.size += 4
+			}
+		}
+		if p.n < 2 || p.n < p.size {
+			continue
+		}
+
+		length := uint64(p.header[1] & 0x7f)
+		switch length {
+		case 126:
+			length = uint64(binary.BigEndian.Uint16(p.header[2:]))
+		case 127:
+			length = binary.BigEndian.Uint64(p.header[2:])
+		}
+		// Count the final frame of each message; opcodes from 8 up are control
+		// frames
+		if p.header[0]&0x80 != 0 && p.header[0]&0x08 == 0 {
+			frames++
+		}
+		p.skip, p.n = length, 0
+	}
+	return frames
+}
+
+// meteredSocket wraps the upgraded websocket stream to meter its traffic.
+// The websocket library serializes reads and serializes writes, so each
+// parser is only used by one goroutine at a time.
+type meteredSocket struct {
+	io.ReadWriteCloser
+	meter         *trafficMeter
+	read, written wsFrameParser
+}
+
+func (s *meteredSocket) Read(b []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Read(b)
+	if n > 0 {
+		s.meter.record(trafficCounts{bytesReceived: uint64(n), framesReceived: s.read.feed(b[:n])})
+	}
+	return n, err
+}
+
+func (s *meteredSocket) Write(b []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Write(b)
+	if n > 0 {
+		s.meter.record(trafficCounts{bytesSent: uint64(n), framesSent: s.written.feed(b[:n])})
+	}
+	return n, err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"go.mau.fi/whatsmeow/store"
+)
+
+// bridgeVersion is set to the whatsmeow-sys crate version by build.rs
+var bridgeVersion = "dev"
+
+// whatsmeowModule is the module path of the vendored whatsmeow
+const whatsmeowModule = "go.mau.fi/whatsmeow"
+
+// VersionInfo describes the bridge build and the WhatsApp version it speaks
+type VersionInfo struct {
+	Bridge string `json:"bridge"`
+	Go     string `json:"go"`
+	// Whatsmeow is the module version, WhatsmeowCommit the commit it pins
+	Whatsmeow       string `json:"whatsmeow"`
+	WhatsmeowCommit string `json:"whatsmeow_commit,omitempty"`
+	// WAVersion is the WhatsApp Web client version announced to the server
+	WAVersion string `json:"wa_version"`
+}
+
+// Version reports the bridge, Go and whatsmeow versions
+func Version() VersionInfo {
+	info := VersionInfo{
+		Bridge:    bridgeVersion,
+		Go:        runtime.Version(),
+		WAVersion: store.GetWAVersion().String(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range build.Deps {
+			if dep.Path != whatsmeowModule {
+				continue
+			}
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			info.Whatsmeow = dep.Version
+			// Pseudo-versions end in the commit: v0.0.0-20251217143725-11cf47c62d32
+			if parts := strings.Split(dep.Version, "-"); len(parts) == 3 {
+				info.WhatsmeowCommit = parts[2]
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waWa6"
+	"go.mau.fi/whatsmeow/store"
+)
+
+// Version refresh timing
+const (
+	versionFetchTimeout = 15 * time.Second
+	// versionRetryDelay keeps failing or forced fetches from hammering
+	// web.whatsapp.com
+	versionRetryDelay = time.Minute
+)
+
+// versionCache is the latest WhatsApp Web version fetched by any client
+var versionCache struct {
+	sync.Mutex
+	version store.WAVersionContainer
+	fetched time.Time
+	tried   time.Time
+}
+
+// VersionConfig selects the WhatsApp Web version announced to the server
+type VersionConfig struct {
+	// Fixed pins a version such as "2.3000.1031080782" and disables refreshing
+	Fixed string
+	// Refresh fetches the latest version from web.whatsapp.com before
+	// connecting once the last fetch is older than this; zero disables it
+	Refresh time.Duration
+}
+
+func (cfg VersionConfig) validate() error {
+	if cfg.Fixed != "" {
+		if _, err := store.ParseVersion(cfg.Fixed); err != nil {
+			return argErrorf("invalid WhatsApp version: %w", err)
+		}
+	}
+	if cfg.Refresh < 0 {
+		return argErrorf("version refresh interval must not be negative")
+	}
+	return nil
+}
+
+// waVersion returns the version this client announces, or zero for the one
+// built into whatsmeow
+func (c *Client) waVersion() store.WAVersionContainer {
+	c.mu.RLock()
+	cfg := c.config.Version
+	c.mu.RUnlock()
+
+	if cfg.Fixed != "" {
+		// Validated when configured
+		version, _ := store.ParseVersion(cfg.Fixed)
+		return version
+	}
+	if cfg.Refresh == 0 {
+		return store.WAVersionContainer{}
+	}
+	versionCache.Lock()
+	defer versionCache.Unlock()
+	return versionCache.version
+}
+
+// refreshVersion fetches the latest version if refreshing is enabled and the
+// cached one is stale, or right away when the server said ours is outdated
+func (c *Client) refreshVersion(outdated bool) {
+	c.mu.RLock()
+	cfg, httpClient := c.config.Version, c.mediaHTTP
+	c.mu.RUnlock()
+	if cfg.Fixed != "" || cfg.Refresh == 0 {
+		return
+	}
+
+	versionCache.Lock()
+	now := time.Now()
+	stale := now.Sub(versionCache.tried) >= versionRetryDelay &&
+		(outdated || now.Sub(versionCache.fetched) >= cfg.Refresh)
+	if stale {
+		versionCache.tried = now
+	}
+	versionCache.Unlock()
+	if !stale {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, versionFetchTimeout)
+	defer cancel()
+	version, err := whatsmeow.GetLatestVersion(ctx, httpClient)
+	if err != nil {
+		return
+	}
+	versionCache.Lock()
+	versionCache.version = *version
+	versionCache.fetched = now
+	versionCache.Unlock()
+}
+
+// applyVersion announces this client's version in a handshake payload.
+// store.SetWAVersion is process-wide and doesn't reach the user agent, so
+// the payload is patched instead.
+func (c *Client) applyVersion(payload *waWa6.ClientPayload) {
+	version := c.waVersion()
+	if version.IsZero() {
+		return
+	}
+	payload.UserAgent.AppVersion = version.ProtoAppVersion()
+	if reg := payload.DevicePairingData; reg != nil {
+		hash := version.Hash()
+		reg.BuildHash = hash[:]
+	}
+}
+
+// SetVersionConfig pins or refreshes the announced WhatsApp Web version;
+// takes effect on the next Connect
+func (c *Client) SetVersionConfig(cfg VersionConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config.Version = cfg
+	return nil
+}
@@ -12,12 +12,120 @@ $defContent = @'
 LIBRARY whatsmeow
 EXPORTS
     wm_client_new
+    wm_client_new_encrypted
+    wm_client_new_with_options
     wm_client_connect
     wm_client_disconnect
+    wm_refresh_qr
+    wm_set_qr_refresh
+    wm_set_offline_batch
     wm_client_destroy
     wm_poll_event
+    wm_poll_events_batch
+    wm_poll_event_ref
+    wm_event_free
     wm_send_message
     wm_last_error
+    wm_set_tls_config
+    wm_set_tls_system_roots
+    wm_set_media_config
+    wm_store_maintain
+    wm_set_dialer_config
+    wm_set_low_bandwidth
+    wm_get_played_by
+    wm_archive_chat
+    wm_set_normalize_reactions
+    wm_get_reactions
+    wm_pin_chat
+    wm_set_display_name_mode
+    wm_mute_chat
+    wm_unmute_chat
+    wm_group_invite_qr
+    wm_set_avatar_dir
+    wm_group_set_member_add_mode
+    wm_star_message
+    wm_label_edit
+    wm_label_chat
+    wm_label_message
+    wm_freeze
+    wm_clear_chat
+    wm_delete_chat
+    wm_set_event_callback
+    wm_set_event_format
+    wm_set_raw_messages
+    wm_get_dropped_events
+    wm_get_error_detail
+    wm_get_call_error
+    wm_get_send_result
+    wm_get_call_result
+    wm_client_is_connected
+    wm_client_is_logged_in
+    wm_get_own_jid
+    wm_set_device_props
+    wm_set_history_sync
+    wm_set_wa_version
+    wm_set_push_name
+    wm_set_proxy
+    wm_set_websocket_config
+    wm_set_auto_reconnect
+    wm_set_keepalive
+    wm_set_keepalive_timing
+    wm_ping
+    wm_set_stream_takeover
+    wm_set_offline_queue
+    wm_generate_message_id
+    wm_send_message_with_id
+    wm_send_image_with_id
+    wm_send_message_async
+    wm_send_image_async
+    wm_get_message_status
+    wm_newsletter_follow
+    wm_newsletter_unfollow
+    wm_newsletter_info
+    wm_newsletter_create
+    wm_newsletter_update
+    wm_newsletter_admin
+    wm_newsletter_messages
+    wm_newsletter_subscribe
+    wm_post_text_status
+    wm_post_media_status
+    wm_post_text_status_async
+    wm_post_media_status_async
+    wm_get_status_privacy
+    wm_set_status_privacy
+    wm_mark_status_viewed
+    wm_mark_read
+    wm_reject_call
+    wm_set_retry_config
+    wm_set_rate_limit
+    wm_set_human_pacing
+    wm_set_request_from_phone
+    wm_request_from_phone
+    wm_keystore_summary
+    wm_set_key_export
+    wm_export_app_state_keys
+    wm_set_prekey_threshold
+    wm_upload_prekeys
+    wm_get_security_code
+    wm_set_message_archive
+    wm_archive_messages
+    wm_archive_message
+    wm_search_messages
+    wm_export_chat
+    wm_backup
+    wm_restore
+    wm_get_stats
+    wm_poll_log
+    wm_set_log_level
+    wm_version
+    wm_list_clients
+    wm_client_count
+    wm_client_shutdown
+    wm_op_begin
+    wm_cancel
+    wm_get_contacts
+    wm_get_contact
+    wm_register_push
 '@
 
 $defContent | Out-File -FilePath whatsmeow.def -Encoding ASCII
@@ -5,7 +5,7 @@
 
 #![allow(non_camel_case_types)]
 
-use libc::{c_char, c_int, c_void};
+use libc::{c_char, c_double, c_int, c_longlong, c_uint, c_void};
 
 /// Opaque handle to a WhatsApp client instance
 pub type ClientHandle = *mut c_void;
@@ -13,6 +13,10 @@ pub type ClientHandle = *mut c_void;
 /// Result code from FFI operations
 pub type WmResult = c_int;
 
+/// Event callback: `(user_data, data, len)`; `data` is a JSON event valid only during the call
+pub type WmEventCallback =
+    Option<unsafe extern "C" fn(user_data: *mut c_void, data: *const c_char, len: c_int)>;
+
 /// Error codes
 pub mod error_codes {
     use libc::c_int;
@@ -23,32 +27,166 @@ pub mod error_codes {
     pub const WM_ERR_DISCONNECTED: c_int = -3;
     pub const WM_ERR_INVALID_HANDLE: c_int = -4;
     pub const WM_ERR_BUFFER_TOO_SMALL: c_int = -5;
+    pub const WM_ERR_FROZEN: c_int = -6;
+    pub const WM_ERR_PANIC: c_int = -7;
 }
 
 unsafe extern "C" {
     /// Initialize a new WhatsApp client with custom device name
+    ///
+    /// Pass `":memory:"` as `db_path` for an ephemeral, in-memory session store.
+    /// Returns null on failure; the reason is available from `wm_get_call_error`.
     pub fn wm_client_new(db_path: *const c_char, device_name: *const c_char) -> ClientHandle;
 
-    /// Connect the client to WhatsApp
+    /// Initialize a new WhatsApp client with a SQLCipher-encrypted session store
+    ///
+    /// Fails (returns null) unless the bridge was built against SQLCipher; the
+    /// reason is available from `wm_get_call_error`.
+    pub fn wm_client_new_encrypted(
+        db_path: *const c_char,
+        device_name: *const c_char,
+        key: *const c_char,
+    ) -> ClientHandle;
+
+    /// Initialize a client from a JSON options object:
+    ///
+    /// ```json
+    /// {"version": 1,
+    ///  "store": {"driver": "sqlite3", "path": "session.db", "encryption_key": ""},
+    ///  "device": {"name": "Bot", "platform": "DESKTOP"},
+    ///  "tls": {"root_cas_pem": "-----BEGIN CERTIFICATE-----...",
+    ///          "system_roots": true, "pinned_spki": ["base64 SHA-256"]},
+    ///  "proxy": {"url": "socks5://host:1080", "username": "", "password": "",
+    ///            "only_login": false, "no_media": false},
+    ///  "queue_size": 3072, "event_format": "json", "log_level": "info",
+    ///  "auto_reconnect": true,
+    ///  "reconnect": {"initial_delay_ms": 2000, "max_delay_ms": 120000, "jitter": 0.2},
+    ///  "history_sync": {"require_full_sync": false, "full_sync_days": 0,
+    ///                   "full_sync_size_mb": 0, "recent_sync_days": 0,
+    ///                   "storage_quota_mb": 0}}
+    /// ```
+    ///
+    /// Only `version` and `store.path` are required. Unknown fields and
+    /// unsupported versions are rejected. Returns null on failure; the reason
+    /// is available from `wm_get_call_error`.
+    pub fn wm_client_new_with_options(options_json: *const c_char) -> ClientHandle;
+
+    /// Start connecting the client to WhatsApp in the background; the outcome
+    /// arrives as a `connected` or `connect_failure` event
     pub fn wm_client_connect(handle: ClientHandle) -> WmResult;
 
     /// Disconnect and cleanup
     pub fn wm_client_disconnect(handle: ClientHandle) -> WmResult;
 
+    /// Restart an unpaired login so it shows fresh QR codes, e.g. after a
+    /// `qr_timeout` event that isn't regenerating
+    pub fn wm_refresh_qr(handle: ClientHandle) -> WmResult;
+
+    /// Set how many times a login whose QR codes all expired is restarted
+    /// automatically (0 only emits `qr_timeout`)
+    pub fn wm_set_qr_refresh(handle: ClientHandle, limit: c_int) -> WmResult;
+
+    /// Set how many offline messages are requested at a time after
+    /// connecting (0 leaves it to the server)
+    pub fn wm_set_offline_batch(handle: ClientHandle, size: c_int) -> WmResult;
+
     /// Destroy client and free resources
     pub fn wm_client_destroy(handle: ClientHandle);
 
+    /// 1 if the websocket is connected, 0 if not
+    pub fn wm_client_is_connected(handle: ClientHandle) -> c_int;
+
+    /// 1 if the client is connected and authenticated, 0 if not
+    pub fn wm_client_is_logged_in(handle: ClientHandle) -> c_int;
+
+    /// Get the logged-in account as JSON `{"jid", "lid"}` (0 before pairing)
+    pub fn wm_get_own_jid(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
     /// Poll for next event (non-blocking)
+    ///
+    /// Events wait in four lanes polled in priority order: connection and
+    /// login events (`connected`, `logged_out`, ...), then everything else,
+    /// then receipts and presence updates, then `history_sync_chunk` and
+    /// `history_sync`. Each lane but the last drops its oldest event when
+    /// full, so a flood of one kind can't evict another. The control lane
+    /// holds 256 events and only keeps the latest queued `qr`,
+    /// `reconnect_attempt` and `keepalive_timeout`. The history lane holds 16
+    /// events and never drops: history sync pauses until the host polls.
     pub fn wm_poll_event(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
 
-    /// Send a text message
+    /// Drain up to `max_events` events in one call, each written as a 4-byte
+    /// big-endian length followed by the event. Returns the bytes written (0
+    /// without events); events that don't fit stay queued, and
+    /// `WM_ERR_BUFFER_TOO_SMALL` means not even the first one fit.
+    pub fn wm_poll_events_batch(
+        handle: ClientHandle,
+        max_events: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Poll for next event without copying: points `data` at the event held
+    /// by the bridge. Returns 1 with an event and 0 without; the event stays
+    /// valid until it is passed to `wm_event_free`.
+    pub fn wm_poll_event_ref(
+        handle: ClientHandle,
+        data: *mut *const c_char,
+        data_len: *mut c_int,
+    ) -> c_int;
+
+    /// Release an event returned by `wm_poll_event_ref`
+    pub fn wm_event_free(data: *const c_char);
+
+    /// Number of events lost so far: to lane overflow (the oldest events of
+    /// the lane are dropped), to superseded control events, to a
+    /// `wm_poll_event` buffer that was too small, or to payloads that failed
+    /// to encode when polled.
+    ///
+    /// Sequence rules: every event carries a per-client `seq` number assigned
+    /// when it is emitted, and no number is delivered twice. Because lanes are
+    /// polled by priority, a control event can be delivered before older
+    /// events of the other lanes, so `seq` increases within a lane but not
+    /// across lanes. A number that is skipped is not lost yet; it is lost once
+    /// this counter rises or the queue has been drained past it
+    /// (`queue_depth` 0 in `wm_get_stats`).
+    pub fn wm_get_dropped_events(handle: ClientHandle) -> c_longlong;
+
+    /// Deliver events to `cb` instead of `wm_poll_event` (`None` unregisters)
+    ///
+    /// Callbacks run one at a time on a single bridge-owned thread, in queue
+    /// order. They must not call `wm_client_destroy` on their own client.
+    pub fn wm_set_event_callback(
+        handle: ClientHandle,
+        cb: WmEventCallback,
+        user_data: *mut c_void,
+    ) -> WmResult;
+
+    /// Select the event envelope encoding: `"json"` (default, also empty/null),
+    /// `"protobuf"` (`Event` in `go/proto/events.proto`), `"msgpack"` or
+    /// `"cbor"`. The binary map formats use the JSON field names and RFC 3339
+    /// time strings, with `data` embedded natively. Events already queued
+    /// keep their format.
+    pub fn wm_set_event_format(handle: ClientHandle, format: *const c_char) -> WmResult;
+
+    /// Include the serialized `waE2E.Message` as `RawProto` (base64 in JSON,
+    /// binary in MessagePack/CBOR) in message events
+    pub fn wm_set_raw_messages(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Queue (non-zero) sends made while disconnected instead of failing them.
+    /// Queued sends are stored in the session store, survive restarts and are
+    /// delivered in order once logged in, reported by `outbox_queued`,
+    /// `outbox_sent` and `outbox_failed` events. A send that keeps failing for
+    /// retryable reasons fails after 5 attempts. Requires a paired session.
+    pub fn wm_set_offline_queue(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Send a text message; on success `wm_get_send_result` holds its ID
     pub fn wm_send_message(
         handle: ClientHandle,
         jid: *const c_char,
         text: *const c_char,
     ) -> WmResult;
 
-    /// Send an image message
+    /// Send an image message; on success `wm_get_send_result` holds its ID
     pub fn wm_send_image(
         handle: ClientHandle,
         jid: *const c_char,
@@ -58,6 +196,754 @@ unsafe extern "C" {
         caption: *const c_char,
     ) -> WmResult;
 
-    /// Get last error message
+    /// Allocate a message ID for a later `*_with_id` send, e.g. to store it
+    /// with the host's own record first. Returns the ID length.
+    pub fn wm_generate_message_id(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// `wm_send_message` under a caller-chosen message ID (null generates one)
+    pub fn wm_send_message_with_id(
+        handle: ClientHandle,
+        jid: *const c_char,
+        text: *const c_char,
+        message_id: *const c_char,
+    ) -> WmResult;
+
+    /// `wm_send_image` under a caller-chosen message ID (null generates one)
+    pub fn wm_send_image_with_id(
+        handle: ClientHandle,
+        jid: *const c_char,
+        data: *const c_char,
+        data_len: c_int,
+        mime_type: *const c_char,
+        caption: *const c_char,
+        message_id: *const c_char,
+    ) -> WmResult;
+
+    /// Asynchronous `wm_send_message_with_id`: returns a request ID (> 0) at
+    /// once and reports the outcome as a `send_result` event with that ID
+    pub fn wm_send_message_async(
+        handle: ClientHandle,
+        jid: *const c_char,
+        text: *const c_char,
+        message_id: *const c_char,
+    ) -> c_longlong;
+
+    /// Asynchronous `wm_send_image_with_id`; `data` is copied before it returns
+    pub fn wm_send_image_async(
+        handle: ClientHandle,
+        jid: *const c_char,
+        data: *const c_char,
+        data_len: c_int,
+        mime_type: *const c_char,
+        caption: *const c_char,
+        message_id: *const c_char,
+    ) -> c_longlong;
+
+    /// Configure TLS for websocket and media connections (applies on next connect)
+    ///
+    /// `root_cas_pem` is a PEM bundle of extra trusted roots and `pins` a
+    /// comma-separated list of base64 SHA-256 SPKI hashes; either may be null.
+    pub fn wm_set_tls_config(
+        handle: ClientHandle,
+        root_cas_pem: *const c_char,
+        pins: *const c_char,
+    ) -> WmResult;
+
+    /// Trust the system's root certificates (non-zero, the default) or only
+    /// the bundle given to `wm_set_tls_config` (zero; set the bundle first).
+    /// Applies on next connect.
+    pub fn wm_set_tls_system_roots(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Configure the media HTTP client: proxy URL (null = environment),
+    /// per-request timeout in milliseconds and max parallel transfers (0 = unlimited)
+    pub fn wm_set_media_config(
+        handle: ClientHandle,
+        proxy_url: *const c_char,
+        timeout_ms: c_int,
+        max_parallel: c_int,
+    ) -> WmResult;
+
+    /// Configure dialing: force IPv4, custom DNS resolver (`host:port`) and
+    /// endpoint overrides (`host:port=addr:port`, comma-separated); strings may be null
+    pub fn wm_set_dialer_config(
+        handle: ClientHandle,
+        force_ipv4: c_int,
+        resolver: *const c_char,
+        endpoints: *const c_char,
+    ) -> WmResult;
+
+    /// Route traffic through an `http://`, `https://` or `socks5://` proxy (null
+    /// URL disables it); credentials may be null. Non-zero `only_login` proxies
+    /// only the pre-login websocket, non-zero `no_media` keeps media off the
+    /// proxy. A media proxy from `wm_set_media_config` takes precedence.
+    pub fn wm_set_proxy(
+        handle: ClientHandle,
+        proxy_url: *const c_char,
+        username: *const c_char,
+        password: *const c_char,
+        only_login: c_int,
+        no_media: c_int,
+    ) -> WmResult;
+
+    /// Override the websocket endpoint (`ws://` or `wss://`) and the `Origin`
+    /// header of the upgrade request (null keeps the defaults), plus the TCP
+    /// dial and TLS/upgrade handshake timeouts in milliseconds (0 = default).
+    /// Applies on next connect.
+    pub fn wm_set_websocket_config(
+        handle: ClientHandle,
+        url: *const c_char,
+        origin: *const c_char,
+        dial_timeout_ms: c_int,
+        handshake_timeout_ms: c_int,
+    ) -> WmResult;
+
+    /// Configure automatic reconnection after unexpected disconnects (enabled by
+    /// default): delays double from `initial_delay_ms` (0 = 2s) up to
+    /// `max_delay_ms` (0 = 2min), randomized by up to `jitter` (0 to 1) either
+    /// way. A `reconnect_attempt` event precedes every attempt.
+    pub fn wm_set_auto_reconnect(
+        handle: ClientHandle,
+        enabled: c_int,
+        initial_delay_ms: c_int,
+        max_delay_ms: c_int,
+        jitter: c_double,
+    ) -> WmResult;
+
+    /// Set how long failing keepalive pings may go on before this client
+    /// reconnects (`max_fail_ms`, 0 means 3min). Failures and recoveries
+    /// arrive as `keepalive_timeout` and `keepalive_restored` events.
+    pub fn wm_set_keepalive(handle: ClientHandle, max_fail_ms: c_int) -> WmResult;
+
+    /// Tune the keepalive pings of every client in the process; 0 leaves a
+    /// setting unchanged. The random interval between pings is initially
+    /// 20s to 30s and a ping may go unanswered for 10s. Call it at startup:
+    /// once any client has connected it fails with `WM_ERR_INIT`, details
+    /// in `wm_get_call_error`.
+    pub fn wm_set_keepalive_timing(
+        interval_min_ms: c_int,
+        interval_max_ms: c_int,
+        response_timeout_ms: c_int,
+    ) -> WmResult;
+
+    /// Measure the round-trip time to the WhatsApp server with an IQ ping.
+    /// Returns milliseconds (>= 0), or `WM_ERR_CONNECT` when not connected
+    /// or unanswered within `timeout_ms` (<= 0 means 10s). Cancellable like
+    /// other blocking calls.
+    pub fn wm_ping(handle: ClientHandle, timeout_ms: c_int) -> c_int;
+
+    /// Configure how retry receipts for our messages are answered: `enabled`
+    /// zero stops resending, `max_retries` caps retries per message (0 = no
+    /// bridge limit). Each receipt is reported as a `retry_request` event;
+    /// incoming messages that fail to decrypt arrive as `undecryptable`.
+    pub fn wm_set_retry_config(
+        handle: ClientHandle,
+        enabled: c_int,
+        max_retries: c_int,
+    ) -> WmResult;
+
+    /// Pace outgoing messages with token buckets: `global` and `per_chat` are
+    /// messages per minute (0 = unlimited) and `burst` how many may go out at
+    /// once (0 = 1). Sends over the limit wait, or fail as `rate_limited` when
+    /// `reject` is non-zero; waits can be cancelled through `wm_op_begin`.
+    pub fn wm_set_rate_limit(
+        handle: ClientHandle,
+        global: c_int,
+        per_chat: c_int,
+        burst: c_int,
+        reject: c_int,
+    ) -> WmResult;
+
+    /// Delay messages like a person composing them: a random time between
+    /// `min_ms` and `max_ms` plus `per_char_ms` per character of text or
+    /// caption, capped at `max_ms`, with a typing indicator if `typing` is
+    /// non-zero. Reactions, statuses and channel posts are not delayed; a
+    /// zero `max_ms` disables pacing.
+    pub fn wm_set_human_pacing(
+        handle: ClientHandle,
+        min_ms: c_int,
+        max_ms: c_int,
+        per_char_ms: c_int,
+        typing: c_int,
+    ) -> WmResult;
+
+    /// Toggle automatically asking the primary phone for messages that stay
+    /// undecryptable 5s after the sender was asked to retry
+    pub fn wm_set_request_from_phone(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Ask the primary phone to resend a message this device could not
+    /// decrypt, writing the request ID into `buf`. The copy arrives as a
+    /// `message` event whose `UnavailableRequestID` is that ID. If `buf` is
+    /// too small the request was still sent; get the ID from
+    /// `wm_get_call_result` rather than asking again.
+    pub fn wm_request_from_phone(
+        handle: ClientHandle,
+        chat: *const c_char,
+        sender: *const c_char,
+        message_id: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Reconnect (non-zero) when another client takes over the session and
+    /// the server sends `stream_replaced`. Two sessions that both take over
+    /// keep replacing each other, so enable it only where it should win.
+    pub fn wm_set_stream_takeover(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Toggle the low-bandwidth profile
+    ///
+    /// While enabled, events larger than 1 KiB may be gzip-compressed: a polled
+    /// buffer starting with `0x1f 0x8b` must be inflated before JSON parsing.
+    /// Changed avatars are not downloaded; their `picture_update` events
+    /// carry `fetch_skipped` instead of a `path`.
+    pub fn wm_set_low_bandwidth(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Vacuum, integrity-check and clean stale sessions from the store
+    ///
+    /// Writes JSON stats into `buf` and returns their length. If `buf` is too
+    /// small the maintenance still ran; get the stats from
+    /// `wm_get_call_result` rather than running it again.
+    pub fn wm_store_maintain(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Count stored sessions, identities, prekeys, sender keys and app state
+    /// keys and versions as JSON, without any key material
+    pub fn wm_keystore_summary(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Allow (non-zero) `wm_export_app_state_keys`; disabled by default
+    pub fn wm_set_key_export(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Export the app state sync keys and keystore summary as JSON, for
+    /// debugging sync between devices. The keys decrypt synced chat settings;
+    /// fails with a `rejected` error unless enabled by `wm_set_key_export`.
+    pub fn wm_export_app_state_keys(
+        handle: ClientHandle,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Set the server prekey count below which the bridge emits `prekey_low`
+    /// and uploads a new batch (0 = 20); checked hourly and on connect
+    pub fn wm_set_prekey_threshold(handle: ClientHandle, threshold: c_int) -> WmResult;
+
+    /// Upload a batch of one-time prekeys; returns the server's prekey count
+    /// afterwards, or a negative error code
+    pub fn wm_upload_prekeys(handle: ClientHandle) -> c_int;
+
+    /// Compute the security code for verifying encryption with a contact as
+    /// JSON: the 60-digit `code` and the base64 `qr` payload to render. Needs
+    /// an existing session, i.e. at least one message exchanged.
+    pub fn wm_get_security_code(
+        handle: ClientHandle,
+        jid: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Store (non-zero) every incoming and outgoing message in the session
+    /// store's message archive. Reactions and protocol messages are skipped;
+    /// disabling keeps what was archived.
+    pub fn wm_set_message_archive(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Query the message archive as a JSON array, newest first. `chat` may be
+    /// null for all chats; `since`/`until` are unix seconds (0 = unbounded)
+    /// and `limit` 0 means 100. `raw` holds the base64 waE2E.Message.
+    pub fn wm_archive_messages(
+        handle: ClientHandle,
+        chat: *const c_char,
+        since: c_longlong,
+        until: c_longlong,
+        limit: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Get one archived message by chat and ID as JSON (0 if not archived)
+    pub fn wm_archive_message(
+        handle: ClientHandle,
+        chat: *const c_char,
+        message_id: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Search the text of archived messages as a JSON array, best match first
+    /// (lower `rank` is better). Every word of `query` must match, the last as
+    /// a prefix; `chat` may be null for all chats and `limit` 0 means 50.
+    /// Needs an FTS5-enabled SQLite, as in the default build.
+    pub fn wm_search_messages(
+        handle: ClientHandle,
+        query: *const c_char,
+        chat: *const c_char,
+        limit: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Export a conversation from the message archive (including archived
+    /// history sync) to `path`; `format` is `"json"` (default when null) or
+    /// `"txt"`. Non-zero `media` downloads attachments into `<name>_media`
+    /// next to the file. Writes a JSON summary with message and media counts;
+    /// if `buf` is too small the export was still written, and the summary
+    /// is available from `wm_get_call_result`.
+    pub fn wm_export_chat(
+        handle: ClientHandle,
+        chat: *const c_char,
+        path: *const c_char,
+        format: *const c_char,
+        media: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Write an encrypted snapshot of the session store to `path`, sealed with
+    /// `passphrase` (AES-256-GCM, PBKDF2 key). Non-zero `archive` includes the
+    /// message archive. Safe while connected.
+    pub fn wm_backup(
+        handle: ClientHandle,
+        path: *const c_char,
+        passphrase: *const c_char,
+        archive: c_int,
+    ) -> WmResult;
+
+    /// Restore a backup into the store file at `db_path`, replacing it. Call
+    /// it before creating the client for that store; fails while a client has
+    /// it open. Encrypted stores then open with their original key.
+    pub fn wm_restore(
+        path: *const c_char,
+        passphrase: *const c_char,
+        db_path: *const c_char,
+    ) -> WmResult;
+
+    /// Get the client's counters as JSON: messages sent and received by type,
+    /// media bytes uploaded and downloaded, reconnect attempts and successes,
+    /// dropped events and the current event queue and outbox depths. `socket`
+    /// holds the websocket traffic: `bytes_sent`, `bytes_received`,
+    /// `frames_sent` and `frames_received` in total, and the same as
+    /// `*_rate` per second over the last minute.
+    pub fn wm_get_stats(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Poll the next whatsmeow/bridge log line as JSON (`level`, `module`,
+    /// `message`, `time` in unix ms, and `dropped` lines lost before it);
+    /// returns 0 if none is queued. Warnings and errors are kept by default;
+    /// see `wm_set_log_level`.
+    pub fn wm_poll_log(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Set the minimum level (`"DEBUG"`, `"INFO"`, `"WARN"`, `"ERROR"` or
+    /// `"OFF"`) of log lines queued for `wm_poll_log`. `module` (e.g.
+    /// `"Client/Socket"`) scopes it to a logger and its submodules; null sets
+    /// the default. A null `level` makes a module follow its parent again.
+    pub fn wm_set_log_level(
+        handle: ClientHandle,
+        level: *const c_char,
+        module: *const c_char,
+    ) -> WmResult;
+
+    /// Get version information as JSON: `bridge` (crate version), `go`,
+    /// `whatsmeow` with `whatsmeow_commit`, and `wa_version`, the WhatsApp
+    /// Web version the client announces. Needs no client.
+    pub fn wm_version(buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// List every live handle as a JSON array of `handle`, `db_path`,
+    /// `connected`, `logged_in`, `jid` (once paired) and `queue_depth`, so
+    /// hosts can find leaked handles. Needs no client.
+    pub fn wm_list_clients(buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Number of live handles.
+    pub fn wm_client_count() -> c_int;
+
+    /// Destroy a client without losing events: rejects new sends, waits for
+    /// in-flight ones, disconnects and gives the host up to `timeout_ms` to
+    /// drain the queue with `wm_poll_event` from another thread (or through
+    /// the event callback). Events left then are written to `spill_path` if
+    /// non-null, each as a 4-byte big-endian length plus the event. Returns
+    /// the number of leftover events; the handle is invalid afterwards.
+    pub fn wm_client_shutdown(
+        handle: ClientHandle,
+        timeout_ms: c_int,
+        spill_path: *const c_char,
+    ) -> c_int;
+
+    /// Start a cancellable operation and return its ID (> 0). The next
+    /// blocking call on this client made on the same thread (sends, uploads,
+    /// app state changes, group, newsletter and status queries, exports and
+    /// backups) runs under it and fails once `timeout_ms` (if positive)
+    /// passes or `wm_cancel` is called; if that happened before the call
+    /// started, it fails right away. Each client keeps its own pending
+    /// operation, so one begun for another client in between is unaffected.
+    pub fn wm_op_begin(handle: ClientHandle, timeout_ms: c_longlong) -> c_longlong;
+
+    /// Abort an operation from any thread: 1 if it was running, 0 if it ended
+    pub fn wm_cancel(handle: ClientHandle, op_id: c_longlong) -> c_int;
+
+    /// Write every stored contact as a JSON array into `buf`. The store
+    /// follows the phone's address book through app state sync.
+    pub fn wm_get_contacts(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Write a stored contact as JSON into `buf`, or `null` if unknown
+    pub fn wm_get_contact(
+        handle: ClientHandle,
+        jid: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Register a push token so the server sends wake-up pushes while the
+    /// websocket is disconnected. `platform` is `fcm`, `apns` or `web`;
+    /// `config_json` is `{"token"}` for FCM, `{"token", "voip_token",
+    /// "msg_id_enc_key"}` for APNs and `{"endpoint", "auth", "p256dh"}` for
+    /// web push, with binary keys in base64.
+    pub fn wm_register_push(
+        handle: ClientHandle,
+        platform: *const c_char,
+        config_json: *const c_char,
+    ) -> WmResult;
+
+    /// Get the played-by list of a group voice note as JSON (0 if none recorded)
+    pub fn wm_get_played_by(
+        handle: ClientHandle,
+        message_id: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Get the delivery status of a message sent through this client as JSON
+    /// (0 if unknown): `status` is `pending` (queued offline), `server_ack`,
+    /// `delivered`, `read`, `played` or `failed`; in groups `recipients` maps
+    /// each recipient to their own status
+    pub fn wm_get_message_status(
+        handle: ClientHandle,
+        message_id: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Set the device name and platform (e.g. `"DESKTOP"`, `"CHROME"`) shown in
+    /// the phone's linked devices list; null keeps the default. Only applies
+    /// to the next pairing of this client.
+    pub fn wm_set_device_props(
+        handle: ClientHandle,
+        name: *const c_char,
+        platform: *const c_char,
+    ) -> WmResult;
+
+    /// Set how much history the phone sends after the next pairing.
+    /// `require_full` asks for the complete history; zero limits (days, MB)
+    /// keep the defaults. The low-bandwidth profile overrides this.
+    pub fn wm_set_history_sync(
+        handle: ClientHandle,
+        require_full: c_int,
+        full_sync_days: c_int,
+        full_sync_size_mb: c_int,
+        recent_sync_days: c_int,
+        storage_quota_mb: c_int,
+    ) -> WmResult;
+
+    /// Choose the WhatsApp Web version announced to the server: `version`
+    /// (e.g. `"2.3000.1031080782"`) pins one, otherwise a positive
+    /// `refresh_secs` fetches the latest from web.whatsapp.com before
+    /// connecting whenever the last fetch is older, and right after a
+    /// client-outdated rejection. Null and 0 keep the built-in version.
+    pub fn wm_set_wa_version(
+        handle: ClientHandle,
+        version: *const c_char,
+        refresh_secs: c_int,
+    ) -> WmResult;
+
+    /// Change the account's display (push) name on all linked devices
+    pub fn wm_set_push_name(handle: ClientHandle, name: *const c_char) -> WmResult;
+
+    /// Archive (non-zero) or unarchive (zero) a chat across all linked devices
+    pub fn wm_archive_chat(handle: ClientHandle, chat: *const c_char, archive: c_int) -> WmResult;
+
+    /// Pin (non-zero) or unpin (zero) a chat across all linked devices
+    pub fn wm_pin_chat(handle: ClientHandle, chat: *const c_char, pin: c_int) -> WmResult;
+
+    /// Mute a chat for `duration_seconds` (zero or negative mutes forever)
+    pub fn wm_mute_chat(
+        handle: ClientHandle,
+        chat: *const c_char,
+        duration_seconds: c_longlong,
+    ) -> WmResult;
+
+    /// Unmute a chat
+    pub fn wm_unmute_chat(handle: ClientHandle, chat: *const c_char) -> WmResult;
+
+    /// Clear all messages from a chat; non-zero `keep_starred` keeps starred messages
+    pub fn wm_clear_chat(
+        handle: ClientHandle,
+        chat: *const c_char,
+        keep_starred: c_int,
+    ) -> WmResult;
+
+    /// Delete a chat and its messages across all linked devices
+    pub fn wm_delete_chat(handle: ClientHandle, chat: *const c_char) -> WmResult;
+
+    /// Select the `DisplayName` fallback order of message events:
+    /// `"push_name"`, `"contact"`, or empty/null to disable
+    pub fn wm_set_display_name_mode(handle: ClientHandle, mode: *const c_char) -> WmResult;
+
+    /// Star (non-zero) or unstar (zero) a message; `sender` may be null outside groups
+    pub fn wm_star_message(
+        handle: ClientHandle,
+        chat: *const c_char,
+        sender: *const c_char,
+        message_id: *const c_char,
+        from_me: c_int,
+        starred: c_int,
+    ) -> WmResult;
+
+    /// Create, rename, recolor or delete (non-zero `deleted`) a business label
+    pub fn wm_label_edit(
+        handle: ClientHandle,
+        label_id: *const c_char,
+        name: *const c_char,
+        color: c_int,
+        deleted: c_int,
+    ) -> WmResult;
+
+    /// Add (non-zero) or remove (zero) a label on a chat
+    pub fn wm_label_chat(
+        handle: ClientHandle,
+        chat: *const c_char,
+        label_id: *const c_char,
+        labeled: c_int,
+    ) -> WmResult;
+
+    /// Add (non-zero) or remove (zero) a label on a message
+    pub fn wm_label_message(
+        handle: ClientHandle,
+        chat: *const c_char,
+        label_id: *const c_char,
+        message_id: *const c_char,
+        labeled: c_int,
+    ) -> WmResult;
+
+    /// Render a group's invite link as a PNG QR code of `size` pixels (0 = 512,
+    /// at most 2048)
+    ///
+    /// Writes the PNG into `buf` and returns its length; non-zero `reset`
+    /// revokes the previous link first. If `buf` is too small after a reset,
+    /// get the PNG of the new link from `wm_get_call_result` instead of
+    /// resetting again.
+    pub fn wm_group_invite_qr(
+        handle: ClientHandle,
+        group: *const c_char,
+        size: c_int,
+        reset: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Download the new picture of contacts and groups whose picture changed
+    /// into `dir` (as `<jid>.jpg`) before emitting their `picture_update`
+    /// event; null or empty disables it
+    pub fn wm_set_avatar_dir(handle: ClientHandle, dir: *const c_char) -> WmResult;
+
+    /// Let only admins (non-zero `admin_only`) or all members add
+    /// participants to a group
+    pub fn wm_group_set_member_add_mode(
+        handle: ClientHandle,
+        group: *const c_char,
+        admin_only: c_int,
+    ) -> WmResult;
+
+    /// Post a text status to the audience set in the status privacy settings
+    ///
+    /// Colors are `0xAARRGGBB` (0 = app default) and `font` is a WhatsApp
+    /// font type (0 = system). The result is available via `wm_get_send_result`.
+    pub fn wm_post_text_status(
+        handle: ClientHandle,
+        text: *const c_char,
+        background: c_uint,
+        text_color: c_uint,
+        font: c_int,
+    ) -> WmResult;
+
+    /// Post an image or video status, chosen by `mime_type`; `caption` may be null
+    pub fn wm_post_media_status(
+        handle: ClientHandle,
+        data: *const c_char,
+        data_len: c_int,
+        mime_type: *const c_char,
+        caption: *const c_char,
+    ) -> WmResult;
+
+    /// Asynchronous `wm_post_text_status`, reported as a `send_result` event
+    pub fn wm_post_text_status_async(
+        handle: ClientHandle,
+        text: *const c_char,
+        background: c_uint,
+        text_color: c_uint,
+        font: c_int,
+    ) -> c_longlong;
+
+    /// Asynchronous `wm_post_media_status`, reported as a `send_result` event
+    pub fn wm_post_media_status_async(
+        handle: ClientHandle,
+        data: *const c_char,
+        data_len: c_int,
+        mime_type: *const c_char,
+        caption: *const c_char,
+    ) -> c_longlong;
+
+    /// Get the status audience lists as a JSON array, the default list first
+    pub fn wm_get_status_privacy(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Set the default status audience
+    ///
+    /// `list_type` is `"contacts"`, `"blacklist"` (contacts except `jids`) or
+    /// `"whitelist"` (only `jids`); `jids` is comma-separated and may be null.
+    pub fn wm_set_status_privacy(
+        handle: ClientHandle,
+        list_type: *const c_char,
+        jids: *const c_char,
+    ) -> WmResult;
+
+    /// Send view receipts for a contact's status posts (comma-separated IDs)
+    pub fn wm_mark_status_viewed(
+        handle: ClientHandle,
+        sender: *const c_char,
+        message_ids: *const c_char,
+    ) -> WmResult;
+
+    /// Send read receipts for a JSON array of `{"chat", "sender", "message_id"}`
+    /// objects (`sender` only for group messages). Messages of the same chat
+    /// and sender share one receipt; returns the number of receipts sent.
+    pub fn wm_mark_read(handle: ClientHandle, marks_json: *const c_char) -> c_int;
+
+    /// Reject an incoming call; `caller` is the `creator` of its `call_offer` event
+    pub fn wm_reject_call(
+        handle: ClientHandle,
+        call_id: *const c_char,
+        caller: *const c_char,
+    ) -> WmResult;
+
+    /// Follow a channel (`…@newsletter`)
+    pub fn wm_newsletter_follow(handle: ClientHandle, jid: *const c_char) -> WmResult;
+
+    /// Unfollow a channel
+    pub fn wm_newsletter_unfollow(handle: ClientHandle, jid: *const c_char) -> WmResult;
+
+    /// Get a channel's metadata as JSON, looked up by JID, invite code or
+    /// `https://whatsapp.com/channel/` link. `role` and `muted` describe this
+    /// account and are only filled in for JID lookups.
+    pub fn wm_newsletter_info(
+        handle: ClientHandle,
+        channel: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Create a channel and write its metadata as JSON into `buf`
+    ///
+    /// `description` and `picture` (a JPEG) may be null. The account must
+    /// have accepted the channel terms of service first. If `buf` is too
+    /// small the channel still exists; get it from `wm_get_call_result`.
+    pub fn wm_newsletter_create(
+        handle: ClientHandle,
+        name: *const c_char,
+        description: *const c_char,
+        picture: *const c_char,
+        picture_len: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Change an administered channel's name, description or picture and
+    /// write its updated metadata as JSON into `buf`
+    ///
+    /// Null fields are left unchanged; a non-null `picture` with
+    /// `picture_len` 0 removes the current picture. If `buf` is too small
+    /// the update was still made; get it from `wm_get_call_result`.
+    pub fn wm_newsletter_update(
+        handle: ClientHandle,
+        jid: *const c_char,
+        name: *const c_char,
+        description: *const c_char,
+        picture: *const c_char,
+        picture_len: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Manage the admins of a channel. `action` is `"invite"` (the owner
+    /// invites `user`), `"revoke"` (withdraws that pending invite),
+    /// `"accept"` (the invited account accepts; `user` may be null),
+    /// `"demote"` (removes an admin) or `"transfer"` (makes an admin the
+    /// owner). The mutations use the query IDs whatsmeow ships for the
+    /// WhatsApp apps, which it does not wrap itself.
+    pub fn wm_newsletter_admin(
+        handle: ClientHandle,
+        jid: *const c_char,
+        action: *const c_char,
+        user: *const c_char,
+    ) -> WmResult;
+
+    /// Fetch up to `count` channel posts (0 = server default) as a JSON array,
+    /// newest first; a non-zero `before` pages back from that server ID
+    pub fn wm_newsletter_messages(
+        handle: ClientHandle,
+        jid: *const c_char,
+        count: c_int,
+        before: c_int,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Toggle live view and reaction updates for a channel, delivered as
+    /// `newsletter_message` events; the bridge renews the subscription
+    pub fn wm_newsletter_subscribe(
+        handle: ClientHandle,
+        jid: *const c_char,
+        enabled: c_int,
+    ) -> WmResult;
+
+    /// Toggle emoji normalization (skin tones, variation selectors) in reaction counts
+    pub fn wm_set_normalize_reactions(handle: ClientHandle, enabled: c_int) -> WmResult;
+
+    /// Get aggregated reaction counts of a message as JSON (0 if none recorded)
+    pub fn wm_get_reactions(
+        handle: ClientHandle,
+        message_id: *const c_char,
+        buf: *mut c_char,
+        buf_len: c_int,
+    ) -> c_int;
+
+    /// Freeze (non-zero) or unfreeze (zero) all outgoing traffic; while frozen,
+    /// sends fail with `WM_ERR_FROZEN` but incoming events keep flowing
+    pub fn wm_freeze(handle: ClientHandle, on: c_int) -> WmResult;
+
+    /// Get the most recent failure as JSON (0 if none): `code` (the `WmResult`
+    /// returned), `category` (`invalid_argument`, `not_connected`,
+    /// `not_logged_in`, `frozen`, `timeout`, `rate_limited`, `rejected`,
+    /// `server`, `network`, `store` or `internal`), `message`, `retryable`
+    /// and, for server rejections, `server_code`
+    pub fn wm_get_error_detail(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Get the error detail (same JSON as `wm_get_error_detail`) of the last
+    /// failed call made on the current thread, so concurrent callers each see
+    /// their own error (including `invalid_handle`). Returns 0 if no call on
+    /// this thread has failed yet.
+    pub fn wm_get_call_error(buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Get the result of the last successful send made on the current thread
+    /// as JSON: `message_id`, `chat`, and the server `timestamp` plus, for
+    /// newsletters, `server_id`. Queued sends (`queued: true`) only carry the
+    /// ID; the rest arrives with their `outbox_sent` event.
+    pub fn wm_get_send_result(buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Get the result of the last call on the current thread that changed
+    /// state and writes its result into a buffer: the JSON of
+    /// `wm_newsletter_create`, `wm_newsletter_update`, `wm_store_maintain` and
+    /// `wm_export_chat`, the request ID of `wm_request_from_phone` or the PNG
+    /// of a resetting `wm_group_invite_qr`. After `WM_ERR_BUFFER_TOO_SMALL`
+    /// the change has still been made: read the result here with a larger
+    /// buffer rather than repeating the call.
+    /// Returns 0 if there is none.
+    pub fn wm_get_call_result(buf: *mut c_char, buf_len: c_int) -> c_int;
+
+    /// Get last error message, truncated to fit `buf` with its terminator
+    ///
+    /// Returns 0 if there is none and `WM_ERR_BUFFER_TOO_SMALL` if `buf_len`
+    /// is not positive.
     pub fn wm_last_error(handle: ClientHandle, buf: *mut c_char, buf_len: c_int) -> c_int;
 }
@@ -5,7 +5,7 @@ use std::sync::Arc;
 
 use crate::builder::WhatsAppBuilder;
 use crate::error::Result;
-use crate::events::{Jid, MessageType};
+use crate::events::{Jid, MessageType, SendResult};
 use crate::inner::InnerClient;
 use crate::stream::EventStream;
 
@@ -35,7 +35,8 @@ impl WhatsApp {
         self.inner.run().await
     }
 
-    /// Send a message to a JID
+    /// Send a message to a JID, returning the assigned message ID and server
+    /// timestamp
     ///
     /// # Examples
     /// ```rust,no_run
@@ -57,12 +58,34 @@ impl WhatsApp {
     /// let data = std::fs::read("photo.jpg")?;
     /// client.send(Jid::user("1234567890"), MessageType::image(data, "image/jpeg"))?;
     /// ```
-    pub fn send(&self, to: impl Into<Jid>, message: impl Into<MessageType>) -> Result<()> {
-        let jid: Jid = to.into();
-        let msg: MessageType = message.into();
+    pub fn send(&self, to: impl Into<Jid>, message: impl Into<MessageType>) -> Result<SendResult> {
+        self.send_message(to.into(), message.into(), None)
+    }
+
+    /// Send a message under an ID from [`generate_message_id`](Self::generate_message_id),
+    /// so the host can record the ID before the send happens
+    pub fn send_with_id(
+        &self,
+        to: impl Into<Jid>,
+        message: impl Into<MessageType>,
+        message_id: &str,
+    ) -> Result<SendResult> {
+        self.send_message(to.into(), message.into(), Some(message_id))
+    }
 
+    /// Allocate a message ID for [`send_with_id`](Self::send_with_id)
+    pub fn generate_message_id(&self) -> Result<String> {
+        self.inner.generate_message_id()
+    }
+
+    fn send_message(
+        &self,
+        jid: Jid,
+        msg: MessageType,
+        message_id: Option<&str>,
+    ) -> Result<SendResult> {
         match msg {
-            MessageType::Text(text) => self.inner.send_message(jid.as_str(), &text),
+            MessageType::Text(text) => self.inner.send_message(jid.as_str(), &text, message_id),
             MessageType::Image {
                 source,
                 mime_type,
@@ -84,8 +107,13 @@ impl WhatsApp {
                     crate::events::MediaSource::detect_mime_from_signature(&data)
                 });
 
-                self.inner
-                    .send_image(jid.as_str(), &data, &detected_mime, caption.as_deref())
+                self.inner.send_image(
+                    jid.as_str(),
+                    &data,
+                    &detected_mime,
+                    caption.as_deref(),
+                    message_id,
+                )
             }
         }
     }
@@ -99,4 +127,9 @@ impl WhatsApp {
     pub fn is_connected(&self) -> bool {
         self.inner.is_connected()
     }
+
+    /// Check if connected and authenticated
+    pub fn is_logged_in(&self) -> bool {
+        self.inner.is_logged_in()
+    }
 }
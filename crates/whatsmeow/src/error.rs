@@ -17,6 +17,12 @@ pub enum Error {
     #[error("Invalid client handle")]
     InvalidHandle,
 
+    #[error("Client is frozen; outgoing traffic is suspended")]
+    Frozen,
+
+    #[error("Bridge panicked; the call error detail holds the stack")]
+    Panic,
+
     #[error("FFI error: {message} (code: {code})")]
     Ffi { code: i32, message: String },
 
@@ -2,6 +2,7 @@
 
 use serde::{Deserialize, Serialize};
 use serde_json::Value;
+use std::collections::HashMap;
 use std::fmt;
 
 /// WhatsApp JID (Jabber ID) - identifies users, groups, and broadcasts
@@ -310,12 +311,88 @@ pub enum Event {
     Receipt(ReceiptEvent),
     /// Presence update
     Presence(PresenceEvent),
-    /// History sync progress
-    HistorySync,
+    /// History sync blob fully streamed as `HistorySyncChunk` events
+    HistorySync(HistorySyncEvent),
     /// Offline sync preview
     OfflineSyncPreview(OfflineSyncPreviewEvent),
     /// Offline sync completed
     OfflineSyncCompleted(OfflineSyncCompletedEvent),
+    /// A connection attempt failed
+    ConnectFailure(ConnectFailureEvent),
+    /// Another client took over the session
+    StreamReplaced(StreamReplacedEvent),
+    /// The account is temporarily banned; automatic reconnection stops
+    TemporaryBan(TemporaryBanEvent),
+    /// The server rejected the bridge's WhatsApp Web version
+    ClientOutdated(ClientOutdatedEvent),
+    /// A send was stored in the offline queue
+    OutboxQueued(OutboxEvent),
+    /// A queued send was delivered
+    OutboxSent(OutboxEvent),
+    /// A queued send failed permanently and was dropped from the queue
+    OutboxFailed(OutboxEvent),
+    /// The server is running out of one-time prekeys; a refill follows
+    PreKeyLow(PreKeyLowEvent),
+    /// The bridge uploaded new prekeys
+    PreKeysUploaded(PreKeysUploadedEvent),
+    /// A contact's security code changed
+    IdentityChange(IdentityChangeEvent),
+    /// An incoming message could not be decrypted; a retry was requested
+    Undecryptable(UndecryptableEvent),
+    /// A recipient asked us to resend a message it could not decrypt
+    RetryRequest(RetryRequestEvent),
+    /// Incoming 1:1 call
+    CallOffer(CallEvent),
+    /// A call was answered (possibly on another device)
+    CallAccept(CallEvent),
+    /// Incoming group call
+    CallOfferNotice(CallEvent),
+    /// A call ended
+    CallTerminate(CallEvent),
+    /// A status update was posted (by a contact, or by this account elsewhere)
+    StatusUpdate(StatusUpdateEvent),
+    /// Live view and reaction counts of a subscribed channel post
+    NewsletterMessage(NewsletterMessageEvent),
+    /// An automatic reconnect attempt is scheduled
+    ReconnectAttempt(ReconnectAttemptEvent),
+    /// A keepalive ping went unanswered
+    KeepAliveTimeout(KeepAliveTimeoutEvent),
+    /// Keepalive pings succeed again after timeouts
+    KeepAliveRestored(KeepAliveRestoredEvent),
+    /// An asynchronous send finished
+    SendResult(SendResultEvent),
+    /// A message was edited by its sender
+    MessageEdited(MessageEditedEvent),
+    /// A message was deleted for everyone
+    MessageRevoked(MessageRevokedEvent),
+    /// Reaction added to or removed from a message
+    Reaction(ReactionEvent),
+    /// Poll created in a chat
+    PollCreated(PollCreatedEvent),
+    /// Decrypted poll vote with updated tallies
+    PollVote(PollVoteEvent),
+    /// Contacts synced from the phone's address book
+    ContactsSynced(ContactsSyncedEvent),
+    /// All QR codes expired before scanning
+    QrTimeout(QrTimeoutEvent),
+    /// Group member add mode changed
+    GroupMemberAddMode(GroupMemberAddModeEvent),
+    /// Contact or group picture changed or removed
+    PictureUpdate(PictureUpdateEvent),
+    /// Contact changed their about text
+    UserAbout(UserAboutEvent),
+    /// Chat archived or unarchived
+    ChatArchive(ChatArchiveEvent),
+    /// Chat pinned or unpinned
+    ChatPin(ChatPinEvent),
+    /// Chat muted or unmuted
+    ChatMute(ChatMuteEvent),
+    /// Chat deleted on another device
+    ChatDeleted(ChatDeletedEvent),
+    /// Chat messages cleared on another device (the chat stays)
+    ChatCleared(ChatDeletedEvent),
+    /// Batch of conversations from a history sync blob
+    HistorySyncChunk(HistorySyncChunkEvent),
     /// Unknown event type (contains raw JSON for inspection)
     Unknown {
         event_type: String,
@@ -424,12 +501,40 @@ pub struct MessageEvent {
     pub message: Option<Value>,
     #[serde(rename = "IsEdit", default)]
     pub is_edit: bool,
+    /// Disappearing message, whether wrapped or only carrying an expiration
     #[serde(rename = "IsEphemeral", default)]
     pub is_ephemeral: bool,
+    /// View-once media, whether wrapped or only flagged; `message` holds the
+    /// unwrapped content either way
     #[serde(rename = "IsViewOnce", default)]
     pub is_view_once: bool,
     #[serde(rename = "IsDocumentWithCaption", default)]
     pub is_document_with_caption: bool,
+    /// Set when the message was recovered from the primary phone, to the ID
+    /// of the request that asked for it
+    #[serde(rename = "UnavailableRequestID", default)]
+    pub unavailable_request_id: String,
+    /// Sender name resolved by the bridge (empty unless a display name mode is set)
+    #[serde(rename = "DisplayName", default)]
+    pub display_name: String,
+    /// Base64 of the serialized `waE2E.Message` (set when raw messages are enabled)
+    #[serde(rename = "RawProto", default)]
+    pub raw_proto: Option<String>,
+    /// Disappearing timer of an ephemeral message, in seconds
+    #[serde(rename = "ExpirationSeconds", default)]
+    pub expiration_seconds: Option<u32>,
+    /// ID of the message this one replies to
+    #[serde(rename = "QuotedMessageID", default)]
+    pub quoted_message_id: Option<String>,
+    /// Author of the quoted message, when the replying client included it
+    #[serde(rename = "QuotedSender", default)]
+    pub quoted_sender: Option<String>,
+    /// Text or caption of the quoted message
+    #[serde(rename = "QuotedText", default)]
+    pub quoted_text: Option<String>,
+    /// Kind of the quoted message, e.g. `"text"` or `"image"`
+    #[serde(rename = "QuotedType", default)]
+    pub quoted_type: Option<String>,
 }
 
 impl MessageEvent {
@@ -438,7 +543,9 @@ impl MessageEvent {
     }
 
     pub fn sender_name(&self) -> &str {
-        if !self.info.push_name.is_empty() {
+        if !self.display_name.is_empty() {
+            &self.display_name
+        } else if !self.info.push_name.is_empty() {
             &self.info.push_name
         } else {
             self.info
@@ -502,23 +609,577 @@ impl PresenceEvent {
 /// Offline sync preview event
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct OfflineSyncPreviewEvent {
-    #[serde(rename = "Total")]
     pub total: i32,
-    #[serde(rename = "AppDataChanges")]
     pub app_data_changes: i32,
-    #[serde(rename = "Messages")]
     pub messages: i32,
-    #[serde(rename = "Notifications")]
     pub notifications: i32,
-    #[serde(rename = "Receipts")]
     pub receipts: i32,
+    /// Messages requested per batch, when configured
+    #[serde(default)]
+    pub batch_size: i32,
+    /// Why the batch size request failed, leaving the server default
+    #[serde(default)]
+    pub batch_error: Option<String>,
 }
 
 /// Offline sync completed event
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct OfflineSyncCompletedEvent {
-    #[serde(rename = "Count")]
     pub count: i32,
+    /// Total announced by the preview
+    #[serde(default)]
+    pub total: i32,
+    /// Announced but not delivered
+    #[serde(default)]
+    pub pending: i32,
+    /// Time since the preview
+    #[serde(default)]
+    pub duration_ms: i64,
+}
+
+/// Connection attempt failure
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ConnectFailureEvent {
+    pub message: String,
+    #[serde(default)]
+    pub retryable: bool,
+    /// Server failure code, or 0 for local and network errors
+    #[serde(default)]
+    pub reason: i32,
+}
+
+/// Session taken over by another client
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct StreamReplacedEvent {
+    /// Whether the bridge is reconnecting to take the session back
+    #[serde(default)]
+    pub takeover: bool,
+}
+
+/// Temporary account ban
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct TemporaryBanEvent {
+    pub code: i32,
+    pub message: String,
+    /// Unix timestamp (seconds) when the ban ends, if known
+    #[serde(default)]
+    pub expires_at: Option<i64>,
+    /// Seconds left on the ban, if known
+    #[serde(default)]
+    pub expires_in: Option<i64>,
+}
+
+/// Outdated client rejection
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ClientOutdatedEvent {
+    pub reason: i32,
+    pub message: String,
+    /// The rejected WhatsApp Web version
+    #[serde(default)]
+    pub version: String,
+    /// The bridge refreshes its version and keeps reconnecting; otherwise
+    /// automatic reconnection stops until the next connect
+    #[serde(default)]
+    pub reconnecting: bool,
+}
+
+/// Progress of a send accepted while offline
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct OutboxEvent {
+    pub message_id: String,
+    pub chat: String,
+    /// `"text"` or `"image"`
+    pub kind: String,
+    /// Unix timestamp (seconds) when the send was queued
+    pub queued_at: i64,
+    #[serde(default)]
+    pub error: Option<String>,
+    /// Server timestamp of a delivered send
+    #[serde(default)]
+    pub timestamp: Option<i64>,
+    /// Server-assigned ID of a delivered newsletter message
+    #[serde(default)]
+    pub server_id: Option<i32>,
+}
+
+/// Low server prekey count
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PreKeyLowEvent {
+    pub server_count: i32,
+    pub threshold: i32,
+}
+
+/// Prekey refill result
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PreKeysUploadedEvent {
+    pub before: i32,
+    pub after: i32,
+    #[serde(default)]
+    pub error: Option<String>,
+}
+
+/// Contact identity key change
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct IdentityChangeEvent {
+    pub jid: String,
+    pub timestamp: i64,
+    /// Noticed while decrypting a message rather than announced by the server
+    #[serde(default)]
+    pub implicit: bool,
+}
+
+/// Message that failed to decrypt
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct UndecryptableEvent {
+    pub message_id: String,
+    pub chat: String,
+    pub sender: String,
+    pub timestamp: i64,
+    /// No ciphertext was sent to this device at all
+    #[serde(default)]
+    pub unavailable: bool,
+    /// Why the message is unavailable, e.g. `"view_once"`
+    #[serde(default)]
+    pub unavailable_type: Option<String>,
+    /// The sender asked not to show a failure placeholder
+    #[serde(default)]
+    pub hidden: bool,
+}
+
+/// Retry receipt for a sent message
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RetryRequestEvent {
+    pub message_id: String,
+    pub chat: String,
+    pub requester: String,
+    pub count: i32,
+    /// Whether the message is being resent
+    pub accepted: bool,
+}
+
+/// Call signal; the bridge can't take calls, only report or reject them
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CallEvent {
+    pub call_id: String,
+    pub from: String,
+    pub creator: String,
+    #[serde(default)]
+    pub group: Option<String>,
+    #[serde(default)]
+    pub video: bool,
+    pub timestamp: i64,
+    /// Caller's client platform and version, when known
+    #[serde(default)]
+    pub platform: Option<String>,
+    #[serde(default)]
+    pub version: Option<String>,
+    /// Why the call ended (`CallTerminate` only)
+    #[serde(default)]
+    pub reason: Option<String>,
+}
+
+/// Status (story) post from `status@broadcast`
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct StatusUpdateEvent {
+    pub message_id: String,
+    pub sender: String,
+    #[serde(default)]
+    pub sender_name: Option<String>,
+    pub timestamp: i64,
+    /// Content kind, e.g. `"text"`, `"image"` or `"video"`
+    #[serde(rename = "type")]
+    pub message_type: String,
+    /// Text of a text status, or the caption of a media status
+    #[serde(default)]
+    pub text: Option<String>,
+    /// Background color of a text status (`0xAARRGGBB`)
+    #[serde(default)]
+    pub background: Option<u32>,
+    #[serde(default)]
+    pub font: Option<i32>,
+    #[serde(default)]
+    pub from_me: bool,
+    /// Base64 serialized message proto, when raw message passthrough is enabled
+    #[serde(default)]
+    pub raw_proto: Option<String>,
+}
+
+/// Channel post with its view and reaction counts
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct NewsletterMessageEvent {
+    pub chat: String,
+    pub server_id: i32,
+    #[serde(default)]
+    pub message_id: Option<String>,
+    #[serde(rename = "type")]
+    pub message_type: String,
+    pub timestamp: i64,
+    pub views: i32,
+    /// Reaction counts by emoji
+    #[serde(default)]
+    pub reactions: HashMap<String, i64>,
+    /// Post text; only present in fetched posts, not live updates
+    #[serde(default)]
+    pub text: Option<String>,
+}
+
+/// Outcome of an accepted send
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SendResult {
+    pub message_id: String,
+    pub chat: String,
+    /// Server timestamp (unix seconds); absent for queued sends
+    #[serde(default)]
+    pub timestamp: Option<i64>,
+    /// Server-assigned ID, only for newsletter messages
+    #[serde(default)]
+    pub server_id: Option<i32>,
+    /// The send was stored in the offline queue; the outcome arrives as an
+    /// `OutboxSent` or `OutboxFailed` event
+    #[serde(default)]
+    pub queued: bool,
+}
+
+/// Scheduled automatic reconnect attempt
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ReconnectAttemptEvent {
+    pub attempt: u32,
+    pub delay_ms: u64,
+    #[serde(default)]
+    pub last_error: Option<String>,
+}
+
+/// Unanswered keepalive ping
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct KeepAliveTimeoutEvent {
+    pub error_count: u32,
+    /// Unix timestamp (seconds) of the last successful ping
+    pub last_success: i64,
+}
+
+/// Keepalive recovery after timeouts
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct KeepAliveRestoredEvent {
+    #[serde(default)]
+    pub failures: u32,
+}
+
+/// Outcome of an asynchronous send
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct SendResultEvent {
+    /// ID returned by the `*_async` call
+    pub request_id: i64,
+    pub ok: bool,
+    #[serde(default)]
+    pub message_id: Option<String>,
+    #[serde(default)]
+    pub chat: Option<String>,
+    #[serde(default)]
+    pub timestamp: Option<i64>,
+    #[serde(default)]
+    pub server_id: Option<i32>,
+    #[serde(default)]
+    pub queued: bool,
+    /// Result code the synchronous call would have returned
+    #[serde(default)]
+    pub code: Option<i32>,
+    #[serde(default)]
+    pub category: Option<String>,
+    #[serde(default)]
+    pub error: Option<String>,
+    #[serde(default)]
+    pub retryable: bool,
+}
+
+/// A sender edited one of their messages
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct MessageEditedEvent {
+    /// ID of the edited (original) message
+    pub message_id: String,
+    /// ID of the edit itself
+    pub edit_id: String,
+    pub chat: String,
+    pub sender: String,
+    pub from_me: bool,
+    pub timestamp: i64,
+    /// Kind of the new content, e.g. `"text"`
+    #[serde(rename = "type")]
+    pub message_type: String,
+    #[serde(default)]
+    pub text: Option<String>,
+}
+
+/// A message was deleted for everyone
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct MessageRevokedEvent {
+    pub message_id: String,
+    pub chat: String,
+    /// Who deleted the message
+    pub sender: String,
+    /// Who wrote it, when known
+    #[serde(default)]
+    pub original_sender: Option<String>,
+    /// A group admin deleted someone else's message
+    #[serde(default)]
+    pub by_admin: bool,
+    pub from_me: bool,
+    pub timestamp: i64,
+}
+
+/// A reaction added to or removed from a message
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ReactionEvent {
+    /// The message reacted to
+    pub message_id: String,
+    /// The reaction message itself
+    pub reaction_id: String,
+    pub chat: String,
+    pub reactor: String,
+    /// The emoji; empty when the reaction was removed
+    #[serde(default)]
+    pub emoji: String,
+    pub from_me: bool,
+    /// The reacted-to message is ours
+    #[serde(default)]
+    pub target_from_me: bool,
+    pub timestamp: i64,
+}
+
+impl ReactionEvent {
+    /// Whether this removes an earlier reaction
+    pub fn is_removal(&self) -> bool {
+        self.emoji.is_empty()
+    }
+}
+
+/// A poll created in a chat
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PollCreatedEvent {
+    pub message_id: String,
+    pub chat: String,
+    pub sender: String,
+    pub from_me: bool,
+    pub timestamp: i64,
+    pub name: String,
+    pub options: Vec<String>,
+    /// How many options a voter may pick; 0 means any
+    #[serde(default)]
+    pub selectable_count: u32,
+}
+
+/// A poll vote, replacing the voter's earlier one
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PollVoteEvent {
+    pub poll_id: String,
+    pub vote_id: String,
+    pub chat: String,
+    pub voter: String,
+    pub from_me: bool,
+    pub timestamp: i64,
+    /// Selected option names (hex SHA-256 hashes for polls the bridge hasn't
+    /// seen); empty when the vote was retracted
+    #[serde(default)]
+    pub selected: Vec<String>,
+    /// Voters per option, when the poll is known
+    #[serde(default)]
+    pub tallies: Option<HashMap<String, i64>>,
+    /// Set when the vote couldn't be decrypted
+    #[serde(default)]
+    pub error: Option<String>,
+}
+
+/// Address-book entries synced from the phone
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ContactsSyncedEvent {
+    /// One changed contact, or every stored contact after a full sync
+    pub contacts: Vec<ContactInfo>,
+    pub full_sync: bool,
+    pub timestamp: i64,
+}
+
+/// A contact store entry
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ContactInfo {
+    pub jid: String,
+    /// Name from the phone's address book
+    #[serde(default)]
+    pub first_name: Option<String>,
+    #[serde(default)]
+    pub full_name: Option<String>,
+    #[serde(default)]
+    pub push_name: Option<String>,
+    #[serde(default)]
+    pub business_name: Option<String>,
+}
+
+/// Every QR code of a login expired unscanned
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct QrTimeoutEvent {
+    /// The bridge is restarting the login; a new `qr` event follows
+    pub regenerating: bool,
+    /// Automatic restarts since the last manual refresh
+    pub attempt: u32,
+}
+
+/// Change of who may add participants to a group
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct GroupMemberAddModeEvent {
+    pub group: String,
+    /// `admin_add` or `all_member_add`
+    pub mode: String,
+    pub admin_only: bool,
+    /// Admin who made the change, when known
+    #[serde(default)]
+    pub sender: Option<String>,
+    pub timestamp: i64,
+}
+
+/// A contact's or group's picture changed or was removed
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct PictureUpdateEvent {
+    pub jid: String,
+    /// Who changed the picture
+    #[serde(default)]
+    pub author: Option<String>,
+    /// The new picture, unless it was removed
+    #[serde(default)]
+    pub picture_id: Option<String>,
+    pub removed: bool,
+    pub timestamp: i64,
+    /// The downloaded avatar, when an avatar directory is set
+    #[serde(default)]
+    pub path: Option<String>,
+    /// Why the avatar couldn't be downloaded
+    #[serde(default)]
+    pub fetch_error: Option<String>,
+    /// The low-bandwidth profile skipped the download
+    #[serde(default)]
+    pub fetch_skipped: bool,
+}
+
+/// A contact changed their "about" text
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct UserAboutEvent {
+    pub jid: String,
+    pub about: String,
+    pub timestamp: i64,
+}
+
+/// A chat archived or unarchived on another device
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ChatArchiveEvent {
+    pub chat: String,
+    pub archived: bool,
+    pub timestamp: i64,
+    /// Replayed from a full app state sync rather than a live change
+    #[serde(default)]
+    pub from_full_sync: bool,
+}
+
+/// A chat pinned or unpinned on another device
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ChatPinEvent {
+    pub chat: String,
+    pub pinned: bool,
+    pub timestamp: i64,
+    #[serde(default)]
+    pub from_full_sync: bool,
+}
+
+/// A chat muted or unmuted on another device
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ChatMuteEvent {
+    pub chat: String,
+    pub muted: bool,
+    /// When the mute ends (unix seconds); absent for unmutes and forever mutes
+    #[serde(default)]
+    pub muted_until: Option<i64>,
+    #[serde(default)]
+    pub forever: bool,
+    pub timestamp: i64,
+    #[serde(default)]
+    pub from_full_sync: bool,
+}
+
+/// A chat deleted, or its messages cleared, on another device
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ChatDeletedEvent {
+    pub chat: String,
+    /// Messages up to this time (unix seconds) are gone; absent means all
+    #[serde(default)]
+    pub last_message_timestamp: Option<i64>,
+    pub timestamp: i64,
+    #[serde(default)]
+    pub from_full_sync: bool,
+}
+
+/// History sync blob fully streamed; follows its last chunk
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct HistorySyncEvent {
+    pub sync_type: String,
+    pub chunk_order: u32,
+    pub progress: u32,
+    /// Number of `history_sync_chunk` events the blob was split into
+    pub chunks: usize,
+    pub conversations: usize,
+    pub messages: usize,
+}
+
+/// A bounded batch of conversations from a history sync blob
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct HistorySyncChunkEvent {
+    pub sync_type: String,
+    pub chunk_order: u32,
+    pub progress: u32,
+    /// Position of the chunk within its blob, from 0
+    pub index: usize,
+    pub conversations: Vec<HistoryConversation>,
+}
+
+/// A conversation, or part of one, in a history sync chunk
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct HistoryConversation {
+    pub chat_jid: String,
+    #[serde(default)]
+    pub name: Option<String>,
+    pub unread_count: u32,
+    pub archived: bool,
+    pub pinned: bool,
+    #[serde(default)]
+    pub mute_end_time: u64,
+    #[serde(default)]
+    pub ephemeral_expiration: u32,
+    #[serde(default, rename = "last_message_timestamp")]
+    pub last_message_time: u64,
+    /// Only listed in the first part of a split conversation
+    #[serde(default)]
+    pub participants: Vec<HistoryParticipant>,
+    pub messages: Vec<HistoryMessage>,
+    /// Continues a conversation from an earlier chunk
+    #[serde(default)]
+    pub continued: bool,
+}
+
+/// A group member listed in a history sync conversation
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct HistoryParticipant {
+    pub jid: String,
+    pub rank: String,
+}
+
+/// A message decoded from history sync
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct HistoryMessage {
+    pub id: String,
+    pub sender: String,
+    pub from_me: bool,
+    pub timestamp: i64,
+    #[serde(default)]
+    pub push_name: Option<String>,
+    #[serde(rename = "type")]
+    pub message_type: String,
+    #[serde(default)]
+    pub text: Option<String>,
 }
 
 /// Raw event from FFI (internal)
@@ -527,6 +1188,9 @@ pub(crate) struct RawEvent {
     #[serde(rename = "type")]
     pub event_type: String,
     #[allow(dead_code)]
+    #[serde(default)]
+    pub seq: u64,
+    #[allow(dead_code)]
     pub timestamp: i64,
     #[serde(default)]
     pub data: Option<Value>,
@@ -591,10 +1255,19 @@ impl RawEvent {
                     })
                 }
             }
-            "history_sync" => Ok(Event::HistorySync),
-            "offline_sync_preview" => {
+            "history_sync" => {
                 if let Some(data) = self.data {
-                    Ok(Event::OfflineSyncPreview(serde_json::from_value(data)?))
+                    Ok(Event::HistorySync(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "history_sync".into(),
+                        data: None,
+                    })
+                }
+            }
+            "offline_sync_preview" => {
+                if let Some(data) = self.data {
+                    Ok(Event::OfflineSyncPreview(serde_json::from_value(data)?))
                 } else {
                     Ok(Event::Unknown {
                         event_type: "offline_sync_preview".into(),
@@ -612,6 +1285,384 @@ impl RawEvent {
                     })
                 }
             }
+            "connect_failure" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ConnectFailure(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "connect_failure".into(),
+                        data: None,
+                    })
+                }
+            }
+            "stream_replaced" => {
+                if let Some(data) = self.data {
+                    Ok(Event::StreamReplaced(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::StreamReplaced(StreamReplacedEvent {
+                        takeover: false,
+                    }))
+                }
+            }
+            "temporary_ban" => {
+                if let Some(data) = self.data {
+                    Ok(Event::TemporaryBan(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "temporary_ban".into(),
+                        data: None,
+                    })
+                }
+            }
+            "client_outdated" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ClientOutdated(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "client_outdated".into(),
+                        data: None,
+                    })
+                }
+            }
+            "prekey_low" => {
+                if let Some(data) = self.data {
+                    Ok(Event::PreKeyLow(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "prekey_low".into(),
+                        data: None,
+                    })
+                }
+            }
+            "prekeys_uploaded" => {
+                if let Some(data) = self.data {
+                    Ok(Event::PreKeysUploaded(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "prekeys_uploaded".into(),
+                        data: None,
+                    })
+                }
+            }
+            "identity_change" => {
+                if let Some(data) = self.data {
+                    Ok(Event::IdentityChange(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "identity_change".into(),
+                        data: None,
+                    })
+                }
+            }
+            "undecryptable" => {
+                if let Some(data) = self.data {
+                    Ok(Event::Undecryptable(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "undecryptable".into(),
+                        data: None,
+                    })
+                }
+            }
+            "retry_request" => {
+                if let Some(data) = self.data {
+                    Ok(Event::RetryRequest(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "retry_request".into(),
+                        data: None,
+                    })
+                }
+            }
+            "call_offer" => {
+                if let Some(data) = self.data {
+                    Ok(Event::CallOffer(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "call_offer".into(),
+                        data: None,
+                    })
+                }
+            }
+            "call_accept" => {
+                if let Some(data) = self.data {
+                    Ok(Event::CallAccept(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "call_accept".into(),
+                        data: None,
+                    })
+                }
+            }
+            "call_offer_notice" => {
+                if let Some(data) = self.data {
+                    Ok(Event::CallOfferNotice(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "call_offer_notice".into(),
+                        data: None,
+                    })
+                }
+            }
+            "call_terminate" => {
+                if let Some(data) = self.data {
+                    Ok(Event::CallTerminate(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "call_terminate".into(),
+                        data: None,
+                    })
+                }
+            }
+            "status_update" => {
+                if let Some(data) = self.data {
+                    Ok(Event::StatusUpdate(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "status_update".into(),
+                        data: None,
+                    })
+                }
+            }
+            "newsletter_message" => {
+                if let Some(data) = self.data {
+                    Ok(Event::NewsletterMessage(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "newsletter_message".into(),
+                        data: None,
+                    })
+                }
+            }
+            "outbox_queued" => {
+                if let Some(data) = self.data {
+                    Ok(Event::OutboxQueued(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "outbox_queued".into(),
+                        data: None,
+                    })
+                }
+            }
+            "outbox_sent" => {
+                if let Some(data) = self.data {
+                    Ok(Event::OutboxSent(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "outbox_sent".into(),
+                        data: None,
+                    })
+                }
+            }
+            "outbox_failed" => {
+                if let Some(data) = self.data {
+                    Ok(Event::OutboxFailed(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "outbox_failed".into(),
+                        data: None,
+                    })
+                }
+            }
+            "reconnect_attempt" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ReconnectAttempt(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "reconnect_attempt".into(),
+                        data: None,
+                    })
+                }
+            }
+            "keepalive_timeout" => {
+                if let Some(data) = self.data {
+                    Ok(Event::KeepAliveTimeout(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "keepalive_timeout".into(),
+                        data: None,
+                    })
+                }
+            }
+            "keepalive_restored" => {
+                if let Some(data) = self.data {
+                    Ok(Event::KeepAliveRestored(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::KeepAliveRestored(KeepAliveRestoredEvent {
+                        failures: 0,
+                    }))
+                }
+            }
+            "send_result" => {
+                if let Some(data) = self.data {
+                    Ok(Event::SendResult(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "send_result".into(),
+                        data: None,
+                    })
+                }
+            }
+            "message_edited" => {
+                if let Some(data) = self.data {
+                    Ok(Event::MessageEdited(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "message_edited".into(),
+                        data: None,
+                    })
+                }
+            }
+            "message_revoked" => {
+                if let Some(data) = self.data {
+                    Ok(Event::MessageRevoked(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "message_revoked".into(),
+                        data: None,
+                    })
+                }
+            }
+            "reaction" => {
+                if let Some(data) = self.data {
+                    Ok(Event::Reaction(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "reaction".into(),
+                        data: None,
+                    })
+                }
+            }
+            "poll_created" => {
+                if let Some(data) = self.data {
+                    Ok(Event::PollCreated(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "poll_created".into(),
+                        data: None,
+                    })
+                }
+            }
+            "poll_vote" => {
+                if let Some(data) = self.data {
+                    Ok(Event::PollVote(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "poll_vote".into(),
+                        data: None,
+                    })
+                }
+            }
+            "contacts_synced" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ContactsSynced(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "contacts_synced".into(),
+                        data: None,
+                    })
+                }
+            }
+            "qr_timeout" => {
+                if let Some(data) = self.data {
+                    Ok(Event::QrTimeout(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "qr_timeout".into(),
+                        data: None,
+                    })
+                }
+            }
+            "group_member_add_mode" => {
+                if let Some(data) = self.data {
+                    Ok(Event::GroupMemberAddMode(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "group_member_add_mode".into(),
+                        data: None,
+                    })
+                }
+            }
+            "picture_update" => {
+                if let Some(data) = self.data {
+                    Ok(Event::PictureUpdate(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "picture_update".into(),
+                        data: None,
+                    })
+                }
+            }
+            "user_about" => {
+                if let Some(data) = self.data {
+                    Ok(Event::UserAbout(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "user_about".into(),
+                        data: None,
+                    })
+                }
+            }
+            "archive" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ChatArchive(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "archive".into(),
+                        data: None,
+                    })
+                }
+            }
+            "pin" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ChatPin(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "pin".into(),
+                        data: None,
+                    })
+                }
+            }
+            "mute" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ChatMute(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "mute".into(),
+                        data: None,
+                    })
+                }
+            }
+            "chat_deleted" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ChatDeleted(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "chat_deleted".into(),
+                        data: None,
+                    })
+                }
+            }
+            "chat_cleared" => {
+                if let Some(data) = self.data {
+                    Ok(Event::ChatCleared(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "chat_cleared".into(),
+                        data: None,
+                    })
+                }
+            }
+            "history_sync_chunk" => {
+                if let Some(data) = self.data {
+                    Ok(Event::HistorySyncChunk(serde_json::from_value(data)?))
+                } else {
+                    Ok(Event::Unknown {
+                        event_type: "history_sync_chunk".into(),
+                        data: None,
+                    })
+                }
+            }
             other => Ok(Event::Unknown {
                 event_type: other.to_string(),
                 data: self.data,
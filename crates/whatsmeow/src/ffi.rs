@@ -3,11 +3,13 @@
 use std::ffi::CString;
 use std::path::Path;
 
-use tracing::{debug, warn};
+use serde::Deserialize;
+use tracing::{debug, error, info, warn};
 use whatsmeow_sys::{self as sys, ClientHandle, error_codes::*};
 
 use crate::allocator::TrackedAllocator;
 use crate::error::{Error, Result};
+use crate::events::SendResult;
 
 /// Global allocator reference for tracing (set by the example/app)
 #[global_allocator]
@@ -75,9 +77,40 @@ impl FfiClient {
         self.check_result(result)
     }
 
+    /// Whether the websocket is connected, as tracked by whatsmeow
+    pub fn is_connected(&self) -> bool {
+        unsafe { sys::wm_client_is_connected(self.handle) == 1 }
+    }
+
+    /// Whether the client is connected and authenticated
+    pub fn is_logged_in(&self) -> bool {
+        unsafe { sys::wm_client_is_logged_in(self.handle) == 1 }
+    }
+
     pub fn poll_event(&mut self) -> Result<Option<Vec<u8>>> {
+        let mut data: *const i8 = std::ptr::null();
+        let mut len = 0;
+        let n = unsafe { sys::wm_poll_event_ref(self.handle, &mut data, &mut len) };
+
+        if n < 0 {
+            self.check_result(n)?;
+        }
+
+        if n == 0 {
+            return Ok(None);
+        }
+
+        // The bridge lends the event until it is freed, so no event is too
+        // large for a fixed buffer
+        let event = unsafe { std::slice::from_raw_parts(data as *const u8, len as usize) }.to_vec();
+        unsafe { sys::wm_event_free(data) };
+        Ok(Some(event))
+    }
+
+    /// Poll the next log line queued by the bridge
+    pub fn poll_log(&mut self) -> Result<Option<LogLine>> {
         let n = unsafe {
-            sys::wm_poll_event(
+            sys::wm_poll_log(
                 self.handle,
                 self.event_buffer.as_mut_ptr() as *mut i8,
                 self.event_buffer.len() as i32,
@@ -92,20 +125,34 @@ impl FfiClient {
             return Ok(None);
         }
 
-        Ok(Some(self.event_buffer[..n as usize].to_vec()))
+        Ok(Some(serde_json::from_slice(
+            &self.event_buffer[..n as usize],
+        )?))
     }
 
     #[tracing::instrument(skip(self), name = "ffi.send_message", fields(to = %jid, text_len = text.len()))]
-    pub fn send_message(&self, jid: &str, text: &str) -> Result<()> {
+    pub fn send_message(
+        &self,
+        jid: &str,
+        text: &str,
+        message_id: Option<&str>,
+    ) -> Result<SendResult> {
         let c_jid = CString::new(jid).map_err(|_| Error::Send("JID contains null byte".into()))?;
         let c_text =
             CString::new(text).map_err(|_| Error::Send("Text contains null byte".into()))?;
+        let c_id = optional_cstring(message_id, "Message ID")?;
 
         let result = GLOBAL.trace_operation("wm_send_message", || unsafe {
-            sys::wm_send_message(self.handle, c_jid.as_ptr(), c_text.as_ptr())
+            sys::wm_send_message_with_id(
+                self.handle,
+                c_jid.as_ptr(),
+                c_text.as_ptr(),
+                optional_ptr(&c_id),
+            )
         });
 
-        self.check_result(result)
+        self.check_result(result)?;
+        self.send_result()
     }
 
     #[tracing::instrument(skip(self, data), name = "ffi.send_image", fields(to = %jid, data_len = data.len(), mime = %mime_type))]
@@ -115,31 +162,53 @@ impl FfiClient {
         data: &[u8],
         mime_type: &str,
         caption: Option<&str>,
-    ) -> Result<()> {
+        message_id: Option<&str>,
+    ) -> Result<SendResult> {
         let c_jid = CString::new(jid).map_err(|_| Error::Send("JID contains null byte".into()))?;
         let c_mime = CString::new(mime_type)
             .map_err(|_| Error::Send("MIME type contains null byte".into()))?;
-        let c_caption = caption
-            .map(|c| CString::new(c).map_err(|_| Error::Send("Caption contains null byte".into())))
-            .transpose()?;
-
-        let caption_ptr = c_caption
-            .as_ref()
-            .map(|c| c.as_ptr())
-            .unwrap_or(std::ptr::null());
+        let c_caption = optional_cstring(caption, "Caption")?;
+        let c_id = optional_cstring(message_id, "Message ID")?;
 
         let result = GLOBAL.trace_operation("wm_send_image", || unsafe {
-            sys::wm_send_image(
+            sys::wm_send_image_with_id(
                 self.handle,
                 c_jid.as_ptr(),
                 data.as_ptr() as *const i8,
                 data.len() as i32,
                 c_mime.as_ptr(),
-                caption_ptr,
+                optional_ptr(&c_caption),
+                optional_ptr(&c_id),
             )
         });
 
-        self.check_result(result)
+        self.check_result(result)?;
+        self.send_result()
+    }
+
+    /// Allocate a message ID for a later send
+    pub fn generate_message_id(&self) -> Result<String> {
+        let mut buf = [0u8; 128];
+        let n = unsafe {
+            sys::wm_generate_message_id(self.handle, buf.as_mut_ptr() as *mut i8, buf.len() as i32)
+        };
+        if n < 0 {
+            self.check_result(n)?;
+        }
+        Ok(String::from_utf8_lossy(&buf[..n as usize]).into_owned())
+    }
+
+    /// Read the result of the last successful send made on this thread
+    fn send_result(&self) -> Result<SendResult> {
+        let mut buf = [0u8; 512];
+        let n = unsafe { sys::wm_get_send_result(buf.as_mut_ptr() as *mut i8, buf.len() as i32) };
+        if n <= 0 {
+            return Err(Error::Ffi {
+                code: n,
+                message: "send result unavailable".into(),
+            });
+        }
+        Ok(serde_json::from_slice(&buf[..n as usize])?)
     }
 
     fn check_result(&self, code: i32) -> Result<()> {
@@ -161,6 +230,14 @@ impl FfiClient {
                 warn!(code, "FFI invalid handle");
                 Err(Error::InvalidHandle)
             }
+            WM_ERR_FROZEN => {
+                warn!(code, "FFI client frozen");
+                Err(Error::Frozen)
+            }
+            WM_ERR_PANIC => {
+                error!(code, "FFI call panicked");
+                Err(Error::Panic)
+            }
             _ => {
                 warn!(code, "FFI unknown error");
                 Err(Error::Ffi {
@@ -183,3 +260,44 @@ impl Drop for FfiClient {
 }
 
 unsafe impl Send for FfiClient {}
+
+/// A whatsmeow or bridge log line
+#[derive(Debug, Deserialize)]
+pub(crate) struct LogLine {
+    level: String,
+    module: String,
+    message: String,
+    #[serde(default)]
+    dropped: u64,
+}
+
+impl LogLine {
+    /// Re-emit the line as a tracing event under the `whatsmeow_bridge` target
+    pub fn emit(&self) {
+        if self.dropped > 0 {
+            warn!(target: "whatsmeow_bridge", dropped = self.dropped, "Bridge log lines dropped");
+        }
+        let (module, message) = (&self.module, &self.message);
+        match self.level.as_str() {
+            "ERROR" => error!(target: "whatsmeow_bridge", module = %module, "{message}"),
+            "WARN" => warn!(target: "whatsmeow_bridge", module = %module, "{message}"),
+            "INFO" => info!(target: "whatsmeow_bridge", module = %module, "{message}"),
+            _ => debug!(target: "whatsmeow_bridge", module = %module, "{message}"),
+        }
+    }
+}
+
+/// Convert an optional string argument, naming it in the null-byte error
+fn optional_cstring(value: Option<&str>, what: &str) -> Result<Option<CString>> {
+    value
+        .map(|v| CString::new(v).map_err(|_| Error::Send(format!("{what} contains null byte"))))
+        .transpose()
+}
+
+/// Pointer to an optional C string, or null
+fn optional_ptr(value: &Option<CString>) -> *const std::ffi::c_char {
+    value
+        .as_ref()
+        .map(|v| v.as_ptr())
+        .unwrap_or(std::ptr::null())
+}
@@ -121,9 +121,47 @@ impl Handlers {
                 }
             }
             // Ignored events
-            Event::HistorySync
+            Event::HistorySync(_)
             | Event::OfflineSyncPreview(_)
             | Event::OfflineSyncCompleted(_)
+            | Event::ConnectFailure(_)
+            | Event::StreamReplaced(_)
+            | Event::TemporaryBan(_)
+            | Event::ClientOutdated(_)
+            | Event::OutboxQueued(_)
+            | Event::OutboxSent(_)
+            | Event::OutboxFailed(_)
+            | Event::PreKeyLow(_)
+            | Event::PreKeysUploaded(_)
+            | Event::IdentityChange(_)
+            | Event::Undecryptable(_)
+            | Event::RetryRequest(_)
+            | Event::CallOffer(_)
+            | Event::CallAccept(_)
+            | Event::CallOfferNotice(_)
+            | Event::CallTerminate(_)
+            | Event::StatusUpdate(_)
+            | Event::NewsletterMessage(_)
+            | Event::ReconnectAttempt(_)
+            | Event::KeepAliveTimeout(_)
+            | Event::KeepAliveRestored(_)
+            | Event::SendResult(_)
+            | Event::MessageEdited(_)
+            | Event::MessageRevoked(_)
+            | Event::Reaction(_)
+            | Event::PollCreated(_)
+            | Event::PollVote(_)
+            | Event::ContactsSynced(_)
+            | Event::QrTimeout(_)
+            | Event::GroupMemberAddMode(_)
+            | Event::PictureUpdate(_)
+            | Event::UserAbout(_)
+            | Event::ChatArchive(_)
+            | Event::ChatPin(_)
+            | Event::ChatMute(_)
+            | Event::ChatDeleted(_)
+            | Event::ChatCleared(_)
+            | Event::HistorySyncChunk(_)
             | Event::Unknown { .. } => {}
         }
     }
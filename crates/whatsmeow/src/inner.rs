@@ -1,7 +1,6 @@
 //! Internal client state
 
 use std::sync::Arc;
-use std::sync::atomic::{AtomicBool, Ordering};
 use std::time::Duration;
 
 use parking_lot::Mutex;
@@ -9,7 +8,7 @@ use tokio::sync::watch;
 
 use crate::error::Result;
 use crate::event_bus::EventBus;
-use crate::events::RawEvent;
+use crate::events::{RawEvent, SendResult};
 use crate::ffi::FfiClient;
 use crate::handlers::Handlers;
 use crate::stream::EventStream;
@@ -23,7 +22,6 @@ pub(crate) struct InnerClient {
     pub handlers: Arc<Handlers>,
     shutdown_tx: watch::Sender<bool>,
     shutdown_rx: watch::Receiver<bool>,
-    connected: AtomicBool,
 }
 
 impl InnerClient {
@@ -36,7 +34,6 @@ impl InnerClient {
             handlers: Arc::new(Handlers::new()),
             shutdown_tx,
             shutdown_rx,
-            connected: AtomicBool::new(false),
         }
     }
 
@@ -44,8 +41,7 @@ impl InnerClient {
     pub async fn connect(&self) -> Result<()> {
         tracing::info!("Connecting to WhatsApp");
         self.ffi.lock().connect()?;
-        self.connected.store(true, Ordering::SeqCst);
-        tracing::info!("Connected to WhatsApp");
+        tracing::info!("Connection started");
         Ok(())
     }
 
@@ -67,6 +63,11 @@ impl InnerClient {
                 break;
             }
 
+            // Forward bridge logs into tracing
+            while let Some(line) = { ffi.lock().poll_log()? } {
+                line.emit();
+            }
+
             let data = { ffi.lock().poll_event()? };
 
             if let Some(bytes) = data {
@@ -107,8 +108,13 @@ impl InnerClient {
         self.event_bus.subscribe()
     }
 
-    pub fn send_message(&self, jid: &str, text: &str) -> Result<()> {
-        self.ffi.lock().send_message(jid, text)
+    pub fn send_message(
+        &self,
+        jid: &str,
+        text: &str,
+        message_id: Option<&str>,
+    ) -> Result<SendResult> {
+        self.ffi.lock().send_message(jid, text, message_id)
     }
 
     pub fn send_image(
@@ -117,8 +123,15 @@ impl InnerClient {
         data: &[u8],
         mime_type: &str,
         caption: Option<&str>,
-    ) -> Result<()> {
-        self.ffi.lock().send_image(jid, data, mime_type, caption)
+        message_id: Option<&str>,
+    ) -> Result<SendResult> {
+        self.ffi
+            .lock()
+            .send_image(jid, data, mime_type, caption, message_id)
+    }
+
+    pub fn generate_message_id(&self) -> Result<String> {
+        self.ffi.lock().generate_message_id()
     }
 
     pub fn disconnect(&self) {
@@ -126,11 +139,14 @@ impl InnerClient {
         if let Some(client) = self.ffi.try_lock() {
             let _ = client.disconnect();
         }
-        self.connected.store(false, Ordering::SeqCst);
     }
 
     pub fn is_connected(&self) -> bool {
-        self.connected.load(Ordering::SeqCst)
+        self.ffi.lock().is_connected()
+    }
+
+    pub fn is_logged_in(&self) -> bool {
+        self.ffi.lock().is_logged_in()
     }
 }
 
@@ -38,8 +38,16 @@ pub use client::WhatsApp;
 pub use embedded::ensure_dll_extracted;
 pub use error::{Error, Result};
 pub use events::{
-    Event, Jid, LoggedOutEvent, MediaSource, MessageEvent, MessageInfo, MessageType,
-    PairSuccessEvent, PresenceEvent, QrEvent, ReceiptEvent,
+    CallEvent, ChatArchiveEvent, ChatDeletedEvent, ChatMuteEvent, ChatPinEvent,
+    ClientOutdatedEvent, ConnectFailureEvent, ContactInfo, ContactsSyncedEvent, Event,
+    GroupMemberAddModeEvent, HistoryConversation, HistoryMessage, HistoryParticipant,
+    HistorySyncChunkEvent, HistorySyncEvent, IdentityChangeEvent, Jid, KeepAliveRestoredEvent,
+    KeepAliveTimeoutEvent, LoggedOutEvent, MediaSource, MessageEditedEvent, MessageEvent,
+    MessageInfo, MessageRevokedEvent, MessageType, NewsletterMessageEvent, OutboxEvent,
+    PairSuccessEvent, PictureUpdateEvent, PollCreatedEvent, PollVoteEvent, PreKeyLowEvent,
+    PreKeysUploadedEvent, PresenceEvent, QrEvent, QrTimeoutEvent, ReactionEvent, ReceiptEvent,
+    ReconnectAttemptEvent, RetryRequestEvent, SendResult, SendResultEvent, StatusUpdateEvent,
+    StreamReplacedEvent, TemporaryBanEvent, UndecryptableEvent, UserAboutEvent,
 };
 pub use manager::{ClientId, WhatsAppManager};
 pub use stream::EventStream;